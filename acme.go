@@ -0,0 +1,121 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// acmeChallengeTTL keeps DNS-01 answers short-lived in resolver caches.
+const acmeChallengeTTL = 120
+
+// SetChallenge publishes value as a TXT record at _acme-challenge.<host> for ACME DNS-01 validation.
+// Existing values for the same name are kept, so several orders (for example example.com and
+// *.example.com) can be validated at once. Setting a value that is already present is a no-op.
+func (r *Runner) SetChallenge(ctx context.Context, host, value string) error {
+	name := acmeChallengeName(host)
+	zone, err := r.challengeZone(ctx, name)
+	if err != nil {
+		return err
+	}
+	records, err := r.client.listTXTRecords(ctx, zone.ID, name)
+	if err != nil {
+		return fmt.Errorf("list TXT records for %s: %w", name, err)
+	}
+	for _, record := range records {
+		if unquoteTXT(record.Content) == value {
+			r.debugf("challenge %s already present", name)
+			return nil
+		}
+	}
+	r.infof("create TXT record domain=%s", name)
+	if _, err := r.client.createTXTRecord(ctx, zone.ID, name, value); err != nil {
+		return fmt.Errorf("create TXT record %s: %w", name, err)
+	}
+	return nil
+}
+
+// ClearChallenge removes every TXT value at _acme-challenge.<host>.
+func (r *Runner) ClearChallenge(ctx context.Context, host string) error {
+	name := acmeChallengeName(host)
+	zone, err := r.challengeZone(ctx, name)
+	if err != nil {
+		return err
+	}
+	records, err := r.client.listTXTRecords(ctx, zone.ID, name)
+	if err != nil {
+		return fmt.Errorf("list TXT records for %s: %w", name, err)
+	}
+	for _, record := range records {
+		r.infof("delete TXT record domain=%s", name)
+		if err := r.client.deleteRecord(ctx, zone.ID, record.ID); err != nil {
+			return fmt.Errorf("delete TXT record %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) challengeZone(ctx context.Context, name string) (*cfZone, error) {
+	zones, err := r.client.listZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing zones: %w", err)
+	}
+	return r.resolveZone(name, zonesInAccount(zones, r.client.accountID))
+}
+
+// acmeChallengeName maps a certificate host (wildcards included) to its DNS-01 record name.
+func acmeChallengeName(host string) string {
+	host = normalizeHost(strings.TrimPrefix(strings.TrimSpace(host), "*."))
+	if strings.HasPrefix(host, "_acme-challenge.") {
+		return host
+	}
+	return "_acme-challenge." + host
+}
+
+// unquoteTXT strips the surrounding quotes Cloudflare may return on TXT content.
+func unquoteTXT(content string) string {
+	if len(content) >= 2 && strings.HasPrefix(content, `"`) && strings.HasSuffix(content, `"`) {
+		return content[1 : len(content)-1]
+	}
+	return content
+}
+
+func (c *cloudflareClient) listTXTRecords(ctx context.Context, zoneID, name string) ([]cfRecord, error) {
+	path := fmt.Sprintf("/zones/%s/dns_records?type=TXT&name=%s&per_page=100", zoneID, nameQuery(name))
+	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var records []cfRecord
+	if err := json.Unmarshal(env.Result, &records); err != nil {
+		return nil, fmt.Errorf("invalid dns records payload: %w", err)
+	}
+	filtered := make([]cfRecord, 0, len(records))
+	for _, record := range records {
+		if strings.EqualFold(record.Name, name) && record.Type == "TXT" {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered, nil
+}
+
+func (c *cloudflareClient) createTXTRecord(ctx context.Context, zoneID, name, value string) (*cfRecord, error) {
+	payload := map[string]interface{}{
+		"type":    "TXT",
+		"name":    name,
+		"content": value,
+		"ttl":     acmeChallengeTTL,
+	}
+	path := fmt.Sprintf("/zones/%s/dns_records", zoneID)
+	env, err := c.doRequest(ctx, http.MethodPost, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	var record cfRecord
+	if err := json.Unmarshal(env.Result, &record); err != nil {
+		return nil, fmt.Errorf("invalid create record payload: %w", err)
+	}
+	return &record, nil
+}
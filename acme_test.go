@@ -0,0 +1,60 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSetAndClearChallenge(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "a1", Name: "example.com", Type: "A", Content: "198.51.100.1"},
+		{ID: "t0", Name: "_acme-challenge.other.example.com", Type: "TXT", Content: `"keep"`},
+	}
+	r := newTestRunner(t, CreateConfig(), cf, "203.0.113.10")
+	ctx := context.Background()
+
+	// Apex and wildcard orders share one name and need both values at once.
+	if err := r.SetChallenge(ctx, "example.com", "token-apex"); err != nil {
+		t.Fatalf("SetChallenge failed: %v", err)
+	}
+	if err := r.SetChallenge(ctx, "*.example.com", "token-wild"); err != nil {
+		t.Fatalf("SetChallenge failed: %v", err)
+	}
+	if err := r.SetChallenge(ctx, "example.com", "token-apex"); err != nil {
+		t.Fatalf("SetChallenge failed: %v", err)
+	}
+	if cf.countCalls(http.MethodPost) != 2 {
+		t.Fatalf("expected two TXT records and no duplicate, got %v", cf.calls)
+	}
+	values := map[string]bool{}
+	for _, rec := range cf.records["z1"] {
+		if rec.Name == "_acme-challenge.example.com" && rec.Type == "TXT" {
+			values[rec.Content] = true
+		}
+	}
+	if !values["token-apex"] || !values["token-wild"] {
+		t.Fatalf("expected both challenge values, got %v", values)
+	}
+
+	if err := r.ClearChallenge(ctx, "example.com"); err != nil {
+		t.Fatalf("ClearChallenge failed: %v", err)
+	}
+	if len(cf.records["z1"]) != 2 {
+		t.Fatalf("expected only unrelated records to remain, got %+v", cf.records["z1"])
+	}
+}
+
+func TestSetChallengeMatchesQuotedContent(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "t1", Name: "_acme-challenge.app.example.com", Type: "TXT", Content: `"abc"`}}
+	r := newTestRunner(t, CreateConfig(), cf, "203.0.113.10")
+
+	if err := r.SetChallenge(context.Background(), "_acme-challenge.app.example.com", "abc"); err != nil {
+		t.Fatalf("SetChallenge failed: %v", err)
+	}
+	if cf.countCalls(http.MethodPost) != 0 {
+		t.Fatalf("expected quoted existing value to match, got %v", cf.calls)
+	}
+}
@@ -0,0 +1,57 @@
+package ddns_traefik_plugin
+
+import "context"
+
+// adoptUnowned stamps the managed comment onto records at domain that carry no comment at all, on
+// the first cycle that visits their zone with AdoptExistingOnFirstRun. Records with any other comment
+// have an owner or a note from someone else and are left as they are. The adopted comment is set on
+// records in place so the rest of syncDomain sees them as owned.
+func (r *Runner) adoptUnowned(ctx context.Context, zone *cfZone, domain string, records []cfRecord) error {
+	if !r.cfg.AdoptExistingOnFirstRun {
+		return nil
+	}
+	if _, done := r.adoptedZones[zone.ID]; done {
+		return nil
+	}
+	for i, record := range records {
+		if r.ownsRecord(zone.Name, record) {
+			continue
+		}
+		if record.Comment != "" {
+			r.debugf("domain=%s record %s not adopted (comment %q)", domain, record.ID, record.Comment)
+			continue
+		}
+		comment := r.buildComment(zone.Name, domain, record.Content)
+		if r.writesHeld {
+			r.infof("would adopt A record domain=%s ip=%s (%s)", domain, record.Content, r.holdReason)
+			continue
+		}
+		r.infof("adopt A record domain=%s ip=%s", domain, record.Content)
+		updated, err := r.client.updateARecord(ctx, zone.ID, record.ID, record.Name, record.Content, record.Proxied, record.TTL, comment)
+		r.seedWritten(zone.ID, updated)
+		if err != nil {
+			r.adoptFailed[zone.ID] = struct{}{}
+			return err
+		}
+		records[i].Comment = comment
+	}
+	return nil
+}
+
+// finishAdoption marks zones whose first cycle has run so adoption is not repeated. Zones visited
+// while writes are held, and zones where an adoption write failed, are retried at the next cycle that
+// may write.
+func (r *Runner) finishAdoption(zoneIDs map[string]struct{}) {
+	failed := r.adoptFailed
+	r.adoptFailed = make(map[string]struct{})
+	if !r.cfg.AdoptExistingOnFirstRun || r.writesHeld {
+		return
+	}
+	for id := range zoneIDs {
+		if _, ok := failed[id]; ok {
+			r.debugf("zone=%s adoption incomplete; retrying next cycle", id)
+			continue
+		}
+		r.adoptedZones[id] = struct{}{}
+	}
+}
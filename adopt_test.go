@@ -0,0 +1,71 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAdoptExistingOnFirstRun(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7", TTL: 300},
+		{ID: "r2", Name: "api.example.com", Type: "A", Content: "203.0.113.7", Comment: "owned by the platform team"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "api.example.com"}
+	cfg.AdoptExistingOnFirstRun = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	app, api := cf.records["z1"][0], cf.records["z1"][1]
+	if app.Comment != "managed-by=traefik-plugin-ddns" || app.TTL != 300 || app.Content != "203.0.113.7" {
+		t.Fatalf("expected uncommented record adopted without other changes, got %+v", app)
+	}
+	if api.Comment != "owned by the platform team" {
+		t.Fatalf("expected record with another comment left alone, got %+v", api)
+	}
+
+	// Only the first cycle adopts: a record that loses its comment later is not re-stamped.
+	cf.records["z1"][0].Comment = ""
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if n := cf.countCalls("PUT"); n != 1 {
+		t.Fatalf("expected a single adoption write, got %v", cf.calls)
+	}
+}
+
+func TestAdoptionRetriedAfterFailedWrite(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7"}}
+	failPUT := true
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method == http.MethodPut && failPUT {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":1004,"message":"invalid request"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.AdoptExistingOnFirstRun = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	_, _ = r.reconcile(context.Background())
+	if _, adopted := r.adoptedZones["z1"]; adopted {
+		t.Fatalf("expected a zone with a failed adoption write not to be marked adopted")
+	}
+
+	failPUT = false
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got := cf.records["z1"][0].Comment; got != "managed-by=traefik-plugin-ddns" {
+		t.Fatalf("expected the record adopted on the next cycle, got comment %q", got)
+	}
+	if _, adopted := r.adoptedZones["z1"]; !adopted {
+		t.Fatalf("expected the zone marked adopted once its adoption writes succeeded")
+	}
+}
@@ -0,0 +1,60 @@
+package ddns_traefik_plugin
+
+import (
+	"sort"
+	"time"
+)
+
+// budgetOrder orders a cycle's hosts when CycleBudgetSeconds is set: hosts the previous cycle did not
+// reach go first, in the order they were left, followed by the rest sorted by name. Over successive
+// over-budget cycles every host gets its turn instead of the same tail being dropped each time.
+func (r *Runner) budgetOrder(hosts []string) []string {
+	sort.Strings(hosts)
+	if len(r.budgetCarry) == 0 {
+		return hosts
+	}
+	present := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		present[host] = true
+	}
+	ordered := make([]string, 0, len(hosts))
+	for _, host := range r.budgetCarry {
+		if present[host] {
+			ordered = append(ordered, host)
+			present[host] = false
+		}
+	}
+	for _, host := range hosts {
+		if present[host] {
+			ordered = append(ordered, host)
+		}
+	}
+	r.budgetCarry = nil
+	return ordered
+}
+
+// budgetExhausted reports whether the cycle that started at started has used up CycleBudgetSeconds.
+func (r *Runner) budgetExhausted(started time.Time) bool {
+	budget := time.Duration(r.cfg.CycleBudgetSeconds) * time.Second
+	return budget > 0 && r.clock.Now().Sub(started) >= budget
+}
+
+// carryOver ends an over-budget cycle: hosts it did not reach are reported as skipped and remembered
+// for the front of the next cycle, and a partial-progress summary is logged.
+func (r *Runner) carryOver(unreached []string, results []DomainStatus, publicIP string, started time.Time) []DomainStatus {
+	reconciled, skipped := 0, 0
+	for _, status := range results {
+		if status.Action == ActionSkipped || status.Action == ActionFailed {
+			skipped++
+		} else {
+			reconciled++
+		}
+	}
+	r.warnf("cycle budget of %ds exhausted after %s: %d hosts reconciled, %d skipped or failed, %d not reached (first in line next cycle)",
+		r.cfg.CycleBudgetSeconds, r.clock.Now().Sub(started).Round(time.Millisecond), reconciled, skipped, len(unreached))
+	r.budgetCarry = append([]string(nil), unreached...)
+	for _, host := range unreached {
+		results = append(results, DomainStatus{Domain: host, IP: publicIP, Action: ActionSkipped, Error: "cycle budget exhausted"})
+	}
+	return results
+}
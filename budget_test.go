@@ -0,0 +1,51 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCycleBudgetCarriesUnreachedHostsToNextCycle(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"d.example.com", "b.example.com", "a.example.com", "c.example.com"}
+	cfg.CycleBudgetSeconds = 60
+	clock := newFakeClock(time.Unix(0, 0))
+	// Every host takes 40s, so a 60s budget fits two hosts per cycle.
+	cfg.ResolveContent = func(host string) (string, error) {
+		clock.Advance(40 * time.Second)
+		return "203.0.113.7", nil
+	}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	r.clock = clock
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	order := func(results []DomainStatus) string {
+		var out []string
+		for _, status := range results {
+			out = append(out, strings.TrimSuffix(status.Domain, ".example.com")+"="+status.Action)
+		}
+		return strings.Join(out, " ")
+	}
+	for _, want := range []string{
+		"a=created b=created c=skipped d=skipped",
+		"c=created d=created a=skipped b=skipped",
+		"a=unchanged b=unchanged c=skipped d=skipped",
+	} {
+		results, err := r.reconcile(context.Background())
+		if err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		if got := order(results); got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+	if !strings.Contains(logs.String(), "cycle budget of 60s exhausted after 1m20s: 2 hosts reconciled, 0 skipped or failed, 2 not reached") {
+		t.Fatalf("expected a partial-progress summary, got:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,32 @@
+package ddns_traefik_plugin
+
+import "time"
+
+// clock abstracts time so interval, backoff and grace-period logic can be tested deterministically.
+type clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) ticker
+	Sleep(d time.Duration)
+}
+
+// ticker is the subset of *time.Ticker used by the runner.
+type ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) ticker { return realTicker{time.NewTicker(d)} }
+
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) Chan() <-chan time.Time { return t.t.C }
+
+func (t realTicker) Stop() { t.t.Stop() }
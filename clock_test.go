@@ -0,0 +1,188 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when Sleep or Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+	ticker *fakeTicker
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ticker = &fakeTicker{ch: make(chan time.Time, 1)}
+	return c.ticker
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type fakeTicker struct {
+	ch      chan time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() { t.stopped = true }
+
+func TestDoRequestBackoffUsesClock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(os.Stdout, "", 0))
+	client.baseURL = server.URL
+	fake := newFakeClock(time.Unix(0, 0))
+	client.clock = fake
+
+	start := time.Now()
+	if _, err := client.listZones(context.Background()); err == nil {
+		t.Fatalf("expected retryable failure")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("backoff should not use the real clock")
+	}
+	if len(fake.sleeps) != 2 || fake.sleeps[0] != time.Second || fake.sleeps[1] != 2*time.Second {
+		t.Fatalf("unexpected backoff sleeps: %v", fake.sleeps)
+	}
+}
+
+func TestRunnerStartUsesClockTicker(t *testing.T) {
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "token"
+	cfg.Enabled = false
+	r, err := newRunner(cfg)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	fake := newFakeClock(time.Unix(0, 0))
+	r.clock = fake
+
+	done := make(chan struct{})
+	go func() {
+		r.Start()
+		close(done)
+	}()
+
+	for {
+		fake.mu.Lock()
+		tk := fake.ticker
+		fake.mu.Unlock()
+		if tk != nil {
+			tk.ch <- fake.Now()
+			close(tk.ch)
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Start did not return after ticker channel closed")
+	}
+	if !fake.ticker.stopped {
+		t.Fatalf("expected ticker to be stopped")
+	}
+}
+
+func TestRunnerStartHonorsInitialDelay(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.InitialDelaySeconds = 30
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	fake := newFakeClock(time.Unix(0, 0))
+	r.clock = fake
+
+	done := make(chan struct{})
+	go func() {
+		r.Start()
+		close(done)
+	}()
+	for {
+		fake.mu.Lock()
+		tk := fake.ticker
+		fake.mu.Unlock()
+		if tk != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := cf.countCalls("GET"); n != 0 {
+		t.Fatalf("expected no cycle during the initial delay, got %v", cf.calls)
+	}
+
+	r.Stop()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Start did not return when stopped during the initial delay")
+	}
+	if n := cf.countCalls("GET"); n != 0 {
+		t.Fatalf("expected no cycle after stopping during the delay, got %v", cf.calls)
+	}
+}
+
+func TestRunnerStartSyncsAfterInitialDelay(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.InitialDelaySeconds = 30
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	fake := newFakeClock(time.Unix(0, 0))
+	r.clock = fake
+	defer r.Stop()
+
+	go r.Start()
+	for {
+		fake.mu.Lock()
+		tk := fake.ticker
+		fake.mu.Unlock()
+		if tk != nil {
+			tk.ch <- fake.Now()
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for cf.countCalls("POST /zones/z1/dns_records") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected first cycle once the delay elapsed, got %v", cf.calls)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"sort"
@@ -17,10 +17,24 @@ import (
 type cloudflareClient struct {
 	baseURL    string
 	apiToken   string
+	accountID  string
 	httpClient *http.Client
+	clock      clock
 	logger     interface {
 		Printf(format string, v ...any)
 	}
+
+	// recordTags are written on every create/update; tagsDisabled is set once the plan rejects them.
+	recordTags   []string
+	tagsDisabled bool
+
+	// staticZones, when set, are returned by listZones without calling the API.
+	staticZones []cfZone
+	// partialZones keeps the zones fetched so far when a later zone listing page fails.
+	partialZones bool
+
+	// listings caches zone-wide listings during a sync cycle; nil outside one.
+	listings *recordCache
 }
 
 func newCloudflareClient(apiToken string, httpClient *http.Client, logger interface {
@@ -30,6 +44,7 @@ func newCloudflareClient(apiToken string, httpClient *http.Client, logger interf
 		baseURL:    "https://api.cloudflare.com/client/v4",
 		apiToken:   apiToken,
 		httpClient: httpClient,
+		clock:      realClock{},
 		logger:     logger,
 	}
 }
@@ -55,30 +70,57 @@ type cfPager struct {
 type cfZone struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// Status is the zone's activation state, for example active, pending or moved.
+	Status  string    `json:"status,omitempty"`
+	Account cfAccount `json:"account"`
+}
+
+type cfAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type cfRecord struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Type    string `json:"type"`
-	Content string `json:"content"`
-	Proxied bool   `json:"proxied"`
-	Comment string `json:"comment"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Content string   `json:"content"`
+	Proxied bool     `json:"proxied"`
+	TTL     int      `json:"ttl"`
+	Comment string   `json:"comment"`
+	Tags    []string `json:"tags,omitempty"`
+	// Proxiable is Cloudflare's verdict on whether the record can be proxied; nil when a response
+	// did not include it.
+	Proxiable *bool `json:"proxiable,omitempty"`
+	// Data is the structured content of SRV records.
+	Data *cfSRVData `json:"data,omitempty"`
 }
 
 func (c *cloudflareClient) listZones(ctx context.Context) ([]cfZone, error) {
+	if len(c.staticZones) > 0 {
+		return append([]cfZone(nil), c.staticZones...), nil
+	}
 	var zones []cfZone
 	page := 1
 	for {
 		path := fmt.Sprintf("/zones?page=%d&per_page=50", page)
+		if c.accountID != "" {
+			path += "&account.id=" + url.QueryEscape(c.accountID)
+		}
 		env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+		var pageZones []cfZone
+		if err == nil {
+			if jsonErr := json.Unmarshal(env.Result, &pageZones); jsonErr != nil {
+				err = fmt.Errorf("invalid zones payload: %w", jsonErr)
+			}
+		}
 		if err != nil {
+			if page > 1 && c.partialZones {
+				c.logger.Printf("[WARN] zone listing failed on page %d (%v); continuing with the %d zones fetched so far, the list may be incomplete", page, err, len(zones))
+				return zones, nil
+			}
 			return nil, err
 		}
-		var pageZones []cfZone
-		if err := json.Unmarshal(env.Result, &pageZones); err != nil {
-			return nil, fmt.Errorf("invalid zones payload: %w", err)
-		}
 		zones = append(zones, pageZones...)
 		if env.ResultInfo == nil || env.ResultInfo.TotalPages <= page {
 			break
@@ -89,7 +131,7 @@ func (c *cloudflareClient) listZones(ctx context.Context) ([]cfZone, error) {
 }
 
 func (c *cloudflareClient) listARecords(ctx context.Context, zoneID, host string) ([]cfRecord, error) {
-	escapedHost := url.QueryEscape(host)
+	escapedHost := nameQuery(host)
 	path := fmt.Sprintf("/zones/%s/dns_records?type=A&name=%s&per_page=100", zoneID, escapedHost)
 	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
 	if err != nil {
@@ -114,17 +156,79 @@ func (c *cloudflareClient) listARecords(ctx context.Context, zoneID, host string
 	return filtered, nil
 }
 
-func (c *cloudflareClient) createARecord(ctx context.Context, zoneID, host, ip string, proxied bool, comment string) (*cfRecord, error) {
+// listRecordsByName returns records of every type at host, for detecting conflicts such as a CNAME.
+func (c *cloudflareClient) listRecordsByName(ctx context.Context, zoneID, host string) ([]cfRecord, error) {
+	path := fmt.Sprintf("/zones/%s/dns_records?name=%s&per_page=100", zoneID, nameQuery(host))
+	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var records []cfRecord
+	if err := json.Unmarshal(env.Result, &records); err != nil {
+		return nil, fmt.Errorf("invalid dns records payload: %w", err)
+	}
+	filtered := make([]cfRecord, 0, len(records))
+	for _, r := range records {
+		if strings.EqualFold(r.Name, host) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
+
+// nameQuery encodes a record name for the name= filter. Cloudflare stores names lowercase and the
+// filter is an exact match, so a mixed-case host would otherwise find nothing and be created again.
+func nameQuery(name string) string {
+	return url.QueryEscape(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// listZoneARecords returns every A record in a zone, across all result pages.
+func (c *cloudflareClient) listZoneARecords(ctx context.Context, zoneID string) ([]cfRecord, error) {
+	return c.listZoneRecords(ctx, zoneID, "A")
+}
+
+// listZoneRecords returns every record of recordType in a zone, across all result pages.
+func (c *cloudflareClient) listZoneRecords(ctx context.Context, zoneID, recordType string) ([]cfRecord, error) {
+	if c.listings != nil {
+		if records, ok := c.listings.get(zoneID, recordType); ok {
+			return records, nil
+		}
+	}
+	var records []cfRecord
+	page := 1
+	for {
+		path := fmt.Sprintf("/zones/%s/dns_records?type=%s&page=%d&per_page=100", zoneID, recordType, page)
+		env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var pageRecords []cfRecord
+		if err := json.Unmarshal(env.Result, &pageRecords); err != nil {
+			return nil, fmt.Errorf("invalid dns records payload: %w", err)
+		}
+		records = append(records, pageRecords...)
+		if env.ResultInfo == nil || env.ResultInfo.TotalPages <= page {
+			break
+		}
+		page++
+	}
+	if c.listings != nil {
+		c.listings.put(zoneID, recordType, records)
+	}
+	return records, nil
+}
+
+func (c *cloudflareClient) createARecord(ctx context.Context, zoneID, host, ip string, proxied bool, ttl int, comment string) (*cfRecord, error) {
 	payload := map[string]interface{}{
 		"type":    "A",
 		"name":    host,
 		"content": ip,
-		"ttl":     1,
+		"ttl":     c.writableTTL(host, proxied, ttl),
 		"proxied": proxied,
 		"comment": comment,
 	}
 	path := fmt.Sprintf("/zones/%s/dns_records", zoneID)
-	env, err := c.doRequest(ctx, http.MethodPost, path, payload)
+	env, err := c.writeRecord(ctx, http.MethodPost, path, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -135,17 +239,17 @@ func (c *cloudflareClient) createARecord(ctx context.Context, zoneID, host, ip s
 	return &record, nil
 }
 
-func (c *cloudflareClient) updateARecord(ctx context.Context, zoneID, recordID, host, ip string, proxied bool, comment string) (*cfRecord, error) {
+func (c *cloudflareClient) updateARecord(ctx context.Context, zoneID, recordID, host, ip string, proxied bool, ttl int, comment string) (*cfRecord, error) {
 	payload := map[string]interface{}{
 		"type":    "A",
 		"name":    host,
 		"content": ip,
-		"ttl":     1,
+		"ttl":     c.writableTTL(host, proxied, ttl),
 		"proxied": proxied,
 		"comment": comment,
 	}
 	path := fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, recordID)
-	env, err := c.doRequest(ctx, http.MethodPut, path, payload)
+	env, err := c.writeRecord(ctx, http.MethodPut, path, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +260,44 @@ func (c *cloudflareClient) updateARecord(ctx context.Context, zoneID, recordID,
 	return &record, nil
 }
 
+// writableTTL returns the TTL to send for a record. Cloudflare rejects anything but automatic TTL on
+// proxied records, so a configured TTL is replaced there rather than failing the write.
+func (c *cloudflareClient) writableTTL(host string, proxied bool, ttl int) int {
+	if proxied && ttl != autoTTL {
+		c.logger.Printf("[DEBUG] domain=%s ttl=%d replaced by automatic TTL (proxied records only accept ttl=1)", host, ttl)
+		return autoTTL
+	}
+	return ttl
+}
+
+// writeRecord sends a create or update, adding recordTags when set. Tags are Enterprise-only: if
+// the API rejects them, tagging is switched off for the rest of the process and the write is retried.
+func (c *cloudflareClient) writeRecord(ctx context.Context, method, path string, payload map[string]interface{}) (*cfEnvelope, error) {
+	if len(c.recordTags) == 0 || c.tagsDisabled {
+		return c.doRequest(ctx, method, path, payload)
+	}
+	payload["tags"] = c.recordTags
+	env, err := c.doRequest(ctx, method, path, payload)
+	if err == nil || !isTagsUnsupported(err) {
+		return env, err
+	}
+	c.tagsDisabled = true
+	c.logger.Printf("[WARN] record tags rejected by Cloudflare (Enterprise plans only); writing records without tags: %v", err)
+	delete(payload, "tags")
+	return c.doRequest(ctx, method, path, payload)
+}
+
+func (c *cloudflareClient) deleteRecord(ctx context.Context, zoneID, recordID string) error {
+	path := fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, recordID)
+	_, err := c.doRequest(ctx, http.MethodDelete, path, nil)
+	return err
+}
+
 func (c *cloudflareClient) doRequest(ctx context.Context, method, path string, payload interface{}) (*cfEnvelope, error) {
+	// A write may land even when its response is lost, so the zone's listings are dropped up front.
+	if method != http.MethodGet && c.listings != nil {
+		c.listings.forgetZone(zoneIDFromPath(path))
+	}
 	var body []byte
 	var err error
 	if payload != nil {
@@ -192,11 +333,11 @@ func (c *cloudflareClient) doRequest(ctx context.Context, method, path string, p
 					return
 				}
 				if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
-					lastErr = fmt.Errorf("retryable status=%d body=%s", resp.StatusCode, string(raw))
+					lastErr = &CloudflareAPIError{StatusCode: resp.StatusCode, Codes: envelopeCodes(raw), body: string(raw), retryable: true}
 					return
 				}
 				if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-					lastErr = fmt.Errorf("non-success status=%d body=%s", resp.StatusCode, string(raw))
+					lastErr = &CloudflareAPIError{StatusCode: resp.StatusCode, Codes: envelopeCodes(raw), body: string(raw)}
 					return
 				}
 
@@ -206,7 +347,7 @@ func (c *cloudflareClient) doRequest(ctx context.Context, method, path string, p
 					return
 				}
 				if !env.Success {
-					lastErr = fmt.Errorf("cloudflare API error: %+v", env.Errors)
+					lastErr = &CloudflareAPIError{StatusCode: resp.StatusCode, Codes: errorCodes(env.Errors), errs: env.Errors}
 					return
 				}
 				lastErr = nil
@@ -218,45 +359,171 @@ func (c *cloudflareClient) doRequest(ctx context.Context, method, path string, p
 			return parsed, nil
 		}
 		if attempt < 3 {
-			time.Sleep(time.Duration(attempt) * time.Second)
+			c.clock.Sleep(time.Duration(attempt) * time.Second)
 		}
 	}
 	return nil, fmt.Errorf("cloudflare request failed: %w", lastErr)
 }
 
-func resolvePublicIPv4(ctx context.Context, sources []string, client *http.Client) (string, error) {
-	var errs []string
-	for _, source := range sources {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("%s: %v", source, err))
-			continue
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("%s: %v", source, err))
-			continue
-		}
+// Cloudflare error codes used to classify failures.
+const (
+	cfCodeUnauthorized   = 9109
+	cfCodeAuthentication = 10000
+	cfCodeRateLimited    = 971
+	// cfCodeRecordConflict: an A, AAAA or CNAME record with that host already exists.
+	cfCodeRecordConflict = 81053
+	// Returned when a create collides with an existing record of the same type.
+	cfCodeRecordAlreadyExists   = 81057
+	cfCodeIdenticalRecordExists = 81058
+)
 
-		raw, readErr := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if readErr != nil {
-			errs = append(errs, fmt.Sprintf("%s: %v", source, readErr))
-			continue
-		}
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			errs = append(errs, fmt.Sprintf("%s: status=%d", source, resp.StatusCode))
-			continue
+// CloudflareAPIError is a failed Cloudflare API call: a non-2xx response, a rate limit or server error
+// that persisted through retries, or an envelope with success=false. Use errors.As to inspect it.
+type CloudflareAPIError struct {
+	// StatusCode is the HTTP status of the last attempt.
+	StatusCode int
+	// Codes are the Cloudflare error codes from the response envelope, when it had one.
+	Codes []int
+
+	errs      []cfErr
+	body      string
+	retryable bool
+}
+
+func (e *CloudflareAPIError) Error() string {
+	switch {
+	case e.retryable:
+		return fmt.Sprintf("retryable status=%d body=%s", e.StatusCode, e.body)
+	case e.body != "":
+		return fmt.Sprintf("non-success status=%d body=%s", e.StatusCode, e.body)
+	}
+	return fmt.Sprintf("cloudflare API error: %+v", e.errs)
+}
+
+// Code reports whether the response carried Cloudflare error code n.
+func (e *CloudflareAPIError) Code(n int) bool {
+	for _, code := range e.Codes {
+		if code == n {
+			return true
 		}
+	}
+	return false
+}
 
-		candidate := strings.TrimSpace(string(raw))
-		parsed := net.ParseIP(candidate)
-		if parsed != nil && parsed.To4() != nil {
-			return candidate, nil
+// IsAuth reports whether the token is invalid or lacks permission for the request.
+func (e *CloudflareAPIError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden ||
+		e.Code(cfCodeUnauthorized) || e.Code(cfCodeAuthentication)
+}
+
+// IsRateLimited reports whether Cloudflare throttled the request.
+func (e *CloudflareAPIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Code(cfCodeRateLimited)
+}
+
+// IsConflict reports whether the write collided with an existing record, of the same or another type.
+func (e *CloudflareAPIError) IsConflict() bool {
+	return e.StatusCode == http.StatusConflict || e.alreadyExists() || e.Code(cfCodeRecordConflict)
+}
+
+// alreadyExists reports whether a create failed because the same record exists already.
+func (e *CloudflareAPIError) alreadyExists() bool {
+	return e.Code(cfCodeRecordAlreadyExists) || e.Code(cfCodeIdenticalRecordExists)
+}
+
+// isAlreadyExists reports whether err wraps a Cloudflare "record already exists" error.
+func isAlreadyExists(err error) bool {
+	var apiErr *CloudflareAPIError
+	return errors.As(err, &apiErr) && apiErr.alreadyExists()
+}
+
+// tagsRefusals are the phrases with which Cloudflare refuses record tags on plans without them, for
+// example "DNS record has 2 tags, exceeding the quota of 0".
+var tagsRefusals = []string{"exceeding the quota", "not available", "not supported", "not allowed"}
+
+// isTagsUnsupported reports whether err is Cloudflare refusing the tags field. Other errors that merely
+// mention tags, such as a malformed tag, are not.
+func isTagsUnsupported(err error) bool {
+	var apiErr *CloudflareAPIError
+	if !errors.As(err, &apiErr) || apiErr.retryable {
+		return false
+	}
+	message := apiErr.message()
+	if !strings.Contains(message, "tags") {
+		return false
+	}
+	for _, refusal := range tagsRefusals {
+		if strings.Contains(message, refusal) {
+			return true
 		}
-		errs = append(errs, fmt.Sprintf("%s: invalid ip %q", source, candidate))
 	}
-	return "", fmt.Errorf("all IP sources failed: %s", strings.Join(errs, "; "))
+	return false
+}
+
+// isSSLRejection reports whether Cloudflare refused a write because of the zone's SSL/TLS settings,
+// as it can when a record is switched between proxied and DNS-only.
+func isSSLRejection(err error) bool {
+	var apiErr *CloudflareAPIError
+	if !errors.As(err, &apiErr) || apiErr.retryable {
+		return false
+	}
+	message := apiErr.message()
+	return strings.Contains(message, "ssl") || strings.Contains(message, "certificate")
+}
+
+// isNotProxiable reports whether Cloudflare refused to proxy the record at all, for example for a
+// private address.
+func isNotProxiable(err error) bool {
+	var apiErr *CloudflareAPIError
+	if !errors.As(err, &apiErr) || apiErr.retryable {
+		return false
+	}
+	message := apiErr.message()
+	return strings.Contains(message, "cannot be proxied") || strings.Contains(message, "not proxiable")
+}
+
+// message is the lowercased response body and envelope messages, for matching errors without a stable code.
+func (e *CloudflareAPIError) message() string {
+	message := e.body
+	for _, err := range e.errs {
+		message += " " + err.Message
+	}
+	return strings.ToLower(message)
+}
+
+// isForbidden reports whether err wraps a Cloudflare permission error.
+func isForbidden(err error) bool {
+	var apiErr *CloudflareAPIError
+	return errors.As(err, &apiErr) && apiErr.IsAuth()
+}
+
+func errorCodes(errs []cfErr) []int {
+	codes := make([]int, 0, len(errs))
+	for _, e := range errs {
+		codes = append(codes, e.Code)
+	}
+	return codes
+}
+
+// envelopeCodes extracts error codes from a non-2xx body, which usually still carries the envelope.
+func envelopeCodes(raw []byte) []int {
+	var env cfEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil
+	}
+	return errorCodes(env.Errors)
+}
+
+// zoneIDFromPath returns the zone ID of a /zones/<id>/... API path, or "" for account-level calls.
+func zoneIDFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/zones/")
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
 }
 
 func bestZoneForDomain(domain string, zones []cfZone) *cfZone {
@@ -279,6 +546,53 @@ func bestZoneForDomain(domain string, zones []cfZone) *cfZone {
 	return best
 }
 
+// zonesInAccount keeps the zones owned by accountID, or all zones when accountID is empty.
+// The listing is already filtered server-side; this also guards zone resolution when duplicate
+// zone names exist across accounts.
+func zonesInAccount(zones []cfZone, accountID string) []cfZone {
+	if accountID == "" {
+		return zones
+	}
+	out := make([]cfZone, 0, len(zones))
+	for _, zone := range zones {
+		if zone.Account.ID == "" || zone.Account.ID == accountID {
+			out = append(out, zone)
+		}
+	}
+	return out
+}
+
+// duplicateZoneNames returns, sorted, the zone names that appear in more than one account.
+func duplicateZoneNames(zones []cfZone) []string {
+	accounts := make(map[string]map[string]struct{})
+	for _, zone := range zones {
+		name := strings.ToLower(strings.TrimSpace(zone.Name))
+		if accounts[name] == nil {
+			accounts[name] = make(map[string]struct{})
+		}
+		accounts[name][zone.Account.ID] = struct{}{}
+	}
+	var dups []string
+	for name, ids := range accounts {
+		if len(ids) > 1 {
+			dups = append(dups, name)
+		}
+	}
+	sort.Strings(dups)
+	return dups
+}
+
+// countAccounts returns how many distinct accounts own the given zones.
+func countAccounts(zones []cfZone) int {
+	seen := make(map[string]struct{})
+	for _, zone := range zones {
+		if zone.Account.ID != "" {
+			seen[zone.Account.ID] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
 func pickRecord(records []cfRecord) cfRecord {
 	if len(records) == 0 {
 		return cfRecord{}
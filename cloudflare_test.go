@@ -2,10 +2,14 @@ package ddns_traefik_plugin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -44,6 +48,44 @@ func TestResolvePublicIPv4Fallback(t *testing.T) {
 	}
 }
 
+func TestResolvePublicIPFamilySelection(t *testing.T) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	serverV6 := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("2001:db8::8\n"))
+	}))
+	defer serverV6.Close()
+	serverV4 := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("203.0.113.8\n"))
+	}))
+	defer serverV4.Close()
+	sources := []string{serverV6.URL, serverV4.URL}
+
+	cases := []struct {
+		family string
+		want   string
+	}{
+		{family: "v4", want: "203.0.113.8"},
+		{family: "v6", want: "2001:db8::8"},
+		{family: "any", want: "2001:db8::8"},
+	}
+	for _, tc := range cases {
+		got, err := resolvePublicIP(context.Background(), sources, tc.family, client)
+		if err != nil {
+			t.Fatalf("family=%s unexpected error: %v", tc.family, err)
+		}
+		if got != tc.want {
+			t.Fatalf("family=%s got %s, want %s", tc.family, got, tc.want)
+		}
+	}
+
+	if _, err := resolvePublicIP(context.Background(), []string{serverV4.URL}, "v6", client); err == nil {
+		t.Fatalf("expected v6 lookup against v4-only source to fail")
+	}
+	if _, err := resolvePublicIP(context.Background(), sources, "v5", client); err == nil {
+		t.Fatalf("expected unsupported family to fail")
+	}
+}
+
 func TestListARecordsFiltersExactName(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(http.StatusOK)
@@ -61,3 +103,200 @@ func TestListARecordsFiltersExactName(t *testing.T) {
 		t.Fatalf("unexpected records: %+v", records)
 	}
 }
+
+func TestListZonesAccountFilter(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query = req.URL.RawQuery
+		_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"1","name":"example.com","account":{"id":"acc-1","name":"main"}}]}`))
+	}))
+	defer server.Close()
+
+	client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(os.Stdout, "", 0))
+	client.baseURL = server.URL
+	client.accountID = "acc-1"
+	zones, err := client.listZones(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(query, "account.id=acc-1") {
+		t.Fatalf("expected account filter in query, got %q", query)
+	}
+	if len(zones) != 1 || zones[0].Account.ID != "acc-1" {
+		t.Fatalf("unexpected zones: %+v", zones)
+	}
+	if countAccounts([]cfZone{{Account: cfAccount{ID: "a"}}, {Account: cfAccount{ID: "b"}}, {Account: cfAccount{ID: "a"}}}) != 2 {
+		t.Fatalf("expected two distinct accounts")
+	}
+}
+
+func TestZoneIDFromPath(t *testing.T) {
+	cases := map[string]string{
+		"/zones/abc/dns_records?type=A": "abc",
+		"/zones/abc/custom_hostnames":   "abc",
+		"/zones?page=1":                 "",
+		"/accounts/1":                   "",
+	}
+	for path, want := range cases {
+		if got := zoneIDFromPath(path); got != want {
+			t.Errorf("zoneIDFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRecordTagsInPayload(t *testing.T) {
+	var payloads []map[string]interface{}
+	rejectTags := false
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var payload map[string]interface{}
+		_ = json.NewDecoder(req.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		if _, tagged := payload["tags"]; tagged && rejectTags {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"success":false,"errors":[{"code":1004,"message":"DNS record tags are not available on this plan"}]}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"success":true,"result":{"id":"r1"}}`))
+	}))
+	defer server.Close()
+
+	client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(io.Discard, "", 0))
+	client.baseURL = server.URL
+	client.clock = newFakeClock(time.Unix(0, 0))
+
+	if _, err := client.createARecord(context.Background(), "z1", "app.example.com", "203.0.113.8", false, autoTTL, "c"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, tagged := payloads[0]["tags"]; tagged {
+		t.Fatalf("expected tags to be omitted when unset, got %v", payloads[0])
+	}
+
+	client.recordTags = []string{"env:prod", "owner:ddns"}
+	payloads = nil
+	if _, err := client.updateARecord(context.Background(), "z1", "r1", "app.example.com", "203.0.113.8", false, autoTTL, "c"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	tags, _ := payloads[0]["tags"].([]interface{})
+	if len(tags) != 2 || tags[0] != "env:prod" || tags[1] != "owner:ddns" {
+		t.Fatalf("expected tags in payload, got %v", payloads[0])
+	}
+
+	rejectTags = true
+	payloads = nil
+	if _, err := client.createARecord(context.Background(), "z1", "b.example.com", "203.0.113.8", false, autoTTL, "c"); err != nil {
+		t.Fatalf("expected create to succeed without tags, got %v", err)
+	}
+	if !client.tagsDisabled {
+		t.Fatalf("expected tagging to be disabled after rejection")
+	}
+	if _, tagged := payloads[len(payloads)-1]["tags"]; tagged {
+		t.Fatalf("expected retry without tags, got %v", payloads[len(payloads)-1])
+	}
+}
+
+func TestIsTagsUnsupported(t *testing.T) {
+	cases := []struct {
+		message string
+		want    bool
+	}{
+		{"DNS record has 2 tags, exceeding the quota of 0.", true},
+		{"DNS record tags are not available on this plan", true},
+		{"Invalid tag name: tag names must not contain ':'", false},
+		{"Content for A record is invalid. Must be a valid IPv4 address", false},
+	}
+	for _, tc := range cases {
+		err := &CloudflareAPIError{StatusCode: http.StatusBadRequest, errs: []cfErr{{Code: 1004, Message: tc.message}}}
+		if got := isTagsUnsupported(err); got != tc.want {
+			t.Errorf("isTagsUnsupported(%q) = %v, want %v", tc.message, got, tc.want)
+		}
+	}
+}
+
+func TestDoRequestReturnsCloudflareAPIError(t *testing.T) {
+	cases := []struct {
+		status  int
+		body    string
+		message string
+		check   func(*CloudflareAPIError) bool
+	}{
+		{http.StatusOK, `{"success":false,"errors":[{"code":81053,"message":"record exists"}]}`,
+			"cloudflare API error: [{Code:81053 Message:record exists}]", (*CloudflareAPIError).IsConflict},
+		{http.StatusForbidden, `{"success":false,"errors":[{"code":10000,"message":"auth"}]}`,
+			`non-success status=403 body={"success":false,"errors":[{"code":10000,"message":"auth"}]}`, (*CloudflareAPIError).IsAuth},
+		{http.StatusTooManyRequests, `slow down`, "retryable status=429 body=slow down", (*CloudflareAPIError).IsRateLimited},
+	}
+	for _, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(tc.status)
+			_, _ = rw.Write([]byte(tc.body))
+		}))
+		client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(io.Discard, "", 0))
+		client.baseURL = server.URL
+		client.clock = newFakeClock(time.Unix(0, 0))
+		_, err := client.doRequest(context.Background(), http.MethodGet, "/zones", nil)
+		server.Close()
+
+		var apiErr *CloudflareAPIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("status %d: expected CloudflareAPIError, got %v", tc.status, err)
+		}
+		if apiErr.Error() != tc.message || err.Error() != "cloudflare request failed: "+tc.message {
+			t.Errorf("status %d: unexpected message %q", tc.status, err)
+		}
+		if !tc.check(apiErr) {
+			t.Errorf("status %d: predicate false for %+v", tc.status, apiErr)
+		}
+	}
+	conflict := &CloudflareAPIError{StatusCode: http.StatusBadRequest, Codes: []int{81057}}
+	if !conflict.Code(81057) || conflict.Code(9109) || conflict.IsAuth() || conflict.IsRateLimited() {
+		t.Fatalf("unexpected classification for %+v", conflict)
+	}
+}
+
+func TestListARecordsQueriesLowercaseName(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		query = req.URL.Query().Get("name")
+		_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"a","name":"app.example.com","type":"A","content":"203.0.113.7"}]}`))
+	}))
+	defer server.Close()
+
+	client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(io.Discard, "", 0))
+	client.baseURL = server.URL
+	records, err := client.listARecords(context.Background(), "zone", "App.Example.COM")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query != "app.example.com" || len(records) != 1 {
+		t.Fatalf("expected lowercase name query to match the record, got query %q records %+v", query, records)
+	}
+}
+
+func TestListZonesPartialPagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") != "1" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"success":false,"errors":[{"code":1000,"message":"bad page"}]}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"z1","name":"example.com"}],"result_info":{"page":1,"total_pages":2}}`))
+	}))
+	defer server.Close()
+
+	var logs strings.Builder
+	client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(&logs, "", 0))
+	client.baseURL = server.URL
+	client.clock = newFakeClock(time.Unix(0, 0))
+
+	if _, err := client.listZones(context.Background()); err == nil {
+		t.Fatalf("expected a failed page to fail the listing by default")
+	}
+	client.partialZones = true
+	zones, err := client.listZones(context.Background())
+	if err != nil || len(zones) != 1 || zones[0].ID != "z1" {
+		t.Fatalf("expected the zones of page 1, got %+v err=%v", zones, err)
+	}
+	if !strings.Contains(logs.String(), "[WARN] zone listing failed on page 2") {
+		t.Fatalf("expected an incomplete list warning, got:\n%s", logs.String())
+	}
+}
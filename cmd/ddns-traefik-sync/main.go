@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -12,18 +13,41 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-var hostCallPattern = regexp.MustCompile(`Host\(([^)]*)\)`)
-var backtickPattern = regexp.MustCompile("`([^`]+)`")
+// The argument may span lines, and whitespace may separate Host from its parenthesis.
+var hostCallPattern = regexp.MustCompile(`Host\s*\(([^)]*)\)`)
+
+// hostLiteralPattern matches backtick, double- and single-quoted host literals;
+// Traefik accepts all three in Host(...) depending on version and provider.
+var hostLiteralPattern = regexp.MustCompile("`([^`]+)`|\"([^\"]+)\"|'([^']+)'")
+
+// ruleEscapes undoes quote escaping left in rules serialized into labels or JSON strings,
+// for example Host(\`app.example.com\`), which would otherwise leave a backslash in the host.
+var ruleEscapes = strings.NewReplacer("\\`", "`", `\"`, `"`, `\'`, "'")
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = ""
+
+// defaultMinSyncIntervalSeconds is the fallback intFromEnv uses for MIN_SYNC_INTERVAL_SECONDS.
+const defaultMinSyncIntervalSeconds = 30
+
+// Supported values for --source-type / SOURCE_TYPE.
+const (
+	sourceTypeTraefik = "traefik"
+	sourceTypeIngress = "ingress"
+)
 
 var defaultIPSources = []string{
 	"https://api.ipify.org",
@@ -34,92 +58,453 @@ var defaultIPSources = []string{
 type config struct {
 	apiToken            string
 	zone                string
+	accountID           string
 	sourcePath          string
+	sourceType          string
+	entrypoints         []string
+	requireMiddleware   string
+	excludeDomains      map[string]struct{}
+	allowReservedTLDs   bool
+	autoWWW             bool
+	failOnEmpty         bool
+	hostDropThreshold   int
+	strictDiscovery     bool
 	syncIntervalSeconds int
 	requestTimeout      int
 	ipSources           []string
+	ipCommand           string
+	ipCommandTimeout    int
+	ipCommandRetries    int
+	verify              bool
+	export              bool
+	postSyncCommand     string
+	postSyncTimeout     int
 	defaultProxied      bool
 	managedComment      string
 }
 
+// String renders the effective configuration as an aligned table with the token masked.
+func (c config) String() string {
+	token := "(unset)"
+	if c.apiToken != "" {
+		token = "(redacted)"
+	}
+	excluded := make([]string, 0, len(c.excludeDomains))
+	for host := range c.excludeDomains {
+		excluded = append(excluded, host)
+	}
+	sort.Strings(excluded)
+
+	rows := [][2]interface{}{
+		{"CF_API_TOKEN", token},
+		{"CF_ZONE", c.zone},
+		{"CF_ACCOUNT_ID", c.accountID},
+		{"TRAEFIK_SOURCE", c.sourcePath},
+		{"SOURCE_TYPE", c.sourceType},
+		{"ENTRYPOINTS", c.entrypoints},
+		{"REQUIRE_MIDDLEWARE", c.requireMiddleware},
+		{"EXCLUDE_DOMAINS", excluded},
+		{"ALLOW_RESERVED_TLDS", c.allowReservedTLDs},
+		{"AUTO_WWW", c.autoWWW},
+		{"FAIL_ON_EMPTY", c.failOnEmpty},
+		{"HOST_DROP_THRESHOLD_PERCENT", c.hostDropThreshold},
+		{"STRICT_DISCOVERY", c.strictDiscovery},
+		{"SYNC_INTERVAL_SECONDS", c.syncIntervalSeconds},
+		{"REQUEST_TIMEOUT_SECONDS", c.requestTimeout},
+		{"IP_SOURCES", c.ipSources},
+		{"IP_COMMAND", c.ipCommand},
+		{"IP_COMMAND_TIMEOUT_SECONDS", c.ipCommandTimeout},
+		{"IP_COMMAND_RETRIES", c.ipCommandRetries},
+		{"POST_SYNC_COMMAND", c.postSyncCommand},
+		{"POST_SYNC_TIMEOUT_SECONDS", c.postSyncTimeout},
+		{"DEFAULT_PROXIED", c.defaultProxied},
+		{"MANAGED_COMMENT", c.managedComment},
+	}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %s\t%v\n", row[0], row[1])
+	}
+	_ = w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
 func main() {
-	cfg, err := loadConfig()
+	cfg, err := loadConfig(os.Args[1:])
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
 
 	logger := log.New(os.Stdout, "ddns-sync ", log.LstdFlags)
+	if cfg.verify || cfg.export {
+		// stdout carries the verify table or the export only.
+		logger.SetOutput(os.Stderr)
+	}
+	if floor := intFromEnv("MIN_SYNC_INTERVAL_SECONDS", defaultMinSyncIntervalSeconds); cfg.syncIntervalSeconds < floor {
+		logger.Printf("[WARN] SYNC_INTERVAL_SECONDS=%d is below the %ds minimum; using %ds", cfg.syncIntervalSeconds, floor, floor)
+		cfg.syncIntervalSeconds = floor
+	}
 	client := newCloudflareClient(cfg.apiToken, &http.Client{Timeout: time.Duration(cfg.requestTimeout) * time.Second}, logger)
+	client.accountID = cfg.accountID
+
+	if cfg.verify {
+		os.Exit(verifyRecords(context.Background(), cfg, client, os.Stdout))
+	}
+	if cfg.export {
+		if err := exportManaged(context.Background(), cfg, client, os.Stdout); err != nil {
+			logger.Fatalf("[ERROR] export failed: %v", err)
+		}
+		return
+	}
 
-	logger.Printf("starting source=%s interval=%ds", cfg.sourcePath, cfg.syncIntervalSeconds)
-	runCycle(context.Background(), cfg, client, logger)
+	logger.Printf("starting version=%s source=%s type=%s interval=%ds", buildVersion(), cfg.sourcePath, cfg.sourceType, cfg.syncIntervalSeconds)
+	logger.Printf("effective config:\n%s", cfg)
+	if cfg.failOnEmpty {
+		domains, err := discoverDomains(context.Background(), cfg, logger)
+		if err != nil {
+			logger.Fatalf("[ERROR] discover domains failed: %v", err)
+		}
+		if len(domains) == 0 {
+			logger.Fatalf("[ERROR] no domains discovered in %s (FAIL_ON_EMPTY is set)", cfg.sourcePath)
+		}
+	}
+	guard := &discoveryGuard{thresholdPercent: cfg.hostDropThreshold}
+	notifySystemd("READY=1", logger)
+	cycle(context.Background(), cfg, client, guard, logger)
 
 	ticker := time.NewTicker(time.Duration(cfg.syncIntervalSeconds) * time.Second)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		runCycle(context.Background(), cfg, client, logger)
+		cycle(context.Background(), cfg, client, guard, logger)
+	}
+}
+
+// cycle runs one sync cycle followed by the optional post-sync hook, then pings the systemd
+// watchdog when the cycle completed.
+func cycle(ctx context.Context, cfg config, cf *cloudflareClient, guard *discoveryGuard, logger *log.Logger) {
+	report := runCycle(ctx, cfg, cf, guard, logger)
+	if cfg.postSyncCommand != "" {
+		runPostSyncCommand(ctx, cfg.postSyncCommand, time.Duration(cfg.postSyncTimeout)*time.Second, report, logger)
+	}
+	if report.Error == "" {
+		notifySystemd("WATCHDOG=1", logger)
+	}
+}
+
+// notifySystemd sends state to the systemd notification socket and logs a failure; see sdNotify.
+func notifySystemd(state string, logger *log.Logger) {
+	if err := sdNotify(state); err != nil {
+		logger.Printf("[WARN] systemd notify %s failed: %v", state, err)
+	}
+}
+
+// sdNotify writes state to the datagram socket named by NOTIFY_SOCKET, as sd_notify(3) does.
+// A leading @ names an abstract socket, which the net package maps itself. It is a no-op when
+// NOTIFY_SOCKET is unset, so the CLI runs unchanged outside systemd.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// buildVersion reports the ldflags version, the VCS revision embedded by the Go toolchain, or "dev".
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+				return "dev+" + setting.Value[:7]
+			}
+		}
+	}
+	return "dev"
+}
+
+// cycleReport summarizes one sync cycle; it is the JSON document piped to POST_SYNC_COMMAND.
+type cycleReport struct {
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	IP         string         `json:"ip,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Domains    []domainResult `json:"domains"`
 }
 
-func runCycle(ctx context.Context, cfg config, cf *cloudflareClient, logger *log.Logger) {
-	domains, err := discoverDomains(cfg.sourcePath)
+// domainResult is the outcome for one domain: created, updated, unchanged, skipped or failed.
+type domainResult struct {
+	Domain string `json:"domain"`
+	Zone   string `json:"zone,omitempty"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+func runCycle(ctx context.Context, cfg config, cf *cloudflareClient, guard *discoveryGuard, logger *log.Logger) (report cycleReport) {
+	report = cycleReport{StartedAt: time.Now(), Domains: []domainResult{}}
+	defer func() { report.FinishedAt = time.Now() }()
+
+	domains, err := discoverDomains(ctx, cfg, logger)
 	if err != nil {
 		logger.Printf("[ERROR] discover domains failed: %v", err)
-		return
+		report.Error = "discover domains failed: " + err.Error()
+		return report
+	}
+	if err := guard.check(len(domains)); err != nil {
+		logger.Printf("[WARN] %v; skipping writes this cycle", err)
+		report.Error = err.Error()
+		return report
 	}
 	if len(domains) == 0 {
 		logger.Printf("[WARN] no HTTP Host(...) domains found")
-		return
+		return report
 	}
 
-	publicIP, err := resolvePublicIPv4(ctx, cfg.ipSources, cf.httpClient)
+	publicIP, err := resolveIP(ctx, cfg, cf)
 	if err != nil {
 		logger.Printf("[ERROR] public ip lookup failed: %v", err)
-		return
+		report.Error = "public ip lookup failed: " + err.Error()
+		return report
 	}
+	report.IP = publicIP
 
 	zones, err := cf.listZones(ctx)
 	if err != nil {
 		logger.Printf("[ERROR] list zones failed: %v", err)
-		return
+		report.Error = "list zones failed: " + err.Error()
+		return report
+	}
+	if cfg.autoWWW {
+		domains = withWWWAliases(domains, zones, cfg)
 	}
 
 	for _, domain := range domains {
-		zone := resolveZone(cfg.zone, domain, zones)
-		if zone == nil {
-			logger.Printf("[WARN] skip domain=%s no matching zone", domain)
-			continue
-		}
+		result := syncDomain(ctx, cfg, cf, logger, domain, publicIP, zones)
+		report.Domains = append(report.Domains, result)
+	}
+	return report
+}
+
+// resolveIP returns the current public IPv4 from IP_COMMAND or IP_SOURCES.
+func resolveIP(ctx context.Context, cfg config, cf *cloudflareClient) (string, error) {
+	if cfg.ipCommand != "" {
+		return resolveIPFromCommandWithRetries(ctx, cfg.ipCommand, time.Duration(cfg.ipCommandTimeout)*time.Second, cfg.ipCommandRetries, cf.logger)
+	}
+	return resolvePublicIPv4(ctx, cfg.ipSources, cf.httpClient)
+}
+
+// Exit codes of --verify.
+const (
+	verifyExitOK    = 0
+	verifyExitDrift = 1
+	verifyExitError = 2
+)
+
+// verifyRecords checks every managed host against the current IP without writing and prints a
+// HOST/STATUS/CURRENT table to out. It returns the process exit code.
+func verifyRecords(ctx context.Context, cfg config, cf *cloudflareClient, out io.Writer) int {
+	domains, err := discoverDomains(ctx, cfg, cf.logger)
+	if err != nil {
+		fmt.Fprintf(out, "ERROR discover domains failed: %v\n", err)
+		return verifyExitError
+	}
+	publicIP, err := resolveIP(ctx, cfg, cf)
+	if err != nil {
+		fmt.Fprintf(out, "ERROR public ip lookup failed: %v\n", err)
+		return verifyExitError
+	}
+	zones, err := cf.listZones(ctx)
+	if err != nil {
+		fmt.Fprintf(out, "ERROR list zones failed: %v\n", err)
+		return verifyExitError
+	}
+	if cfg.autoWWW {
+		domains = withWWWAliases(domains, zones, cfg)
+	}
 
-		records, err := cf.listARecords(ctx, zone.ID, domain)
+	code := verifyExitOK
+	drift := 0
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HOST\tSTATUS\tCURRENT\n")
+	for _, domain := range domains {
+		status, current := "OK", publicIP
+		zone, err := resolveZone(cfg.zone, domain, zones)
 		if err != nil {
-			logger.Printf("[ERROR] domain=%s list records failed: %v", domain, err)
-			continue
+			status, current = "DRIFT", err.Error()
+		} else if records, err := cf.listARecords(ctx, zone.ID, domain); err != nil {
+			status, current = "ERROR", err.Error()
+			code = verifyExitError
+		} else if !hasDesiredARecord(records, domain, publicIP) {
+			status, current = "DRIFT", "(none)"
+			if len(records) > 0 {
+				current = pickRecord(records).Content
+			}
 		}
-		if hasDesiredARecord(records, domain, publicIP) {
-			continue
+		if status == "DRIFT" {
+			drift++
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", domain, status, current)
+	}
+	_ = w.Flush()
+	fmt.Fprintf(out, "%d hosts checked against %s, %d drifted\n", len(domains), publicIP, drift)
+	if drift > 0 && code == verifyExitOK {
+		code = verifyExitDrift
+	}
+	return code
+}
 
-		if len(records) == 0 {
-			logger.Printf("[INFO] create A domain=%s ip=%s", domain, publicIP)
-			_, err := cf.createARecord(ctx, zone.ID, domain, publicIP, cfg.defaultProxied, cfg.managedComment)
-			if err != nil {
-				logger.Printf("[ERROR] create failed domain=%s: %v", domain, err)
-			}
+// exportedHost is the desired state of one host in the export document.
+type exportedHost struct {
+	Zone    string `yaml:"zone"`
+	Record  string `yaml:"record"`
+	Proxied bool   `yaml:"proxied"`
+	TTL     int    `yaml:"ttl"`
+	Comment string `yaml:"comment"`
+}
+
+// exportManaged writes the hosts this CLI manages as portable YAML to out, keyed by host with the zone and
+// desired record settings, so the intent can be backed up or migrated without the Traefik sources. It
+// never writes records and never includes the token. Hosts without a matching zone are left out with a warning.
+func exportManaged(ctx context.Context, cfg config, cf *cloudflareClient, out io.Writer) error {
+	domains, err := discoverDomains(ctx, cfg, cf.logger)
+	if err != nil {
+		return fmt.Errorf("discover domains failed: %w", err)
+	}
+	zones, err := cf.listZones(ctx)
+	if err != nil {
+		return fmt.Errorf("list zones failed: %w", err)
+	}
+	if cfg.autoWWW {
+		domains = withWWWAliases(domains, zones, cfg)
+	}
+	hosts := make(map[string]exportedHost, len(domains))
+	for _, domain := range domains {
+		zone, err := resolveZone(cfg.zone, domain, zones)
+		if err != nil {
+			cf.logger.Printf("[WARN] domain=%s not exported: %v", domain, err)
 			continue
 		}
+		hosts[domain] = exportedHost{Zone: zone.Name, Record: domain, Proxied: cfg.defaultProxied, TTL: 1, Comment: cfg.managedComment}
+	}
 
-		record := pickRecord(records)
-		logger.Printf("[INFO] update A domain=%s old=%s new=%s", domain, record.Content, publicIP)
-		_, err = cf.updateARecord(ctx, zone.ID, record.ID, domain, publicIP, record.Proxied, record.Comment)
-		if err != nil {
-			logger.Printf("[ERROR] update failed domain=%s: %v", domain, err)
+	fmt.Fprintf(out, "# managed hosts exported by ddns-traefik-sync %s\n", buildVersion())
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(map[string]interface{}{"hosts": hosts}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func syncDomain(ctx context.Context, cfg config, cf *cloudflareClient, logger *log.Logger, domain, publicIP string, zones []cfZone) domainResult {
+	result := domainResult{Domain: domain}
+	zone, err := resolveZone(cfg.zone, domain, zones)
+	if err != nil {
+		logger.Printf("[WARN] skip domain=%s: %v", domain, err)
+		result.Action = "skipped"
+		result.Error = err.Error()
+		return result
+	}
+	result.Zone = zone.Name
+
+	records, err := cf.listARecords(ctx, zone.ID, domain)
+	if err != nil {
+		logger.Printf("[ERROR] domain=%s list records failed: %v", domain, err)
+		result.Action = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	if hasDesiredARecord(records, domain, publicIP) {
+		result.Action = "unchanged"
+		return result
+	}
+
+	if len(records) == 0 {
+		logger.Printf("[INFO] create A domain=%s ip=%s", domain, publicIP)
+		result.Action = "created"
+		if _, err := cf.createARecord(ctx, zone.ID, domain, publicIP, cfg.defaultProxied, cfg.managedComment); err != nil {
+			logger.Printf("[ERROR] create failed domain=%s: %v", domain, err)
+			result.Action = "failed"
+			result.Error = err.Error()
 		}
+		return result
+	}
+
+	record := pickRecord(records)
+	logger.Printf("[INFO] update A domain=%s old=%s new=%s", domain, record.Content, publicIP)
+	result.Action = "updated"
+	if _, err := cf.updateARecord(ctx, zone.ID, record.ID, domain, publicIP, record.Proxied, record.Comment); err != nil {
+		logger.Printf("[ERROR] update failed domain=%s: %v", domain, err)
+		result.Action = "failed"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runPostSyncCommand pipes the cycle report as JSON to command (run with sh -c) and logs its output.
+// Failures are logged only; they never fail the cycle.
+func runPostSyncCommand(ctx context.Context, command string, timeout time.Duration, report cycleReport, logger *log.Logger) {
+	payload, err := json.Marshal(report)
+	if err != nil {
+		logger.Printf("[ERROR] post-sync command: encode report failed: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if out := strings.TrimSpace(output.String()); out != "" {
+		logger.Printf("[INFO] post-sync command output: %s", out)
+	}
+	if err != nil {
+		logger.Printf("[ERROR] post-sync command failed: %v", err)
 	}
 }
 
-func loadConfig() (config, error) {
+func loadConfig(args []string) (config, error) {
+	flags := flag.NewFlagSet("ddns-traefik-sync", flag.ContinueOnError)
+	sourceTypeFlag := flags.String("source-type", "", "source format: traefik (dynamic config) or ingress (Kubernetes Ingress YAML); env SOURCE_TYPE")
+	verifyFlag := flags.Bool("verify", false, "check once that every managed host points at the current IP, print an OK/DRIFT table and exit non-zero on drift; never writes")
+	if err := flags.Parse(args); err != nil {
+		return config{}, err
+	}
+	export := false
+	switch command := flags.Arg(0); command {
+	case "":
+	case "export":
+		export = true
+	default:
+		return config{}, fmt.Errorf("unknown command %q: expected export", command)
+	}
+
+	sourceType := strings.ToLower(strings.TrimSpace(*sourceTypeFlag))
+	if sourceType == "" {
+		sourceType = strings.ToLower(strings.TrimSpace(os.Getenv("SOURCE_TYPE")))
+	}
+	if sourceType == "" {
+		sourceType = sourceTypeTraefik
+	}
+	if sourceType != sourceTypeTraefik && sourceType != sourceTypeIngress {
+		return config{}, fmt.Errorf("unsupported source type %q: expected %s or %s", sourceType, sourceTypeTraefik, sourceTypeIngress)
+	}
+
 	apiToken := strings.TrimSpace(os.Getenv("CF_API_TOKEN"))
 	if apiToken == "" {
 		return config{}, errors.New("CF_API_TOKEN is required")
@@ -137,31 +522,77 @@ func loadConfig() (config, error) {
 		managedComment = "managed-by=ddns-traefik-sync"
 	}
 
-	ipSources := defaultIPSources
-	if raw := strings.TrimSpace(os.Getenv("IP_SOURCES")); raw != "" {
-		custom := make([]string, 0)
-		for _, entry := range strings.Split(raw, ",") {
-			if v := strings.TrimSpace(entry); v != "" {
-				custom = append(custom, v)
-			}
+	excludeDomains := make(map[string]struct{})
+	for _, entry := range listFromEnv("EXCLUDE_DOMAINS") {
+		if host := normalizeHost(entry); host != "" {
+			excludeDomains[host] = struct{}{}
 		}
-		if len(custom) > 0 {
-			ipSources = custom
+	}
+
+	ipSources := defaultIPSources
+	if custom := listFromEnv("IP_SOURCES"); len(custom) > 0 {
+		ipSources = custom
+		if boolFromEnv("APPEND_DEFAULT_IP_SOURCES", false) {
+			ipSources = withDefaultIPSources(custom)
 		}
 	}
+	// Running a shell command is opt-in twice: IP_COMMAND alone is rejected.
+	ipCommand := strings.TrimSpace(os.Getenv("IP_COMMAND"))
+	if ipCommand != "" && !boolFromEnv("ALLOW_IP_COMMAND", false) {
+		return config{}, errors.New("IP_COMMAND is set but ALLOW_IP_COMMAND is not true")
+	}
+	postSyncCommand := strings.TrimSpace(os.Getenv("POST_SYNC_COMMAND"))
+	if postSyncCommand != "" && !boolFromEnv("ALLOW_POST_SYNC_COMMAND", false) {
+		return config{}, errors.New("POST_SYNC_COMMAND is set but ALLOW_POST_SYNC_COMMAND is not true")
+	}
 
 	return config{
 		apiToken:            apiToken,
 		zone:                zone,
+		accountID:           strings.TrimSpace(os.Getenv("CF_ACCOUNT_ID")),
 		sourcePath:          sourcePath,
+		sourceType:          sourceType,
+		entrypoints:         listFromEnv("ENTRYPOINTS"),
+		requireMiddleware:   strings.TrimSpace(os.Getenv("REQUIRE_MIDDLEWARE")),
+		excludeDomains:      excludeDomains,
+		allowReservedTLDs:   boolFromEnv("ALLOW_RESERVED_TLDS", false),
+		autoWWW:             boolFromEnv("AUTO_WWW", false),
+		failOnEmpty:         boolFromEnv("FAIL_ON_EMPTY", false),
+		hostDropThreshold:   nonNegativeIntFromEnv("HOST_DROP_THRESHOLD_PERCENT", 50),
+		strictDiscovery:     boolFromEnv("STRICT_DISCOVERY", false),
 		syncIntervalSeconds: interval,
 		requestTimeout:      timeout,
 		ipSources:           ipSources,
+		ipCommand:           ipCommand,
+		ipCommandTimeout:    intFromEnv("IP_COMMAND_TIMEOUT_SECONDS", 10),
+		ipCommandRetries:    nonNegativeIntFromEnv("IP_COMMAND_RETRIES", 2),
+		verify:              *verifyFlag,
+		export:              export,
+		postSyncCommand:     postSyncCommand,
+		postSyncTimeout:     intFromEnv("POST_SYNC_TIMEOUT_SECONDS", 30),
 		defaultProxied:      defaultProxied,
 		managedComment:      managedComment,
 	}, nil
 }
 
+// withDefaultIPSources appends the built-in sources that are not already listed.
+func withDefaultIPSources(sources []string) []string {
+	out := append([]string(nil), sources...)
+	for _, source := range defaultIPSources {
+		listed := false
+		for _, existing := range out {
+			if existing == source {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			out = append(out, source)
+		}
+	}
+	return out
+}
+
 func intFromEnv(name string, fallback int) int {
 	raw := strings.TrimSpace(os.Getenv(name))
 	if raw == "" {
@@ -174,6 +605,25 @@ func intFromEnv(name string, fallback int) int {
 	return value
 }
 
+// nonNegativeIntFromEnv is intFromEnv for counts where 0 is a meaningful value.
+func nonNegativeIntFromEnv(name string, fallback int) int {
+	if strings.TrimSpace(os.Getenv(name)) == "0" {
+		return 0
+	}
+	return intFromEnv(name, fallback)
+}
+
+// listFromEnv splits a comma-separated variable, dropping empty entries.
+func listFromEnv(name string) []string {
+	var out []string
+	for _, entry := range strings.Split(os.Getenv(name), ",") {
+		if v := strings.TrimSpace(entry); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func boolFromEnv(name string, fallback bool) bool {
 	raw := strings.TrimSpace(strings.ToLower(os.Getenv(name)))
 	if raw == "" {
@@ -182,41 +632,200 @@ func boolFromEnv(name string, fallback bool) bool {
 	return raw == "1" || raw == "true" || raw == "yes" || raw == "on"
 }
 
-func discoverDomains(source string) ([]string, error) {
-	files, err := listYAMLFiles(source)
+// discoverDomains returns the hosts found in TRAEFIK_SOURCE. Unreadable and malformed files are
+// logged and skipped; a malformed file contributes the documents before the error. With
+// STRICT_DISCOVERY any such file fails discovery instead, with every problem in the error.
+func discoverDomains(ctx context.Context, cfg config, logger *log.Logger) ([]string, error) {
+	extract := func(doc map[string]interface{}) []string {
+		return extractHostsFromDocument(doc, cfg.entrypoints, cfg.requireMiddleware)
+	}
+	if cfg.sourceType == sourceTypeIngress {
+		extract = extractHostsFromIngress
+	}
+
+	sources, problems, err := readSources(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 	set := make(map[string]struct{})
 
-	for _, path := range files {
-		content, err := os.ReadFile(path)
-		if err != nil {
-			continue
-		}
-		dec := yaml.NewDecoder(bytes.NewReader(content))
+	for _, source := range sources {
+		dec := yaml.NewDecoder(bytes.NewReader(source.content))
 		for {
 			var doc map[string]interface{}
 			if err := dec.Decode(&doc); err != nil {
-				if errors.Is(err, io.EOF) {
-					break
+				if !errors.Is(err, io.EOF) {
+					problems = append(problems, fmt.Errorf("parse %s failed: %w", source.name, err))
 				}
 				break
 			}
-			for _, host := range extractHostsFromDocument(doc) {
+			for _, host := range extract(doc) {
 				set[host] = struct{}{}
 			}
 		}
 	}
+	if cfg.strictDiscovery && len(problems) > 0 {
+		return nil, fmt.Errorf("STRICT_DISCOVERY: %d source errors: %w", len(problems), errors.Join(problems...))
+	}
+	for _, problem := range problems {
+		logger.Printf("[WARN] %v", problem)
+	}
 
 	out := make([]string, 0, len(set))
 	for host := range set {
+		if _, skip := cfg.excludeDomains[host]; skip {
+			continue
+		}
+		if !cfg.allowReservedTLDs && hasReservedTLD(host) {
+			continue
+		}
 		out = append(out, host)
 	}
 	sort.Strings(out)
 	return out, nil
 }
 
+// reservedTLDs are special-use top-level domains that only appear in development and LAN setups and
+// can never be a Cloudflare zone.
+var reservedTLDs = map[string]struct{}{"localhost": {}, "test": {}, "invalid": {}, "example": {}, "local": {}}
+
+// hasReservedTLD reports whether host is, or ends in, a reserved TLD.
+func hasReservedTLD(host string) bool {
+	_, ok := reservedTLDs[host[strings.LastIndex(host, ".")+1:]]
+	return ok
+}
+
+// withWWWAliases adds www.<apex> for every discovered apex host (host equal to its zone name)
+// unless the alias is excluded or already discovered.
+func withWWWAliases(domains []string, zones []cfZone, cfg config) []string {
+	seen := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		seen[domain] = struct{}{}
+	}
+	out := domains
+	for _, domain := range domains {
+		zone, _ := resolveZone(cfg.zone, domain, zones)
+		if zone == nil || !strings.EqualFold(strings.TrimSpace(zone.Name), domain) {
+			continue
+		}
+		alias := "www." + domain
+		if _, ok := seen[alias]; ok {
+			continue
+		}
+		if _, ok := cfg.excludeDomains[alias]; ok {
+			continue
+		}
+		seen[alias] = struct{}{}
+		out = append(out, alias)
+	}
+	return out
+}
+
+// isSourceURL reports whether TRAEFIK_SOURCE names an HTTP config server rather than a path.
+func isSourceURL(source string) bool {
+	parsed, err := url.Parse(source)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// readSources returns the YAML content of TRAEFIK_SOURCE: one fetched body for a URL, or every
+// .yml/.yaml file for a path. Unreadable files are skipped and returned as problems; a failed
+// fetch is an error.
+func readSources(ctx context.Context, cfg config) ([]sourceContent, []error, error) {
+	if isSourceURL(cfg.sourcePath) {
+		content, err := fetchSource(ctx, cfg.sourcePath, time.Duration(cfg.requestTimeout)*time.Second)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []sourceContent{{name: cfg.sourcePath, content: content}}, nil, nil
+	}
+	files, err := listYAMLFiles(cfg.sourcePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	contents := make([]sourceContent, 0, len(files))
+	var problems []error
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("read %s failed: %w", path, err))
+			continue
+		}
+		contents = append(contents, sourceContent{name: path, content: content})
+	}
+	return contents, problems, nil
+}
+
+// sourceContent is one file or config server response read from TRAEFIK_SOURCE.
+type sourceContent struct {
+	name    string
+	content []byte
+}
+
+// discoveryGuard catches a host set that shrank by more than thresholdPercent since the last
+// trusted discovery, as happens when Traefik is mid-way through rewriting its config files.
+// A drop seen on two consecutive cycles is trusted; thresholdPercent 0 disables the guard.
+type discoveryGuard struct {
+	thresholdPercent int
+	previous         int
+	suspect          bool
+}
+
+// check records count and returns an error when it is a suspect drop.
+func (g *discoveryGuard) check(count int) error {
+	if g.thresholdPercent > 0 && g.previous > 0 && !g.suspect &&
+		(g.previous-count)*100 > g.previous*g.thresholdPercent {
+		g.suspect = true
+		return fmt.Errorf("discovered %d hosts, down from %d (more than %d%% fewer); discovery looks incomplete",
+			count, g.previous, g.thresholdPercent)
+	}
+	g.previous, g.suspect = count, false
+	return nil
+}
+
+// sourceRetryBackoff is the delay unit between config server fetch attempts.
+var sourceRetryBackoff = time.Second
+
+// fetchSource GETs YAML from a config server, retrying network errors, 429 and 5xx like doRequest.
+func fetchSource(ctx context.Context, source string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		var content []byte
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/yaml, text/yaml, text/plain, */*")
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+		} else {
+			content, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			switch {
+			case err != nil:
+				lastErr = err
+			case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+				lastErr = fmt.Errorf("retryable status=%d", resp.StatusCode)
+			case resp.StatusCode < 200 || resp.StatusCode >= 300:
+				return nil, fmt.Errorf("fetch %s: status=%d", source, resp.StatusCode)
+			default:
+				return content, nil
+			}
+		}
+		if attempt < 3 {
+			timer := time.NewTimer(time.Duration(attempt) * sourceRetryBackoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("fetch %s: %w", source, ctx.Err())
+			case <-timer.C:
+			}
+		}
+	}
+	return nil, fmt.Errorf("fetch %s: %w", source, lastErr)
+}
+
 func listYAMLFiles(source string) ([]string, error) {
 	info, err := os.Stat(source)
 	if err != nil {
@@ -242,7 +851,11 @@ func listYAMLFiles(source string) ([]string, error) {
 	return files, err
 }
 
-func extractHostsFromDocument(doc map[string]interface{}) []string {
+// extractHostsFromDocument collects hosts from http.routers.*.rule.
+// When entrypoints is non-empty, only routers bound to one of them contribute;
+// routers without entryPoints listen on all entrypoints and always pass.
+// When requireMiddleware is set, only routers whose middlewares list it contribute.
+func extractHostsFromDocument(doc map[string]interface{}, entrypoints []string, requireMiddleware string) []string {
 	out := make(map[string]struct{})
 	httpSection, ok := doc["http"].(map[string]interface{})
 	if !ok {
@@ -261,6 +874,9 @@ func extractHostsFromDocument(doc map[string]interface{}) []string {
 		if !ok {
 			continue
 		}
+		if !routerOnEntrypoints(router, entrypoints) || !routerUsesMiddleware(router, requireMiddleware) {
+			continue
+		}
 		for _, host := range extractHosts(rule) {
 			out[host] = struct{}{}
 		}
@@ -273,18 +889,99 @@ func extractHostsFromDocument(doc map[string]interface{}) []string {
 	return hosts
 }
 
+func routerOnEntrypoints(router map[string]interface{}, entrypoints []string) bool {
+	if len(entrypoints) == 0 {
+		return true
+	}
+	bound, _ := router["entryPoints"].([]interface{})
+	if len(bound) == 0 {
+		return true
+	}
+	for _, raw := range bound {
+		name, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		for _, want := range entrypoints {
+			if name == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routerUsesMiddleware reports whether the router's middlewares include name. A reference with a
+// provider suffix (ddns@file) matches a name without one; a name with a suffix must match exactly.
+func routerUsesMiddleware(router map[string]interface{}, name string) bool {
+	if name == "" {
+		return true
+	}
+	middlewares, _ := router["middlewares"].([]interface{})
+	for _, raw := range middlewares {
+		ref, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		ref = strings.TrimSpace(ref)
+		if ref == name || (!strings.Contains(name, "@") && strings.SplitN(ref, "@", 2)[0] == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractHostsFromIngress reads spec.rules[].host from a Kubernetes Ingress object.
+// List objects (kind: List) are unwrapped through their items.
+func extractHostsFromIngress(doc map[string]interface{}) []string {
+	out := make(map[string]struct{})
+	if items, ok := doc["items"].([]interface{}); ok {
+		for _, rawItem := range items {
+			item, ok := rawItem.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, host := range extractHostsFromIngress(item) {
+				out[host] = struct{}{}
+			}
+		}
+	}
+	if spec, ok := doc["spec"].(map[string]interface{}); ok {
+		rules, _ := spec["rules"].([]interface{})
+		for _, rawRule := range rules {
+			rule, ok := rawRule.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			host, ok := rule["host"].(string)
+			if !ok {
+				continue
+			}
+			if host = normalizeHost(host); host != "" {
+				out[host] = struct{}{}
+			}
+		}
+	}
+
+	hosts := make([]string, 0, len(out))
+	for h := range out {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
 func extractHosts(rule string) []string {
+	if strings.Contains(rule, `\`) {
+		rule = ruleEscapes.Replace(rule)
+	}
 	callMatches := hostCallPattern.FindAllStringSubmatch(rule, -1)
 	set := make(map[string]struct{})
 	for _, call := range callMatches {
 		if len(call) < 2 {
 			continue
 		}
-		for _, token := range backtickPattern.FindAllStringSubmatch(call[1], -1) {
-			if len(token) < 2 {
-				continue
-			}
-			host := normalizeHost(token[1])
+		for _, token := range hostLiteralPattern.FindAllStringSubmatch(call[1], -1) {
+			host := normalizeHost(token[1] + token[2] + token[3])
 			if host != "" {
 				set[host] = struct{}{}
 			}
@@ -309,6 +1006,7 @@ func normalizeHost(host string) string {
 type cloudflareClient struct {
 	baseURL    string
 	apiToken   string
+	accountID  string
 	httpClient *http.Client
 	logger     *log.Logger
 }
@@ -338,6 +1036,11 @@ type cfPager struct {
 	TotalPages int `json:"total_pages"`
 }
 type cfZone struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Account cfAccount `json:"account"`
+}
+type cfAccount struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
@@ -355,6 +1058,9 @@ func (c *cloudflareClient) listZones(ctx context.Context) ([]cfZone, error) {
 	page := 1
 	for {
 		path := fmt.Sprintf("/zones?page=%d&per_page=50", page)
+		if c.accountID != "" {
+			path += "&account.id=" + url.QueryEscape(c.accountID)
+		}
 		env, err := c.doRequest(ctx, http.MethodGet, path, nil)
 		if err != nil {
 			return nil, err
@@ -373,7 +1079,7 @@ func (c *cloudflareClient) listZones(ctx context.Context) ([]cfZone, error) {
 }
 
 func (c *cloudflareClient) listARecords(ctx context.Context, zoneID, host string) ([]cfRecord, error) {
-	path := fmt.Sprintf("/zones/%s/dns_records?type=A&name=%s&per_page=100", zoneID, url.QueryEscape(host))
+	path := fmt.Sprintf("/zones/%s/dns_records?type=A&name=%s&per_page=100", zoneID, url.QueryEscape(strings.ToLower(host)))
 	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
@@ -520,26 +1226,97 @@ func resolvePublicIPv4(ctx context.Context, sources []string, client *http.Clien
 			continue
 		}
 		candidate := strings.TrimSpace(string(raw))
+		// IPv4-mapped IPv6 answers (::ffff:203.0.113.8) are returned in dotted form for the A record.
 		if ip := net.ParseIP(candidate); ip != nil && ip.To4() != nil {
-			return candidate, nil
+			return ip.To4().String(), nil
 		}
 		errs = append(errs, "invalid IPv4")
 	}
 	return "", fmt.Errorf("ip lookup failed: %s", strings.Join(errs, "; "))
 }
 
-func resolveZone(zoneOverride, domain string, zones []cfZone) *cfZone {
+// ipCommandRetryBackoff is the delay unit between IP_COMMAND attempts.
+var ipCommandRetryBackoff = time.Second
+
+// resolveIPFromCommandWithRetries runs resolveIPFromCommand up to retries+1 times, so a flaky
+// vendor CLI gets another chance before the cycle gives up. Each attempt has its own timeout.
+func resolveIPFromCommandWithRetries(ctx context.Context, command string, timeout time.Duration, retries int, logger *log.Logger) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(time.Duration(attempt) * ipCommandRetryBackoff):
+			}
+		}
+		ip, err := resolveIPFromCommand(ctx, command, timeout)
+		if err == nil {
+			return ip, nil
+		}
+		lastErr = err
+		if attempt < retries {
+			logger.Printf("[WARN] ip command attempt %d/%d failed: %v", attempt+1, retries+1, err)
+		}
+	}
+	return "", lastErr
+}
+
+// resolveIPFromCommand runs command with sh -c and parses its trimmed stdout as the public IPv4.
+// stderr is included in the error so vendor tool failures are visible in the logs, and on timeout
+// so is whatever the command printed before it was killed.
+func resolveIPFromCommand(ctx context.Context, command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait on grandchildren that keep the output pipes open after the timeout kills sh.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("ip command timed out after %s: stdout=%q stderr=%q", timeout, strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()))
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return "", fmt.Errorf("ip command failed: %v stderr=%q", err, strings.TrimSpace(stderr.String()))
+	}
+	candidate := strings.TrimSpace(stdout.String())
+	ip := net.ParseIP(candidate)
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("ip command returned invalid IPv4 %q", candidate)
+	}
+	return ip.To4().String(), nil
+}
+
+// resolveZone picks the zone for domain. The error tells apart a CF_ZONE that the token
+// cannot see from a domain that is simply not under that zone.
+func resolveZone(zoneOverride, domain string, zones []cfZone) (*cfZone, error) {
 	if zoneOverride == "" {
-		return bestZoneForDomain(domain, zones)
+		if zone := bestZoneForDomain(domain, zones); zone != nil {
+			return zone, nil
+		}
+		return nil, fmt.Errorf("no zone visible to the token matches %s", domain)
 	}
 	target := strings.ToLower(strings.TrimSpace(zoneOverride))
 	for i := range zones {
-		zone := strings.ToLower(strings.TrimSpace(zones[i].Name))
-		if zone == target && (domain == zone || strings.HasSuffix(domain, "."+zone)) {
-			return &zones[i]
+		if strings.ToLower(strings.TrimSpace(zones[i].Name)) != target {
+			continue
+		}
+		if domain == target || strings.HasSuffix(domain, "."+target) {
+			// A child zone delegated from the configured one owns its names; records for them in the
+			// parent would be shadowed by the delegation and never answered.
+			if child := bestZoneForDomain(domain, zones); child != nil && !strings.EqualFold(strings.TrimSpace(child.Name), target) {
+				return nil, fmt.Errorf("%s belongs to delegated zone %s, not CF_ZONE %s", domain, child.Name, target)
+			}
+			return &zones[i], nil
 		}
+		return nil, fmt.Errorf("%s is not under CF_ZONE %s", domain, target)
 	}
-	return nil
+	return nil, fmt.Errorf("CF_ZONE %s not found among zones visible to the token (check CF_ZONE, token permissions and CF_ACCOUNT_ID)", target)
 }
 
 func bestZoneForDomain(domain string, zones []cfZone) *cfZone {
@@ -563,13 +1340,22 @@ func bestZoneForDomain(domain string, zones []cfZone) *cfZone {
 
 func hasDesiredARecord(records []cfRecord, domain, ip string) bool {
 	for _, r := range records {
-		if strings.EqualFold(r.Name, domain) && strings.EqualFold(r.Type, "A") && strings.TrimSpace(r.Content) == ip {
+		if strings.EqualFold(r.Name, domain) && strings.EqualFold(r.Type, "A") && sameIP(r.Content, ip) {
 			return true
 		}
 	}
 	return false
 }
 
+// sameIP compares record contents as addresses, so formatting differences never look like drift.
+func sameIP(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if ipA, ipB := net.ParseIP(a), net.ParseIP(b); ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return a == b
+}
+
 func pickRecord(records []cfRecord) cfRecord {
 	if len(records) == 0 {
 		return cfRecord{}
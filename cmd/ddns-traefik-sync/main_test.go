@@ -0,0 +1,701 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decodeDoc(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("invalid yaml: %v", err)
+	}
+	return doc
+}
+
+func TestExtractHostsQuoteStyles(t *testing.T) {
+	got := extractHosts("Host(\"a.example.com\") || Host('b.example.com') || Host(`c.example.com`)")
+	sort.Strings(got)
+	want := []string{"a.example.com", "b.example.com", "c.example.com"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("extractHosts = %v, want %v", got, want)
+	}
+}
+
+func TestExtractHostsMultiLineAndEscapedRules(t *testing.T) {
+	cases := map[string][]string{
+		"Host(\n  `a.example.com`,\n  `b.example.com`\n) &&\nPathPrefix(`/`)": {"a.example.com", "b.example.com"},
+		"Host (`a.example.com`) ||\r\n  Host(`b.example.com`)":                {"a.example.com", "b.example.com"},
+		"Host(\\`a.example.com\\`) || Host(\\\"b.example.com\\\")":            {"a.example.com", "b.example.com"},
+		"HostRegexp(`{sub:[a-z]+}.example.com`) || Host(`c.example.com`)":     {"c.example.com"},
+	}
+	for rule, want := range cases {
+		got := extractHosts(rule)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("extractHosts(%q) = %v, want %v", rule, got, want)
+		}
+	}
+}
+
+func TestExtractHostsFromDocumentEntrypoints(t *testing.T) {
+	doc := decodeDoc(t, `
+http:
+  routers:
+    public:
+      rule: Host(`+"`app.example.com`"+`)
+      entryPoints: [web, websecure]
+    private:
+      rule: Host(`+"`admin.example.com`"+`)
+      entryPoints: [internal]
+    default:
+      rule: Host(`+"`all.example.com`"+`)
+`)
+
+	cases := []struct {
+		entrypoints []string
+		want        []string
+	}{
+		{entrypoints: nil, want: []string{"admin.example.com", "all.example.com", "app.example.com"}},
+		{entrypoints: []string{"web"}, want: []string{"all.example.com", "app.example.com"}},
+		{entrypoints: []string{"internal"}, want: []string{"admin.example.com", "all.example.com"}},
+		{entrypoints: []string{"other"}, want: []string{"all.example.com"}},
+	}
+	for _, tc := range cases {
+		got := extractHostsFromDocument(doc, tc.entrypoints, "")
+		sort.Strings(got)
+		if len(got) != len(tc.want) {
+			t.Fatalf("entrypoints=%v got %v, want %v", tc.entrypoints, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("entrypoints=%v got %v, want %v", tc.entrypoints, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestExtractHostsFromDocumentRequireMiddleware(t *testing.T) {
+	doc := decodeDoc(t, `
+http:
+  routers:
+    opted-in:
+      rule: Host(`+"`app.example.com`"+`)
+      middlewares: [auth, ddns]
+    provider-ref:
+      rule: Host(`+"`api.example.com`"+`)
+      middlewares: [ddns@file]
+    other:
+      rule: Host(`+"`admin.example.com`"+`)
+      middlewares: [auth]
+    bare:
+      rule: Host(`+"`all.example.com`"+`)
+`)
+
+	cases := []struct {
+		middleware string
+		want       []string
+	}{
+		{middleware: "", want: []string{"admin.example.com", "all.example.com", "api.example.com", "app.example.com"}},
+		{middleware: "ddns", want: []string{"api.example.com", "app.example.com"}},
+		{middleware: "ddns@file", want: []string{"api.example.com"}},
+		{middleware: "missing", want: []string{}},
+	}
+	for _, tc := range cases {
+		got := extractHostsFromDocument(doc, nil, tc.middleware)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("requireMiddleware=%q got %v, want %v", tc.middleware, got, tc.want)
+		}
+	}
+}
+
+func TestConfigStringRedactsToken(t *testing.T) {
+	cfg := config{apiToken: "super-secret-token", syncIntervalSeconds: 300}
+	out := cfg.String()
+	if strings.Contains(out, "super-secret-token") || !strings.Contains(out, "(redacted)") {
+		t.Fatalf("token not masked:\n%s", out)
+	}
+	if !strings.Contains(out, "SYNC_INTERVAL_SECONDS") {
+		t.Fatalf("expected interval row:\n%s", out)
+	}
+}
+
+func TestResolveIPFromCommand(t *testing.T) {
+	ip, err := resolveIPFromCommand(context.Background(), "echo ' 203.0.113.7 '", 2*time.Second)
+	if err != nil || ip != "203.0.113.7" {
+		t.Fatalf("expected 203.0.113.7, got %q err=%v", ip, err)
+	}
+
+	_, err = resolveIPFromCommand(context.Background(), "echo vendor-tool exploded >&2; exit 3", 2*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "vendor-tool exploded") {
+		t.Fatalf("expected stderr in error, got %v", err)
+	}
+
+	if _, err := resolveIPFromCommand(context.Background(), "echo not-an-ip", 2*time.Second); err == nil {
+		t.Fatalf("expected invalid output to be rejected")
+	}
+	if _, err := resolveIPFromCommand(context.Background(), "sleep 5", 50*time.Millisecond); err == nil {
+		t.Fatalf("expected timeout")
+	}
+}
+
+func TestResolvePublicIPv4UnwrapsMappedAnswers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("::ffff:203.0.113.8\n"))
+	}))
+	defer server.Close()
+
+	got, err := resolvePublicIPv4(context.Background(), []string{server.URL}, &http.Client{Timeout: 2 * time.Second})
+	if err != nil || got != "203.0.113.8" {
+		t.Fatalf("expected the dotted IPv4 form, got %q err=%v", got, err)
+	}
+}
+
+func TestResolveIPFromCommandRetriesFlakyCommand(t *testing.T) {
+	orig := ipCommandRetryBackoff
+	ipCommandRetryBackoff = 0
+	t.Cleanup(func() { ipCommandRetryBackoff = orig })
+
+	// The script fails on its first run and succeeds once its marker file exists.
+	marker := filepath.Join(t.TempDir(), "ran")
+	command := fmt.Sprintf("if [ -f %[1]s ]; then echo 203.0.113.9; else touch %[1]s; echo flaky >&2; exit 1; fi", marker)
+	var logs bytes.Buffer
+	ip, err := resolveIPFromCommandWithRetries(context.Background(), command, 2*time.Second, 2, log.New(&logs, "", 0))
+	if err != nil || ip != "203.0.113.9" {
+		t.Fatalf("expected retry to succeed, got %q err=%v", ip, err)
+	}
+	if !strings.Contains(logs.String(), "attempt 1/3 failed") || !strings.Contains(logs.String(), "flaky") {
+		t.Fatalf("expected failed attempt to be logged, got %q", logs.String())
+	}
+
+	if err := os.Remove(marker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveIPFromCommandWithRetries(context.Background(), command, 2*time.Second, 0, log.New(io.Discard, "", 0)); err == nil {
+		t.Fatalf("expected failure without retries")
+	}
+}
+
+func TestResolveIPFromCommandTimeoutKeepsPartialOutput(t *testing.T) {
+	_, err := resolveIPFromCommand(context.Background(), "echo half-written; echo stuck >&2; sleep 5", 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "half-written") || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("expected timeout error with partial output, got %v", err)
+	}
+}
+
+func TestLoadConfigIPCommandBudget(t *testing.T) {
+	t.Setenv("CF_API_TOKEN", "token")
+	t.Setenv("IP_COMMAND", "echo 203.0.113.7")
+	t.Setenv("ALLOW_IP_COMMAND", "true")
+	t.Setenv("REQUEST_TIMEOUT_SECONDS", "3")
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ipCommandTimeout != 10 || cfg.ipCommandRetries != 2 {
+		t.Fatalf("expected independent defaults, got timeout=%d retries=%d", cfg.ipCommandTimeout, cfg.ipCommandRetries)
+	}
+
+	t.Setenv("IP_COMMAND_TIMEOUT_SECONDS", "45")
+	t.Setenv("IP_COMMAND_RETRIES", "0")
+	cfg, err = loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ipCommandTimeout != 45 || cfg.ipCommandRetries != 0 {
+		t.Fatalf("expected timeout=45 retries=0, got timeout=%d retries=%d", cfg.ipCommandTimeout, cfg.ipCommandRetries)
+	}
+}
+
+func TestLoadConfigRequiresAllowIPCommand(t *testing.T) {
+	t.Setenv("CF_API_TOKEN", "token")
+	t.Setenv("IP_COMMAND", "echo 203.0.113.7")
+	if _, err := loadConfig(nil); err == nil {
+		t.Fatalf("expected IP_COMMAND without ALLOW_IP_COMMAND to be rejected")
+	}
+	t.Setenv("ALLOW_IP_COMMAND", "true")
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if cfg.ipCommand != "echo 203.0.113.7" {
+		t.Fatalf("unexpected ipCommand %q", cfg.ipCommand)
+	}
+}
+
+func TestRunPostSyncCommandReceivesReport(t *testing.T) {
+	dir := t.TempDir()
+	out := dir + "/report.json"
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	report := cycleReport{IP: "203.0.113.7", Domains: []domainResult{{Domain: "app.example.com", Zone: "example.com", Action: "updated"}}}
+
+	runPostSyncCommand(context.Background(), "cat > "+out+"; echo warmed", 2*time.Second, report, logger)
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	var got cycleReport
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("hook stdin is not a JSON report: %v", err)
+	}
+	if got.IP != "203.0.113.7" || len(got.Domains) != 1 || got.Domains[0].Action != "updated" {
+		t.Fatalf("unexpected report on stdin: %+v", got)
+	}
+	if !strings.Contains(logs.String(), "warmed") {
+		t.Fatalf("expected hook output in logs, got %q", logs.String())
+	}
+
+	logs.Reset()
+	runPostSyncCommand(context.Background(), "echo boom >&2; exit 1", 2*time.Second, report, logger)
+	if !strings.Contains(logs.String(), "boom") || !strings.Contains(logs.String(), "post-sync command failed") {
+		t.Fatalf("expected failure to be logged with output, got %q", logs.String())
+	}
+}
+
+func TestLoadConfigRequiresAllowPostSyncCommand(t *testing.T) {
+	t.Setenv("CF_API_TOKEN", "token")
+	t.Setenv("POST_SYNC_COMMAND", "true")
+	if _, err := loadConfig(nil); err == nil {
+		t.Fatalf("expected POST_SYNC_COMMAND without ALLOW_POST_SYNC_COMMAND to be rejected")
+	}
+	t.Setenv("ALLOW_POST_SYNC_COMMAND", "true")
+	if _, err := loadConfig(nil); err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+}
+
+func TestResolveZoneMismatchDiagnostics(t *testing.T) {
+	zones := []cfZone{{ID: "z1", Name: "example.com"}}
+
+	if _, err := resolveZone("example.com", "app.example.org", zones); err == nil || !strings.Contains(err.Error(), "not under CF_ZONE example.com") {
+		t.Fatalf("expected domain-not-under-zone error, got %v", err)
+	}
+	if _, err := resolveZone("exmaple.com", "app.example.com", zones); err == nil || !strings.Contains(err.Error(), "CF_ZONE exmaple.com not found") {
+		t.Fatalf("expected zone-not-found error, got %v", err)
+	}
+}
+
+func TestResolveZoneDelegatedSubdomain(t *testing.T) {
+	zones := []cfZone{{ID: "z1", Name: "example.com"}, {ID: "z2", Name: "sub.example.com"}}
+
+	for _, domain := range []string{"sub.example.com", "app.sub.example.com"} {
+		if zone, err := resolveZone("", domain, zones); err != nil || zone.ID != "z2" {
+			t.Fatalf("%s: expected the delegated zone, got %+v err=%v", domain, zone, err)
+		}
+		_, err := resolveZone("example.com", domain, zones)
+		if err == nil || !strings.Contains(err.Error(), "belongs to delegated zone sub.example.com, not CF_ZONE example.com") {
+			t.Fatalf("%s: expected a delegated zone error, got %v", domain, err)
+		}
+	}
+	if zone, err := resolveZone("example.com", "www.example.com", zones); err != nil || zone.ID != "z1" {
+		t.Fatalf("expected the parent zone for its own names, got %+v err=%v", zone, err)
+	}
+}
+
+func TestLoadConfigAppendDefaultIPSources(t *testing.T) {
+	t.Setenv("CF_API_TOKEN", "token")
+	t.Setenv("IP_SOURCES", "https://ip.internal")
+	t.Setenv("APPEND_DEFAULT_IP_SOURCES", "true")
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if cfg.ipSources[0] != "https://ip.internal" || len(cfg.ipSources) != len(defaultIPSources)+1 {
+		t.Fatalf("expected custom source followed by defaults, got %v", cfg.ipSources)
+	}
+}
+
+func TestVerifyRecordsReportsDrift(t *testing.T) {
+	dir := t.TempDir()
+	rules := "http:\n  routers:\n    a:\n      rule: Host(`ok.example.com`)\n    b:\n      rule: Host(`stale.example.com`)\n"
+	if err := os.WriteFile(dir+"/dynamic.yml", []byte(rules), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	ipServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("203.0.113.7"))
+	}))
+	defer ipServer.Close()
+	var writes int
+	cfServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			writes++
+		}
+		switch {
+		case req.URL.Path == "/zones":
+			_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"z1","name":"example.com"}]}`))
+		case req.URL.Query().Get("name") == "ok.example.com":
+			_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"r1","name":"ok.example.com","type":"A","content":"203.0.113.7"}]}`))
+		default:
+			_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"r2","name":"stale.example.com","type":"A","content":"198.51.100.1"}]}`))
+		}
+	}))
+	defer cfServer.Close()
+
+	client := newCloudflareClient("token", &http.Client{Timeout: 2 * time.Second}, log.New(io.Discard, "", 0))
+	client.baseURL = cfServer.URL
+	cfg := config{sourcePath: dir, sourceType: sourceTypeTraefik, ipSources: []string{ipServer.URL}, requestTimeout: 2}
+
+	var out bytes.Buffer
+	code := verifyRecords(context.Background(), cfg, client, &out)
+	if code != verifyExitDrift {
+		t.Fatalf("expected drift exit code, got %d\n%s", code, out.String())
+	}
+	if writes != 0 {
+		t.Fatalf("verify must not write, got %d writes", writes)
+	}
+	report := out.String()
+	for _, want := range []string{"ok.example.com     OK", "stale.example.com  DRIFT   198.51.100.1", "1 drifted"} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("expected %q in report:\n%s", want, report)
+		}
+	}
+}
+
+func TestExportManagedWritesPortableYAML(t *testing.T) {
+	dir := t.TempDir()
+	rules := "http:\n  routers:\n    a:\n      rule: Host(`b.example.com`) || Host(`a.example.com`)\n    b:\n      rule: Host(`other.org`)\n"
+	if err := os.WriteFile(dir+"/dynamic.yml", []byte(rules), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	var writes int
+	cfServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			writes++
+		}
+		_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"z1","name":"example.com"}]}`))
+	}))
+	defer cfServer.Close()
+
+	client := newCloudflareClient("secret-token", &http.Client{Timeout: 2 * time.Second}, log.New(io.Discard, "", 0))
+	client.baseURL = cfServer.URL
+	cfg := config{apiToken: "secret-token", sourcePath: dir, sourceType: sourceTypeTraefik, requestTimeout: 2, defaultProxied: true, managedComment: "managed-by=ddns-traefik-sync"}
+
+	var out bytes.Buffer
+	if err := exportManaged(context.Background(), cfg, client, &out); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	want := "# managed hosts exported by ddns-traefik-sync " + buildVersion() + "\n" +
+		"hosts:\n" +
+		"  a.example.com:\n    zone: example.com\n    record: a.example.com\n    proxied: true\n    ttl: 1\n    comment: managed-by=ddns-traefik-sync\n" +
+		"  b.example.com:\n    zone: example.com\n    record: b.example.com\n    proxied: true\n    ttl: 1\n    comment: managed-by=ddns-traefik-sync\n"
+	if out.String() != want {
+		t.Fatalf("unexpected export:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if writes != 0 || strings.Contains(out.String(), "secret-token") {
+		t.Fatalf("export must not write or include the token (writes=%d)", writes)
+	}
+}
+
+func TestLoadConfigExportCommand(t *testing.T) {
+	t.Setenv("CF_API_TOKEN", "token")
+	cfg, err := loadConfig([]string{"export"})
+	if err != nil || !cfg.export {
+		t.Fatalf("expected export command to be parsed, got %+v err=%v", cfg.export, err)
+	}
+	if _, err := loadConfig([]string{"backup"}); err == nil {
+		t.Fatalf("expected unknown command to be rejected")
+	}
+}
+
+func TestHasDesiredARecordComparesAddresses(t *testing.T) {
+	records := []cfRecord{{Name: "app.example.com", Type: "A", Content: "::ffff:203.0.113.8 "}}
+	if !hasDesiredARecord(records, "app.example.com", "203.0.113.8") {
+		t.Fatalf("expected equal addresses in different formats to match")
+	}
+	if hasDesiredARecord(records, "app.example.com", "203.0.113.9") {
+		t.Fatalf("expected different addresses not to match")
+	}
+}
+
+func TestDiscoverDomainsFromURLSource(t *testing.T) {
+	old := sourceRetryBackoff
+	sourceRetryBackoff = time.Millisecond
+	defer func() { sourceRetryBackoff = old }()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempts++
+		if attempts == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte("http:\n  routers:\n    a:\n      rule: Host(`a.example.com`)\n" +
+			"---\nhttp:\n  routers:\n    b:\n      rule: Host(`b.example.com`)\n"))
+	}))
+	defer server.Close()
+
+	cfg := config{sourcePath: server.URL + "/dynamic.yml", sourceType: sourceTypeTraefik, requestTimeout: 2}
+	domains, err := discoverDomains(context.Background(), cfg, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("discoverDomains failed: %v", err)
+	}
+	if strings.Join(domains, ",") != "a.example.com,b.example.com" || attempts != 2 {
+		t.Fatalf("expected both documents after one retry, got %v after %d attempts", domains, attempts)
+	}
+
+	missing := config{sourcePath: server.URL + "/gone", sourceType: sourceTypeTraefik, requestTimeout: 2}
+	server.Config.Handler = http.NotFoundHandler()
+	if _, err := discoverDomains(context.Background(), missing, log.New(io.Discard, "", 0)); err == nil {
+		t.Fatalf("expected a failed fetch to be an error")
+	}
+}
+
+func TestFetchSourceStopsRetryingWhenCanceled(t *testing.T) {
+	old := sourceRetryBackoff
+	sourceRetryBackoff = time.Hour
+	defer func() { sourceRetryBackoff = old }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		cancel()
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := fetchSource(ctx, server.URL, 2*time.Second)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the fetch to stop with context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a canceled fetch to stop waiting out the retry backoff")
+	}
+}
+
+func TestDiscoverDomainsLogsMalformedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"good.yml":   "http:\n  routers:\n    a:\n      rule: Host(`a.example.com`)\n",
+		"broken.yml": "http:\n  routers:\n    b:\n      rule: Host(`b.example.com`)\n---\nhttp: [unclosed\n",
+		"empty.yml":  "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var logs bytes.Buffer
+	domains, err := discoverDomains(context.Background(), config{sourcePath: dir, sourceType: sourceTypeTraefik}, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("discoverDomains failed: %v", err)
+	}
+	if strings.Join(domains, ",") != "a.example.com,b.example.com" {
+		t.Fatalf("expected hosts before the parse error to be kept, got %v", domains)
+	}
+	if !strings.Contains(logs.String(), "parse "+filepath.Join(dir, "broken.yml")+" failed") {
+		t.Fatalf("expected parse error to be logged, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "empty.yml") {
+		t.Fatalf("expected an empty file not to be reported as a parse error, got %q", logs.String())
+	}
+}
+
+func TestDiscoverDomainsFromIngressDocuments(t *testing.T) {
+	dir := t.TempDir()
+	manifests := `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+    - host: App.Example.com
+    - http:
+        paths: []
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: default-backend
+spec:
+  defaultBackend:
+    service:
+      name: fallback
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  host: ignored.example.com
+---
+apiVersion: v1
+kind: List
+items:
+  - kind: Ingress
+    spec:
+      rules:
+        - host: api.example.com
+        - host: app.example.com
+  - kind: Ingress
+    spec: {}
+`
+	if err := os.WriteFile(filepath.Join(dir, "ingress.yml"), []byte(manifests), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	cfg := config{sourcePath: dir, sourceType: sourceTypeIngress}
+	domains, err := discoverDomains(context.Background(), cfg, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("discoverDomains failed: %v", err)
+	}
+	if strings.Join(domains, ",") != "api.example.com,app.example.com" {
+		t.Fatalf("expected hosts from every Ingress document, got %v", domains)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("expected Ingresses without rules to be skipped quietly, got %q", logs.String())
+	}
+}
+
+func TestExtractHostsFromIngressWithoutRules(t *testing.T) {
+	for _, doc := range []map[string]interface{}{
+		{},
+		{"kind": "Ingress"},
+		{"kind": "Ingress", "spec": map[string]interface{}{}},
+		{"kind": "Ingress", "spec": map[string]interface{}{"rules": "not-a-list"}},
+		{"kind": "Ingress", "spec": map[string]interface{}{"rules": []interface{}{"not-a-rule", map[string]interface{}{"host": 42}}}},
+	} {
+		if hosts := extractHostsFromIngress(doc); len(hosts) != 0 {
+			t.Errorf("extractHostsFromIngress(%v) = %v, want no hosts", doc, hosts)
+		}
+	}
+}
+
+func TestDiscoverDomainsDropsReservedTLDs(t *testing.T) {
+	dir := t.TempDir()
+	rules := "http:\n  routers:\n    a:\n      rule: Host(`app.example.com`) || Host(`app.localhost`) || Host(`api.test`) || Host(`nas.local`)\n"
+	if err := os.WriteFile(filepath.Join(dir, "dynamic.yml"), []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{sourcePath: dir, sourceType: sourceTypeTraefik}
+	domains, err := discoverDomains(context.Background(), cfg, log.New(io.Discard, "", 0))
+	if err != nil || strings.Join(domains, ",") != "app.example.com" {
+		t.Fatalf("expected reserved TLDs to be dropped, got %v err=%v", domains, err)
+	}
+
+	cfg.allowReservedTLDs = true
+	if domains, _ := discoverDomains(context.Background(), cfg, log.New(io.Discard, "", 0)); len(domains) != 4 {
+		t.Fatalf("expected ALLOW_RESERVED_TLDS to keep every host, got %v", domains)
+	}
+}
+
+func TestStrictDiscoveryFailsOnAnyBadFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"good.yml":    "http:\n  routers:\n    a:\n      rule: Host(`a.example.com`)\n",
+		"broken.yml":  "http: [unclosed\n",
+		"broken2.yml": "http:\n\t- tab\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config{sourcePath: dir, sourceType: sourceTypeTraefik}
+	if domains, err := discoverDomains(context.Background(), cfg, log.New(io.Discard, "", 0)); err != nil || len(domains) != 1 {
+		t.Fatalf("expected lenient discovery to keep the good file, got %v err=%v", domains, err)
+	}
+
+	cfg.strictDiscovery = true
+	_, err := discoverDomains(context.Background(), cfg, log.New(io.Discard, "", 0))
+	if err == nil || !strings.Contains(err.Error(), "2 source errors") ||
+		!strings.Contains(err.Error(), "broken.yml") || !strings.Contains(err.Error(), "broken2.yml") {
+		t.Fatalf("expected every problem in the strict error, got %v", err)
+	}
+}
+
+func TestDiscoveryGuardSkipsSuspectDrop(t *testing.T) {
+	guard := &discoveryGuard{thresholdPercent: 50}
+	for i, tc := range []struct {
+		count   int
+		suspect bool
+	}{
+		{10, false},
+		{6, false},
+		{2, true},  // 67% fewer than 6
+		{6, false}, // recovered
+		{0, true},
+		{0, false}, // the drop persisted, so it is trusted
+		{3, false},
+	} {
+		if err := guard.check(tc.count); (err != nil) != tc.suspect {
+			t.Fatalf("step %d count=%d: expected suspect=%t, got %v", i, tc.count, tc.suspect, err)
+		}
+	}
+
+	disabled := &discoveryGuard{}
+	if disabled.check(10) != nil || disabled.check(0) != nil {
+		t.Fatalf("expected threshold 0 to disable the guard")
+	}
+}
+
+func TestIsSourceURL(t *testing.T) {
+	for source, want := range map[string]bool{
+		"https://config.internal/traefik": true,
+		"http://10.0.0.5:8080/dynamic":    true,
+		"/configs":                        false,
+		"configs/dynamic.yml":             false,
+		"file:///configs":                 false,
+	} {
+		if got := isSourceURL(source); got != want {
+			t.Errorf("isSourceURL(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestSDNotifyWritesToNotifySocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("expected a no-op without NOTIFY_SOCKET, got %v", err)
+	}
+
+	// Keep the path short: unix socket paths are limited to about 100 bytes.
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	for _, state := range []string{"READY=1", "WATCHDOG=1"} {
+		if err := sdNotify(state); err != nil {
+			t.Fatalf("sdNotify(%s) failed: %v", state, err)
+		}
+		buf := make([]byte, 64)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil || string(buf[:n]) != state {
+			t.Fatalf("expected %q on the socket, got %q (%v)", state, buf[:n], err)
+		}
+	}
+}
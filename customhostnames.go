@@ -0,0 +1,103 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Cloudflare for SaaS domain control validation methods accepted for custom hostnames.
+var customHostnameSSLMethods = []string{"http", "txt", "email"}
+
+type cfCustomHostname struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	Status   string `json:"status"`
+}
+
+func (c *cloudflareClient) listCustomHostnames(ctx context.Context, zoneID, hostname string) ([]cfCustomHostname, error) {
+	path := fmt.Sprintf("/zones/%s/custom_hostnames?hostname=%s&per_page=50", zoneID, url.QueryEscape(hostname))
+	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var hostnames []cfCustomHostname
+	if err := json.Unmarshal(env.Result, &hostnames); err != nil {
+		return nil, fmt.Errorf("invalid custom hostnames payload: %w", err)
+	}
+	return hostnames, nil
+}
+
+func (c *cloudflareClient) createCustomHostname(ctx context.Context, zoneID, hostname, sslMethod string) (*cfCustomHostname, error) {
+	payload := map[string]interface{}{
+		"hostname": hostname,
+		"ssl": map[string]interface{}{
+			"method": sslMethod,
+			"type":   "dv",
+		},
+	}
+	path := fmt.Sprintf("/zones/%s/custom_hostnames", zoneID)
+	env, err := c.doRequest(ctx, http.MethodPost, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	var created cfCustomHostname
+	if err := json.Unmarshal(env.Result, &created); err != nil {
+		return nil, fmt.Errorf("invalid create custom hostname payload: %w", err)
+	}
+	return &created, nil
+}
+
+// syncCustomHostnames provisions every host as a custom hostname of the configured SaaS zone.
+// It replaces the A-record path entirely: no public IP lookup and no DNS record writes.
+func (r *Runner) syncCustomHostnames(ctx context.Context, hosts []string) {
+	zones, err := r.client.listZones(ctx)
+	if err != nil {
+		r.errorf("failed listing zones: %v", err)
+		return
+	}
+	var saasZone *cfZone
+	for i := range zones {
+		if strings.EqualFold(strings.TrimSpace(zones[i].Name), strings.TrimSpace(r.cfg.Zone)) {
+			saasZone = &zones[i]
+			break
+		}
+	}
+	if saasZone == nil {
+		r.errorf("custom hostname zone %q not found", r.cfg.Zone)
+		return
+	}
+
+	for _, host := range hosts {
+		existing, err := r.client.listCustomHostnames(ctx, saasZone.ID, host)
+		if err != nil {
+			r.errorf("domain=%s custom hostname lookup failed: %v", host, err)
+			continue
+		}
+		if hasCustomHostname(existing, host) {
+			r.debugf("domain=%s custom hostname already provisioned", host)
+			continue
+		}
+		if r.writesHeld {
+			r.planChange(plannedChange{Action: planCreate, Name: host, Kind: "custom hostname"},
+				"would create custom hostname domain=%s zone=%s ssl=%s", host, saasZone.Name, r.cfg.CustomHostnameSSLMethod)
+			continue
+		}
+		r.infof("create custom hostname domain=%s zone=%s ssl=%s", host, saasZone.Name, r.cfg.CustomHostnameSSLMethod)
+		if _, err := r.client.createCustomHostname(ctx, saasZone.ID, host, r.cfg.CustomHostnameSSLMethod); err != nil {
+			r.errorf("domain=%s custom hostname create failed: %v", host, err)
+		}
+	}
+}
+
+func hasCustomHostname(hostnames []cfCustomHostname, host string) bool {
+	for _, h := range hostnames {
+		if strings.EqualFold(h.Hostname, host) {
+			return true
+		}
+	}
+	return false
+}
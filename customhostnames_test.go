@@ -0,0 +1,124 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSyncCustomHostnamesCreatesMissing(t *testing.T) {
+	var mu sync.Mutex
+	var created []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.URL.Path == "/zones":
+			_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"saas","name":"saas.example"}]}`))
+		case req.Method == http.MethodGet && req.URL.Path == "/zones/saas/custom_hostnames":
+			if req.URL.Query().Get("hostname") == "shop.customer.com" {
+				_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"h1","hostname":"shop.customer.com","status":"active"}]}`))
+				return
+			}
+			_, _ = rw.Write([]byte(`{"success":true,"result":[]}`))
+		case req.Method == http.MethodPost && req.URL.Path == "/zones/saas/custom_hostnames":
+			raw, _ := io.ReadAll(req.Body)
+			var payload map[string]interface{}
+			_ = json.Unmarshal(raw, &payload)
+			created = append(created, payload)
+			_, _ = rw.Write([]byte(`{"success":true,"result":{"id":"h2","hostname":"new.customer.com","status":"pending"}}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cfg := CreateConfig()
+	cfg.APIToken = "token"
+	cfg.Zone = "saas.example"
+	cfg.CustomHostnameMode = true
+	effective := normalizeConfig(*cfg)
+	if err := validateConfig(effective); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	r, err := newRunner(effective)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	r.client.baseURL = server.URL
+
+	r.syncCustomHostnames(context.Background(), []string{"shop.customer.com", "new.customer.com"})
+
+	if len(created) != 1 {
+		t.Fatalf("expected one custom hostname to be created, got %d", len(created))
+	}
+	if created[0]["hostname"] != "new.customer.com" {
+		t.Fatalf("unexpected hostname payload: %v", created[0])
+	}
+	ssl, _ := created[0]["ssl"].(map[string]interface{})
+	if ssl["method"] != "http" || ssl["type"] != "dv" {
+		t.Fatalf("unexpected ssl payload: %v", ssl)
+	}
+}
+
+func TestCustomHostnameModeRequiresZone(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.CustomHostnameMode = true
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected missing zone to be rejected")
+	}
+}
+
+func TestDryRunHoldsCustomHostnameCreates(t *testing.T) {
+	var mu sync.Mutex
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.Method == http.MethodPost:
+			posts++
+			_, _ = rw.Write([]byte(`{"success":true,"result":{"id":"h2","hostname":"new.customer.com","status":"pending"}}`))
+		case req.URL.Path == "/zones":
+			_, _ = rw.Write([]byte(`{"success":true,"result":[{"id":"saas","name":"saas.example"}]}`))
+		default:
+			_, _ = rw.Write([]byte(`{"success":true,"result":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	cfg := CreateConfig()
+	cfg.APIToken = "token"
+	cfg.Zone = "saas.example"
+	cfg.CustomHostnameMode = true
+	cfg.DryRun = true
+	cfg.DryRunFormat = dryRunFormatPlan
+	cfg.Domains = []string{"new.customer.com"}
+	effective := normalizeConfig(*cfg)
+	r, err := newRunner(effective)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	r.client.baseURL = server.URL
+	var plan bytes.Buffer
+	r.planOut = &plan
+	if err := r.RegisterConfig("test", effective); err != nil {
+		t.Fatalf("RegisterConfig failed: %v", err)
+	}
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if posts != 0 {
+		t.Fatalf("expected no custom hostname to be created in a dry run, got %d POSTs", posts)
+	}
+	if !strings.Contains(plan.String(), "+ new.customer.com (custom hostname)") {
+		t.Fatalf("expected the create in the plan, got:\n%s", plan.String())
+	}
+}
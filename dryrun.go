@@ -0,0 +1,123 @@
+package ddns_traefik_plugin
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// DryRunFormat values.
+const (
+	dryRunFormatLog  = "log"
+	dryRunFormatPlan = "plan"
+)
+
+// Planned change actions, in the order renderPlan groups them.
+const (
+	planCreate = "create"
+	planUpdate = "update"
+	planDelete = "delete"
+)
+
+var planSymbols = map[string]string{planCreate: "+", planUpdate: "~", planDelete: "-"}
+
+// plannedChange is one A record change held back while writes are held. Current is nil for creates
+// and Desired is nil for deletes. Kind names changes that are not A records, such as custom hostnames,
+// which are listed without fields.
+type plannedChange struct {
+	Action  string
+	Name    string
+	Kind    string
+	Current *cfRecord
+	Desired *cfRecord
+}
+
+// planning reports whether held changes are collected into a plan instead of logged one by one.
+func (r *Runner) planning() bool {
+	return r.cfg.DryRun && r.cfg.DryRunFormat == dryRunFormatPlan
+}
+
+// planChange reports a change that is not applied this cycle: as an INFO line, or in plan mode as a
+// plan entry with the line demoted to DEBUG.
+func (r *Runner) planChange(change plannedChange, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...) + " (" + r.holdReason + ")"
+	if !r.planning() {
+		r.infof("%s", message)
+		return
+	}
+	r.plan = append(r.plan, change)
+	r.debugf("%s", message)
+}
+
+// renderPlan writes changes as a per-host diff grouped by action, terraform plan style.
+func renderPlan(w io.Writer, changes []plannedChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "DNS plan (dry run): no changes")
+		return
+	}
+	order := map[string]int{planCreate: 0, planUpdate: 1, planDelete: 2}
+	sorted := append([]plannedChange(nil), changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Action != sorted[j].Action {
+			return order[sorted[i].Action] < order[sorted[j].Action]
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	counts := make(map[string]int)
+	for _, change := range sorted {
+		counts[change.Action]++
+	}
+
+	fmt.Fprintf(w, "DNS plan (dry run): %d to create, %d to update, %d to delete\n",
+		counts[planCreate], counts[planUpdate], counts[planDelete])
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, change := range sorted {
+		kind := change.Kind
+		if kind == "" {
+			kind = "A"
+		}
+		fmt.Fprintf(tw, "  %s %s (%s)\n", planSymbols[change.Action], change.Name, kind)
+		for _, field := range planFields(change) {
+			fmt.Fprintf(tw, "      %s\t%s\n", field[0], field[1])
+		}
+	}
+	_ = tw.Flush()
+}
+
+// planFields renders content, proxied and ttl of a change, as "current -> desired" where an update changes them.
+func planFields(change plannedChange) [][2]string {
+	values := func(record *cfRecord) []string {
+		if record == nil {
+			return nil
+		}
+		return []string{record.Content, strconv.FormatBool(record.Proxied), planTTL(record.TTL)}
+	}
+	current, desired := values(change.Current), values(change.Desired)
+	if current == nil && desired == nil {
+		return nil
+	}
+	names := []string{"content", "proxied", "ttl"}
+	fields := make([][2]string, 0, len(names))
+	for i, name := range names {
+		var value string
+		switch {
+		case current == nil:
+			value = desired[i]
+		case desired == nil || current[i] == desired[i]:
+			value = current[i]
+		default:
+			value = current[i] + " -> " + desired[i]
+		}
+		fields = append(fields, [2]string{name, value})
+	}
+	return fields
+}
+
+func planTTL(ttl int) string {
+	if ttl == autoTTL || ttl == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(ttl)
+}
@@ -0,0 +1,100 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDryRunNeverWrites(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "api.example.com", Type: "A", Content: "198.51.100.1", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+		{ID: "r2", Name: "old.example.com", Type: "A", Content: "198.51.100.1", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "api.example.com"}
+	cfg.PruneStale = true
+	cfg.DryRun = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var plan bytes.Buffer
+	r.planOut = &plan
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, result := range results {
+		if result.Action != ActionSkipped {
+			t.Fatalf("expected held changes to be skipped, got %+v", results)
+		}
+	}
+	if n := cf.countCalls("POST") + cf.countCalls("PUT") + cf.countCalls("DELETE"); n != 0 {
+		t.Fatalf("expected no writes in dry run, got %v", cf.calls)
+	}
+	if plan.Len() != 0 {
+		t.Fatalf("expected no plan in log format, got %q", plan.String())
+	}
+}
+
+func TestDryRunPlanFormat(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "api.example.com", Type: "A", Content: "198.51.100.1", TTL: 300, Comment: "managed-by=traefik-plugin-ddns"},
+		{ID: "r2", Name: "old.example.com", Type: "A", Content: "198.51.100.1", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "api.example.com"}
+	cfg.PruneStale = true
+	cfg.DryRun = true
+	cfg.DryRunFormat = "Plan"
+	cfg.ReconcileFields = []string{"content", "ttl"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var plan bytes.Buffer
+	r.planOut = &plan
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	want := strings.Join([]string{
+		"DNS plan (dry run): 1 to create, 1 to update, 1 to delete",
+		"  + app.example.com (A)",
+		"      content  203.0.113.7",
+		"      proxied  false",
+		"      ttl      auto",
+		"  ~ api.example.com (A)",
+		"      content  198.51.100.1 -> 203.0.113.7",
+		"      proxied  false",
+		"      ttl      300 -> auto",
+		"  - old.example.com (A)",
+		"      content  198.51.100.1",
+		"      proxied  false",
+		"      ttl      auto",
+		"",
+	}, "\n")
+	if plan.String() != want {
+		t.Fatalf("unexpected plan:\n%s\nwant:\n%s", plan.String(), want)
+	}
+
+	// Each cycle prints its own plan.
+	plan.Reset()
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "api.example.com", Type: "A", Content: "203.0.113.7", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+		{ID: "r3", Name: "app.example.com", Type: "A", Content: "203.0.113.7", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if plan.String() != "DNS plan (dry run): no changes\n" {
+		t.Fatalf("expected empty plan, got %q", plan.String())
+	}
+}
+
+func TestValidateDryRunFormat(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.DryRunFormat = "json"
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected unknown dryRunFormat to be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package ddns_traefik_plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventClientBuffer is how many events a slow socket client may lag behind before events are dropped.
+const eventClientBuffer = 64
+
+// Event types published on EventSocket.
+const (
+	eventCycleStart = "cycle_start"
+	eventCycleEnd   = "cycle_end"
+	eventDomain     = "domain"
+	eventError      = "error"
+)
+
+// event is one JSON line on EventSocket.
+type event struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Domain  string    `json:"domain,omitempty"`
+	Action  string    `json:"action,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Message string    `json:"message,omitempty"`
+	// Changes counts created and updated hosts on cycle_end.
+	Changes int `json:"changes,omitempty"`
+}
+
+// eventHub fans events out to every client connected to a Unix socket. Publishing never blocks:
+// a client whose buffer is full misses the event and the dropped counter is incremented.
+type eventHub struct {
+	listener net.Listener
+	dropped  uint64
+	// reported is the dropped count last logged by the worker.
+	reported uint64
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+}
+
+// newEventHub listens on path, replacing a stale socket file left by a previous run.
+func newEventHub(path string) (*eventHub, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	h := &eventHub{listener: listener, clients: make(map[chan []byte]struct{})}
+	go h.acceptLoop()
+	return h, nil
+}
+
+func (h *eventHub) acceptLoop() {
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		ch := make(chan []byte, eventClientBuffer)
+		h.mu.Lock()
+		h.clients[ch] = struct{}{}
+		h.mu.Unlock()
+		go h.serve(conn, ch)
+	}
+}
+
+func (h *eventHub) serve(conn net.Conn, ch chan []byte) {
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, ch)
+		h.mu.Unlock()
+		conn.Close()
+	}()
+	for line := range ch {
+		if _, err := conn.Write(line); err != nil {
+			return
+		}
+	}
+}
+
+func (h *eventHub) publish(e event) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- line:
+		default:
+			atomic.AddUint64(&h.dropped, 1)
+		}
+	}
+}
+
+// droppedEvents returns how many events were dropped for slow clients.
+func (h *eventHub) droppedEvents() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// emit publishes e when EventSocket is configured.
+func (r *Runner) emit(e event) {
+	if r.events == nil {
+		return
+	}
+	e.Time = r.clock.Now()
+	r.events.publish(e)
+}
+
+// emitCycle publishes per-domain outcomes and the cycle summary.
+func (r *Runner) emitCycle(results []DomainStatus, err error) {
+	if r.events == nil {
+		return
+	}
+	changes := 0
+	for _, status := range results {
+		if status.Action == ActionUnchanged {
+			continue
+		}
+		if status.Action == ActionCreated || status.Action == ActionUpdated {
+			changes++
+		}
+		r.emit(event{Type: eventDomain, Domain: status.Domain, Action: status.Action, IP: status.IP, Message: status.Error})
+	}
+	end := event{Type: eventCycleEnd, Changes: changes}
+	if err != nil {
+		end.Message = err.Error()
+	}
+	r.emit(end)
+	if dropped := r.events.droppedEvents(); dropped > r.events.reported {
+		r.warnf("event socket: %d events dropped for slow clients (total %d)", dropped-r.events.reported, dropped)
+		r.events.reported = dropped
+	}
+}
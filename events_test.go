@@ -0,0 +1,96 @@
+package ddns_traefik_plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventSocketStreamsCycle(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.EventSocket = filepath.Join(t.TempDir(), "events.sock")
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+	t.Cleanup(func() { r.events.listener.Close() })
+
+	conn, err := net.Dial("unix", cfg.EventSocket)
+	if err != nil {
+		t.Fatalf("dial event socket: %v", err)
+	}
+	defer conn.Close()
+	waitForClients(t, r.events, 1)
+
+	r.runSyncCycle(context.Background())
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	scanner := bufio.NewScanner(conn)
+	var types []string
+	for len(types) < 3 && scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, e.Type)
+		if e.Type == eventDomain && (e.Domain != "app.example.com" || e.Action != ActionCreated) {
+			t.Fatalf("unexpected domain event: %+v", e)
+		}
+		if e.Type == eventCycleEnd && e.Changes != 1 {
+			t.Fatalf("expected one change on cycle_end, got %+v", e)
+		}
+	}
+	if len(types) != 3 || types[0] != eventCycleStart || types[1] != eventDomain || types[2] != eventCycleEnd {
+		t.Fatalf("unexpected event sequence: %v", types)
+	}
+}
+
+func TestEventHubDropsForSlowClients(t *testing.T) {
+	hub, err := newEventHub(filepath.Join(t.TempDir(), "events.sock"))
+	if err != nil {
+		t.Fatalf("newEventHub: %v", err)
+	}
+	defer hub.listener.Close()
+
+	// A client that never reads fills its buffer and the socket; publish must still return.
+	conn, err := net.Dial("unix", hub.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	waitForClients(t, hub, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10000; i++ {
+			hub.publish(event{Type: eventError, Message: "padding padding padding padding padding padding"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("publish blocked on a slow client")
+	}
+	if hub.droppedEvents() == 0 {
+		t.Fatalf("expected events to be dropped for the slow client")
+	}
+}
+
+func waitForClients(t *testing.T, hub *eventHub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.Lock()
+		count := len(hub.clients)
+		hub.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d event clients", n)
+}
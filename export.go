@@ -0,0 +1,60 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ExportManaged snapshots what the runner manages as portable YAML, for backups and for seeding the CLI or
+// another tool without Traefik discovery: every managed host with its zone, record name and desired record
+// settings. It lists zones with the runner's token but never writes the token or any other secret. Hosts
+// without a matching zone are listed as comments. It waits for an in-flight cycle.
+func (r *Runner) ExportManaged() ([]byte, error) {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	zones, err := r.client.listZones(r.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing zones: %w", err)
+	}
+	zones = zonesInAccount(zones, r.client.accountID)
+	hosts := r.withoutSelfHosts(r.withWWWAliases(r.snapshotHosts(), zones))
+	sort.Strings(hosts)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# managed hosts exported by ddns-traefik-plugin %s\n", Version())
+	if len(hosts) == 0 {
+		b.WriteString("hosts: {}\n")
+		return b.Bytes(), nil
+	}
+	b.WriteString("hosts:\n")
+	for _, host := range hosts {
+		zone, err := r.resolveZone(host, zones)
+		if err != nil {
+			fmt.Fprintf(&b, "  # %s skipped: %v\n", host, err)
+			continue
+		}
+		name := host
+		if r.cfg.NameTemplate != "" {
+			name = applyNameTemplate(r.cfg.NameTemplate, host, zone.Name)
+		}
+		proxied, ttl := r.recordDefaults(name, zone.Name)
+		if proxied {
+			ttl = autoTTL
+		}
+		fmt.Fprintf(&b, "  %s:\n", strconv.Quote(host))
+		fmt.Fprintf(&b, "    zone: %s\n", strconv.Quote(zone.Name))
+		fmt.Fprintf(&b, "    record: %s\n", strconv.Quote(name))
+		fmt.Fprintf(&b, "    proxied: %t\n", proxied)
+		fmt.Fprintf(&b, "    ttl: %d\n", ttl)
+		fmt.Fprintf(&b, "    comment: %s\n", strconv.Quote(r.managedComment(zone.Name)))
+		if reference, ok := r.cfg.FollowHosts[host]; ok {
+			fmt.Fprintf(&b, "    followHost: %s\n", strconv.Quote(reference))
+		}
+		if suffix, ok := r.cfg.IPv6SuffixPerHost[host]; ok {
+			fmt.Fprintf(&b, "    ipv6Suffix: %s\n", strconv.Quote(suffix))
+		}
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,50 @@
+package ddns_traefik_plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportManaged(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"}, cfZone{ID: "z2", Name: "cdn.net"})
+	cfg := CreateConfig()
+	cfg.APIToken = "super-secret-token"
+	cfg.Domains = []string{"app.example.com", "www.cdn.net", "other.org"}
+	cfg.TTL = 300
+	cfg.ZoneDefaults = map[string]RecordDefaults{"cdn.net": {Proxied: boolPtr(true)}}
+	cfg.FollowHosts = map[string]string{"app.example.com": "upstream.example.net"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	out, err := r.ExportManaged()
+	if err != nil {
+		t.Fatalf("ExportManaged failed: %v", err)
+	}
+	want := strings.Join([]string{
+		"# managed hosts exported by ddns-traefik-plugin " + Version(),
+		"hosts:",
+		`  "app.example.com":`,
+		`    zone: "example.com"`,
+		`    record: "app.example.com"`,
+		"    proxied: false",
+		"    ttl: 300",
+		`    comment: "managed-by=traefik-plugin-ddns"`,
+		`    followHost: "upstream.example.net"`,
+		"  # other.org skipped: no zone visible to the token matches other.org",
+		`  "www.cdn.net":`,
+		`    zone: "cdn.net"`,
+		`    record: "www.cdn.net"`,
+		"    proxied: true",
+		"    ttl: 1",
+		`    comment: "managed-by=traefik-plugin-ddns"`,
+		"",
+	}, "\n")
+	if string(out) != want {
+		t.Fatalf("unexpected export:\n%s\nwant:\n%s", out, want)
+	}
+	if strings.Contains(string(out), "super-secret-token") {
+		t.Fatalf("export must never contain the token")
+	}
+	if n := cf.countCalls("POST") + cf.countCalls("PUT") + cf.countCalls("DELETE"); n != 0 {
+		t.Fatalf("export must not write, got %v", cf.calls)
+	}
+}
@@ -0,0 +1,51 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"strings"
+)
+
+// externalDNSHeritage marks TXT ownership records written by external-dns.
+const externalDNSHeritage = "heritage=external-dns"
+
+// externalDNSTypePrefixes are the record-type prefixes external-dns (v0.12+) puts on registry TXT names,
+// as in a-app.example.com; older releases use the host name itself.
+var externalDNSTypePrefixes = []string{"a-", "aaaa-", "cname-", "ns-", "mx-", "srv-", "txt-"}
+
+// externalDNSOwnedHosts returns the hosts in a zone that external-dns claims through its TXT registry.
+func (r *Runner) externalDNSOwnedHosts(ctx context.Context, zone *cfZone) (map[string]struct{}, error) {
+	records, err := r.client.listZoneRecords(ctx, zone.ID, "TXT")
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[string]struct{})
+	for _, record := range records {
+		if !strings.Contains(unquoteTXT(record.Content), externalDNSHeritage) {
+			continue
+		}
+		name := normalizeHost(record.Name)
+		owned[name] = struct{}{}
+		for _, prefix := range externalDNSTypePrefixes {
+			if trimmed, ok := strings.CutPrefix(name, prefix); ok {
+				owned[trimmed] = struct{}{}
+			}
+		}
+	}
+	return owned, nil
+}
+
+// ownedByExternalDNS reports whether external-dns owns host. Registries are fetched once per zone
+// per cycle through cache. A registry lookup error is treated as owned so the plugin never
+// overwrites records it could not verify.
+func (r *Runner) ownedByExternalDNS(ctx context.Context, zone *cfZone, host string, cache map[string]map[string]struct{}) (bool, error) {
+	owned, ok := cache[zone.ID]
+	if !ok {
+		var err error
+		if owned, err = r.externalDNSOwnedHosts(ctx, zone); err != nil {
+			return true, err
+		}
+		cache[zone.ID] = owned
+	}
+	_, found := owned[host]
+	return found, nil
+}
@@ -0,0 +1,54 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRespectExternalDNSSkipsOwnedHosts(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "t1", Name: "legacy.example.com", Type: "TXT", Content: `"heritage=external-dns,external-dns/owner=default"`},
+		{ID: "t2", Name: "a-modern.example.com", Type: "TXT", Content: `"heritage=external-dns,external-dns/owner=k8s"`},
+		{ID: "t3", Name: "mine.example.com", Type: "TXT", Content: `"v=spf1 -all"`},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"legacy.example.com", "modern.example.com", "mine.example.com"}
+	cfg.RespectExternalDNS = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, status := range results {
+		actions[status.Domain] = status.Action
+	}
+	if actions["legacy.example.com"] != ActionSkipped || actions["modern.example.com"] != ActionSkipped {
+		t.Fatalf("expected external-dns hosts to be skipped, got %v", actions)
+	}
+	if actions["mine.example.com"] != ActionCreated {
+		t.Fatalf("expected unowned host to be managed, got %v", actions)
+	}
+	if cf.countCalls(http.MethodPost) != 1 {
+		t.Fatalf("expected a single create, got %v", cf.calls)
+	}
+}
+
+func TestExternalDNSIgnoredWhenDisabled(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "t1", Name: "app.example.com", Type: "TXT", Content: `"heritage=external-dns"`}}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionCreated {
+		t.Fatalf("expected registry to be ignored without respectExternalDns, got %+v", results)
+	}
+}
@@ -0,0 +1,80 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sustainedTransientCycles is how many consecutive cycles with transient failures trigger an alert.
+const sustainedTransientCycles = 3
+
+// Webhook events for Cloudflare sync failures.
+const (
+	webhookSyncFailed  = "sync_failed"
+	webhookSyncFailing = "sync_failing"
+)
+
+// cycleFailures classifies the Cloudflare failures of one cycle.
+type cycleFailures struct {
+	permanent []string
+	transient int
+}
+
+// isTransientError reports whether err is likely to clear by itself: network errors, timeouts, rate
+// limits and server errors that outlasted doRequest's retries. Cloudflare rejections such as auth or
+// validation errors are permanent and need operator action, as are responses that do not decode; a
+// canceled or expired context is neither and is not retried as a Cloudflare problem.
+func isTransientError(err error) bool {
+	var apiErr *CloudflareAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.retryable || apiErr.IsRateLimited()
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// syncFailuref logs a Cloudflare failure at WARN when transient and at ERROR when permanent, and
+// records it for the end-of-cycle alert decision.
+func (r *Runner) syncFailuref(err error, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if isTransientError(err) {
+		r.failures.transient++
+		r.warnKeyed(format, "%s (transient, retrying next cycle): %v", message, err)
+		return
+	}
+	r.failures.permanent = append(r.failures.permanent, fmt.Sprintf("%s: %v", message, err))
+	r.errorKeyed(format, "%s: %v", message, err)
+}
+
+// alertOnFailures calls WebhookURL for permanent failures right away, and for transient failures only
+// once they persist for sustainedTransientCycles cycles in a row.
+func (r *Runner) alertOnFailures(ctx context.Context) {
+	failures := r.failures
+	switch {
+	case failures.transient == 0:
+		r.transientCycles = 0
+	default:
+		r.transientCycles++
+	}
+	var payload webhookPayload
+	switch {
+	case len(failures.permanent) > 0:
+		payload = webhookPayload{Event: webhookSyncFailed, Message: strings.Join(failures.permanent, "; ")}
+	case r.transientCycles == sustainedTransientCycles:
+		payload = webhookPayload{Event: webhookSyncFailing,
+			Message: fmt.Sprintf("transient Cloudflare failures for %d consecutive cycles", r.transientCycles)}
+	default:
+		return
+	}
+	payload.IP = r.lastKnownIP
+	if err := r.sendWebhook(ctx, payload); err != nil {
+		r.errorf("%s webhook failed: %v", payload.Event, err)
+	}
+}
@@ -0,0 +1,85 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestIsTransientError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("cloudflare request failed: %w", &CloudflareAPIError{StatusCode: http.StatusBadGateway, retryable: true}), true},
+		{&CloudflareAPIError{StatusCode: http.StatusTooManyRequests, retryable: true}, true},
+		{&CloudflareAPIError{StatusCode: http.StatusForbidden, body: "denied"}, false},
+		{&CloudflareAPIError{StatusCode: http.StatusOK, Codes: []int{81053}}, false},
+		{errors.New("dial tcp: i/o timeout"), true},
+		{context.Canceled, false},
+		{fmt.Errorf("cycle: %w", context.DeadlineExceeded), false},
+		{fmt.Errorf("invalid cloudflare response: %w", json.Unmarshal([]byte("<html>"), &struct{}{})), false},
+		{fmt.Errorf("invalid dns records payload: %w", json.Unmarshal([]byte(`{"id":1}`), &[]cfRecord{})), false},
+	}
+	for _, tc := range cases {
+		if got := isTransientError(tc.err); got != tc.want {
+			t.Errorf("isTransientError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestFailureAlertsSeparatePermanentFromTransient(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var payload webhookPayload
+		_ = json.NewDecoder(req.Body).Decode(&payload)
+		mu.Lock()
+		events = append(events, payload.Event)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	status, body := http.StatusBadGateway, "bad gateway"
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method == http.MethodPost {
+			return status, body, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.WebhookURL = hook.URL
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	for i := 1; i < sustainedTransientCycles; i++ {
+		r.runSyncCycle(context.Background())
+	}
+	if got := received(); len(got) != 0 {
+		t.Fatalf("expected no alert before transient failures are sustained, got %v", got)
+	}
+	r.runSyncCycle(context.Background())
+	r.runSyncCycle(context.Background())
+	if got := received(); len(got) != 1 || got[0] != webhookSyncFailing {
+		t.Fatalf("expected one sustained-failure alert, got %v", got)
+	}
+
+	status, body = http.StatusForbidden, `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`
+	r.runSyncCycle(context.Background())
+	if got := received(); len(got) != 2 || got[1] != webhookSyncFailed {
+		t.Fatalf("expected immediate alert for a permanent failure, got %v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// followCacheTTL is how long a FollowHosts answer is reused before the reference is looked up again.
+const followCacheTTL = time.Minute
+
+// followedAnswer is a cached FollowHosts lookup.
+type followedAnswer struct {
+	ip      string
+	fetched time.Time
+}
+
+// validateFollowHosts checks every FollowHosts entry names another host.
+func validateFollowHosts(cfg Config) error {
+	for host, reference := range cfg.FollowHosts {
+		if host == "" || reference == "" {
+			return fmt.Errorf("invalid followHosts entry %q: %q: expected host: reference hostname", host, reference)
+		}
+		if host == reference {
+			return fmt.Errorf("invalid followHosts[%s] %q: a host cannot follow itself", host, reference)
+		}
+	}
+	return nil
+}
+
+// followedContent resolves reference through DNS and returns the address a following host should point at.
+// Answers are cached for followCacheTTL. Of several addresses the lowest public IPv4 wins, so a rotating
+// answer set does not flip the record every cycle.
+func (r *Runner) followedContent(ctx context.Context, reference string) (string, error) {
+	now := r.clock.Now()
+	if cached, ok := r.followed[reference]; ok && now.Sub(cached.fetched) < followCacheTTL {
+		return cached.ip, nil
+	}
+	addrs, err := r.lookupHost(ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf("lookup %s: %w", reference, err)
+	}
+	var public []string
+	for _, addr := range addrs {
+		if isPublicIPv4(net.ParseIP(addr)) {
+			public = append(public, net.ParseIP(addr).To4().String())
+		}
+	}
+	if len(public) == 0 {
+		return "", fmt.Errorf("%s has no public IPv4 address (got %v)", reference, addrs)
+	}
+	sort.Slice(public, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(public[i]).To4(), net.ParseIP(public[j]).To4()) < 0
+	})
+	r.debugf("followed %s -> %s", reference, public[0])
+	r.followed[reference] = followedAnswer{ip: public[0], fetched: now}
+	return public[0], nil
+}
@@ -0,0 +1,85 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFollowHostsTrackReferenceAnswer(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "edge.example.com", "lan.example.com", "dead.example.com"}
+	cfg.FollowHosts = map[string]string{
+		"Edge.example.com": "upstream.example.net.",
+		"lan.example.com":  "intranet.example.net",
+		"dead.example.com": "gone.example.net",
+	}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	clock := newFakeClock(time.Unix(0, 0))
+	r.clock = clock
+	answers := map[string][]string{
+		"upstream.example.net": {"198.51.100.9", "2001:db8::1", "198.51.100.4"},
+		"intranet.example.net": {"10.0.0.5"},
+	}
+	lookups := 0
+	r.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		if addrs, ok := answers[host]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	byDomain := make(map[string]DomainStatus)
+	for _, result := range results {
+		byDomain[result.Domain] = result
+	}
+	if byDomain["app.example.com"].Action != ActionCreated || byDomain["edge.example.com"].IP != "198.51.100.4" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if byDomain["lan.example.com"].Action != ActionSkipped || byDomain["dead.example.com"].Action != ActionSkipped {
+		t.Fatalf("expected private and failed references to be skipped, got %+v", results)
+	}
+	for _, record := range cf.records["z1"] {
+		if record.Name == "edge.example.com" && record.Content != "198.51.100.4" {
+			t.Fatalf("expected edge record to follow upstream, got %+v", record)
+		}
+	}
+
+	// Answers are cached briefly, then looked up again.
+	answers["upstream.example.net"] = []string{"198.51.100.20"}
+	lookups = 0
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if lookups != 2 {
+		t.Fatalf("expected only uncached references to be looked up, got %d lookups", lookups)
+	}
+	clock.Advance(followCacheTTL)
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, record := range cf.records["z1"] {
+		if record.Name == "edge.example.com" && record.Content != "198.51.100.20" {
+			t.Fatalf("expected edge record to follow the new answer, got %+v", record)
+		}
+	}
+}
+
+func TestValidateFollowHosts(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.FollowHosts = map[string]string{"app.example.com": "APP.example.com."}
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected a host following itself to be rejected")
+	}
+	cfg.FollowHosts = map[string]string{"app.example.com": " "}
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected an empty reference to be rejected")
+	}
+}
@@ -0,0 +1,83 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// healthCheckAttempts is how many times HealthCheckURL is probed before the server is considered down.
+const healthCheckAttempts = 3
+
+// evaluateHealth runs the health check and updates failover state.
+// It returns true when the failover address should be published. A non-nil error means
+// the server is unhealthy but not (yet) failed over, so record changes must be skipped.
+func (r *Runner) evaluateHealth(ctx context.Context) (bool, error) {
+	healthErr := r.checkHealth(ctx)
+	if r.cfg.FailoverIP == "" {
+		return false, healthErr
+	}
+
+	if healthErr != nil {
+		r.healthFailures++
+		r.healthSuccesses = 0
+	} else {
+		r.healthSuccesses++
+		r.healthFailures = 0
+	}
+
+	threshold := r.cfg.FailoverThreshold
+	switch {
+	case !r.failedOver && r.healthFailures >= threshold:
+		r.failedOver = true
+		r.warnf("failover: %s unhealthy for %d checks; publishing failover ip %s", r.cfg.HealthCheckURL, r.healthFailures, r.cfg.FailoverIP)
+	case r.failedOver && r.healthSuccesses >= threshold:
+		r.failedOver = false
+		r.infof("failback: %s healthy for %d checks; publishing resolved public ip again", r.cfg.HealthCheckURL, r.healthSuccesses)
+	}
+
+	if r.failedOver {
+		return true, nil
+	}
+	if healthErr != nil {
+		return false, fmt.Errorf("%w (failure %d/%d before failover)", healthErr, r.healthFailures, threshold)
+	}
+	return false, nil
+}
+
+// checkHealth probes HealthCheckURL; any 2xx/3xx answer counts as healthy.
+func (r *Runner) checkHealth(ctx context.Context) error {
+	timeout := time.Duration(r.cfg.HealthCheckTimeoutSeconds) * time.Second
+	var lastErr error
+	for attempt := 1; attempt <= healthCheckAttempts; attempt++ {
+		lastErr = r.probeHealth(ctx, timeout)
+		if lastErr == nil {
+			return nil
+		}
+		if attempt < healthCheckAttempts {
+			r.clock.Sleep(time.Second)
+		}
+	}
+	return lastErr
+}
+
+func (r *Runner) probeHealth(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.cfg.HealthCheckURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("status=%d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckFailureSkipsWrites(t *testing.T) {
+	var healthy atomic.Bool
+	health := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !healthy.Load() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer health.Close()
+
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.HealthCheckURL = health.URL
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+	r.clock = newFakeClock(time.Unix(0, 0))
+
+	if _, err := r.reconcile(context.Background()); err == nil {
+		t.Fatalf("expected health check failure to be reported")
+	}
+	if cf.countCalls(http.MethodPost) != 0 {
+		t.Fatalf("expected no writes while unhealthy, got %v", cf.calls)
+	}
+
+	healthy.Store(true)
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("unexpected error once healthy: %v", err)
+	}
+	if cf.countCalls(http.MethodPost) != 1 {
+		t.Fatalf("expected record to be created once healthy, got %v", cf.calls)
+	}
+}
+
+func TestFailoverHysteresis(t *testing.T) {
+	var healthy atomic.Bool
+	health := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !healthy.Load() {
+			rw.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer health.Close()
+
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.HealthCheckURL = health.URL
+	cfg.FailoverIP = "198.51.100.50"
+	cfg.FailoverThreshold = 2
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+	r.clock = newFakeClock(time.Unix(0, 0))
+
+	content := func() string {
+		cf.mu.Lock()
+		defer cf.mu.Unlock()
+		if len(cf.records["z1"]) == 0 {
+			return ""
+		}
+		return cf.records["z1"][0].Content
+	}
+
+	healthy.Store(true)
+	_, _ = r.reconcile(context.Background())
+	if content() != "203.0.113.10" {
+		t.Fatalf("expected primary ip while healthy, got %q", content())
+	}
+
+	healthy.Store(false)
+	if _, err := r.reconcile(context.Background()); err == nil {
+		t.Fatalf("expected first failure to skip changes")
+	}
+	if content() != "203.0.113.10" {
+		t.Fatalf("expected no failover before threshold, got %q", content())
+	}
+	_, _ = r.reconcile(context.Background())
+	if content() != "198.51.100.50" {
+		t.Fatalf("expected failover ip after threshold, got %q", content())
+	}
+
+	healthy.Store(true)
+	_, _ = r.reconcile(context.Background())
+	if content() != "198.51.100.50" {
+		t.Fatalf("expected failover to hold until threshold healthy checks, got %q", content())
+	}
+	_, _ = r.reconcile(context.Background())
+	if content() != "203.0.113.10" {
+		t.Fatalf("expected failback to primary ip, got %q", content())
+	}
+}
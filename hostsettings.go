@@ -0,0 +1,87 @@
+package ddns_traefik_plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Host conflict policies: which middleware's proxied/TTL settings a host gets when several register it.
+const (
+	hostConflictPrimary = "primary"
+	hostConflictLast    = "last"
+)
+
+// registrationDefaults is the proxied and TTL one middleware asks for a host: its DomainOverrides entry
+// layered over its DefaultProxied and TTL.
+func registrationDefaults(reg registration, host string) (bool, int) {
+	return reg.overrides[host].apply(reg.defaults.apply(false, 0))
+}
+
+// resolveHostSettings picks, for every registered host, the middleware whose settings apply, and warns
+// once about each new set of middlewares that register a host with conflicting settings. With
+// hostConflictPolicy primary the primary middleware wins when it registers the host and the most
+// recently registered one otherwise; with last the most recently registered one always wins. The caller
+// holds hostsMu.
+func (r *Runner) resolveHostSettings() {
+	policy := r.config().HostConflictPolicy
+	owners := make(map[string][]string)
+	for name, reg := range r.registrations {
+		for host := range reg.hosts {
+			owners[host] = append(owners[host], name)
+		}
+	}
+	settings := make(map[string]string, len(owners))
+	// warned is rebuilt from the current conflicts so hosts that are gone or no longer conflict drop out.
+	warned := make(map[string]string)
+	for host, names := range owners {
+		sort.Slice(names, func(i, j int) bool {
+			return r.registrations[names[i]].order < r.registrations[names[j]].order
+		})
+		winner := names[len(names)-1]
+		if policy == hostConflictPrimary && hasField(names, r.primary) {
+			winner = r.primary
+		}
+		settings[host] = winner
+		if len(names) < 2 || !r.hostSettingsConflict(host, names) {
+			continue
+		}
+		conflict := strings.Join(names, ",") + "=" + winner
+		warned[host] = conflict
+		if r.hostConflictsWarned[host] == conflict {
+			continue
+		}
+		details := make([]string, 0, len(names))
+		for _, name := range names {
+			proxied, ttl := registrationDefaults(r.registrations[name], host)
+			details = append(details, fmt.Sprintf("middleware=%s proxied=%t ttl=%d", name, proxied, ttl))
+		}
+		r.warnf("domain=%s registered with conflicting settings (%s); using middleware=%s (hostConflictPolicy=%s)",
+			host, strings.Join(details, ", "), winner, policy)
+	}
+	r.hostSettings = settings
+	r.hostConflictsWarned = warned
+}
+
+func (r *Runner) hostSettingsConflict(host string, names []string) bool {
+	firstProxied, firstTTL := registrationDefaults(r.registrations[names[0]], host)
+	for _, name := range names[1:] {
+		if proxied, ttl := registrationDefaults(r.registrations[name], host); proxied != firstProxied || ttl != firstTTL {
+			return true
+		}
+	}
+	return false
+}
+
+// hostRegistration returns the registration whose settings apply to host, when that is not the
+// middleware the runner-wide settings already come from.
+func (r *Runner) hostRegistration(host string) (registration, bool) {
+	r.hostsMu.RLock()
+	defer r.hostsMu.RUnlock()
+	name, ok := r.hostSettings[host]
+	if !ok || name == r.settingsFrom {
+		return registration{}, false
+	}
+	reg, ok := r.registrations[name]
+	return reg, ok
+}
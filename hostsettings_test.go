@@ -0,0 +1,161 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func registerMiddleware(t *testing.T, r *Runner, name string, mutate func(*Config)) {
+	t.Helper()
+	cfg := CreateConfig()
+	cfg.APIToken = "token"
+	mutate(cfg)
+	if err := r.RegisterConfig(name, normalizeConfig(*cfg)); err != nil {
+		t.Fatalf("RegisterConfig %s failed: %v", name, err)
+	}
+}
+
+func TestHostSettingsComeFromRegisteringMiddleware(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	registerMiddleware(t, r, "cdn", func(cfg *Config) {
+		cfg.Domains = []string{"b.example.com", "c.example.com"}
+		cfg.DefaultProxied = true
+		cfg.DomainOverrides = map[string]RecordDefaults{"c.example.com": {Proxied: boolPtr(false), TTL: 300}}
+	})
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	got := map[string]cfRecord{}
+	for _, record := range cf.records["z1"] {
+		got[record.Name] = record
+	}
+	if got["a.example.com"].Proxied || !got["b.example.com"].Proxied || got["c.example.com"].Proxied || got["c.example.com"].TTL != 300 {
+		t.Fatalf("expected each host to get its middleware's settings, got %+v", got)
+	}
+}
+
+func TestHostConflictPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy      string
+		wantProxied bool
+		wantWinner  string
+	}{
+		{policy: "", wantProxied: false, wantWinner: "primary"},
+		{policy: "last", wantProxied: true, wantWinner: "late"},
+	} {
+		t.Run("policy="+tc.policy, func(t *testing.T) {
+			cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+			cfg := CreateConfig()
+			cfg.Domains = []string{"other.example.com"}
+			r := newTestRunner(t, cfg, cf, "203.0.113.7")
+			var logs bytes.Buffer
+			r.logger = log.New(&logs, "", 0)
+
+			registerMiddleware(t, r, "primary", func(cfg *Config) {
+				cfg.Primary = true
+				cfg.HostConflictPolicy = tc.policy
+				cfg.Domains = []string{"app.example.com"}
+			})
+			late := func(cfg *Config) {
+				cfg.Domains = []string{"app.example.com"}
+				cfg.DefaultProxied = true
+			}
+			registerMiddleware(t, r, "late", late)
+			// A reload with the same settings does not repeat the warning.
+			registerMiddleware(t, r, "late", late)
+
+			if proxied, _ := r.recordDefaults("app.example.com", "example.com"); proxied != tc.wantProxied {
+				t.Fatalf("expected proxied=%t for the conflicting host, got %t", tc.wantProxied, proxied)
+			}
+			out := logs.String()
+			if n := strings.Count(out, "[WARN] domain=app.example.com registered with conflicting settings"); n != 1 {
+				t.Fatalf("expected one conflict warning, got %d:\n%s", n, out)
+			}
+			if !strings.Contains(out, "middleware=primary proxied=false ttl=1, middleware=late proxied=true ttl=1); using middleware="+tc.wantWinner) {
+				t.Fatalf("expected the warning to name both settings and the winner, got:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestHostConflictWithoutPrimaryLastRegisteredWins(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	registerMiddleware(t, r, "second", func(cfg *Config) {
+		cfg.Domains = []string{"app.example.com"}
+		cfg.TTL = 600
+	})
+	if _, ttl := r.recordDefaults("app.example.com", "example.com"); ttl != 600 {
+		t.Fatalf("expected the most recently registered middleware to win, got ttl=%d", ttl)
+	}
+
+	// Same settings from both middlewares are not a conflict.
+	registerMiddleware(t, r, "second", func(cfg *Config) {
+		cfg.Domains = []string{"app.example.com"}
+	})
+	if conflict, ok := r.hostConflictsWarned["app.example.com"]; ok {
+		t.Fatalf("expected matching settings to clear the conflict, got %q", conflict)
+	}
+
+	// A host the middleware no longer registers does not linger in the warned conflicts.
+	registerMiddleware(t, r, "second", func(cfg *Config) {
+		cfg.Domains = []string{"app.example.com"}
+		cfg.TTL = 600
+	})
+	registerMiddleware(t, r, "second", func(cfg *Config) {
+		cfg.Domains = []string{"other.example.com"}
+	})
+	if len(r.hostConflictsWarned) != 0 {
+		t.Fatalf("expected no warned conflicts once the host is registered once, got %v", r.hostConflictsWarned)
+	}
+}
+
+func TestValidateHostConflictPolicy(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.HostConflictPolicy = "first"
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected an unknown hostConflictPolicy to be rejected")
+	}
+	cfg.HostConflictPolicy = " Last "
+	if err := validateConfig(normalizeConfig(*cfg)); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestPreserveHostCaseFollowsWinningMiddleware(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"App.Example.com"}
+	cfg.PreserveHostCase = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	registerMiddleware(t, r, "second", func(cfg *Config) {
+		cfg.Domains = []string{"APP.example.com"}
+	})
+	registerMiddleware(t, r, "third", func(cfg *Config) {
+		cfg.Domains = []string{"app.EXAMPLE.com"}
+	})
+	for i := 0; i < 20; i++ {
+		if got := r.payloadName("app.example.com"); got != "app.EXAMPLE.com" {
+			t.Fatalf("expected the most recently registered middleware's casing, got %q", got)
+		}
+	}
+
+	// A winner without casing of its own falls back to the earliest registered casing.
+	registerMiddleware(t, r, "third", func(cfg *Config) {
+		cfg.Domains = []string{"app.example.com"}
+	})
+	for i := 0; i < 20; i++ {
+		if got := r.payloadName("app.example.com"); got != "App.Example.com" {
+			t.Fatalf("expected the earliest registered casing, got %q", got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package ddns_traefik_plugin
+
+import "time"
+
+// hostBackoffAfterFailures is how many consecutive failed cycles put a host into backoff.
+const hostBackoffAfterFailures = 3
+
+// hostState is everything the runner remembers about one host between cycles. Per-host tracking
+// belongs here rather than in its own map, so forgetStaleHosts drops a vanished host in one place.
+type hostState struct {
+	// absentSince is when the host's owned record was first seen without a registration; zero while
+	// the host is managed.
+	absentSince time.Time
+	// failures counts consecutive failed syncs; while in backoff the host is skipped until cycle
+	// skipUntil.
+	failures  int
+	skipUntil int
+	// unchangedCycle is the last cycle the host was found already synced; unchangedLoggedAt is when that
+	// was last logged rather than suppressed by UnchangedLogWindowSeconds.
+	unchangedCycle    int
+	unchangedLoggedAt time.Time
+	// dnsOnlyWarned is set once the host's fallback from proxied to DNS-only was logged.
+	dnsOnlyWarned bool
+	// mailHintIP is the address the host's reverse DNS hint was last logged for.
+	mailHintIP string
+}
+
+// state returns the state of host, creating it on first use.
+func (r *Runner) state(host string) *hostState {
+	st, ok := r.hostStates[host]
+	if !ok {
+		st = &hostState{}
+		r.hostStates[host] = st
+	}
+	return st
+}
+
+// forgetStaleHosts drops state for hosts that are no longer managed and not waiting out a prune
+// grace period, so churning hosts do not grow the map for the life of the process.
+func (r *Runner) forgetStaleHosts(managed []string) {
+	current := make(map[string]struct{}, len(managed))
+	for _, host := range managed {
+		current[host] = struct{}{}
+	}
+	for host, st := range r.hostStates {
+		if _, ok := current[host]; !ok && st.absentSince.IsZero() {
+			delete(r.hostStates, host)
+		}
+	}
+}
+
+// backingOff reports whether host is skipped this cycle after repeated failures.
+func (r *Runner) backingOff(host string) bool {
+	st, ok := r.hostStates[host]
+	return ok && st.skipUntil > r.cycle
+}
+
+// recordHostResult updates the failure streak of host. From hostBackoffAfterFailures consecutive
+// failures on, the host sits out 1, 2, 4, ... cycles, capped at HostBackoffMaxCycles. A success
+// clears the streak.
+func (r *Runner) recordHostResult(host string, failed bool) {
+	st := r.state(host)
+	if !failed {
+		if st.failures >= hostBackoffAfterFailures {
+			r.infof("domain=%s synced again after %d failed cycles; backoff cleared", host, st.failures)
+		}
+		st.failures, st.skipUntil = 0, 0
+		return
+	}
+	st.failures++
+	if st.failures < hostBackoffAfterFailures {
+		return
+	}
+	skip := r.cfg.HostBackoffMaxCycles
+	if shift := st.failures - hostBackoffAfterFailures; shift < 16 && 1<<shift < skip {
+		skip = 1 << shift
+	}
+	st.skipUntil = r.cycle + skip + 1
+	r.warnf("domain=%s failed %d cycles in a row; skipping it for %d cycles", host, st.failures, skip)
+}
+
+// logUnchanged logs that host is already synced, unless UnchangedLogWindowSeconds is set, the host was
+// also unchanged in the previous cycle and the line was logged within the window. Suppressed lines are
+// counted for the cycle summary.
+func (r *Runner) logUnchanged(host string) {
+	st := r.state(host)
+	now := r.clock.Now()
+	window := time.Duration(r.cfg.UnchangedLogWindowSeconds) * time.Second
+	repeated := st.unchangedCycle == r.cycle-1 && now.Sub(st.unchangedLoggedAt) < window
+	st.unchangedCycle = r.cycle
+	if window > 0 && repeated {
+		r.unchangedSuppressed++
+		return
+	}
+	st.unchangedLoggedAt = now
+	r.debugf("domain=%s already synced", host)
+}
@@ -0,0 +1,142 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostStateForgetsVanishedHosts(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "gone.example.com", Type: "A", Content: "203.0.113.7", Comment: "managed-by=traefik-plugin-ddns"},
+		{ID: "r2", Name: "removed.example.com", Type: "A", Content: "203.0.113.7", Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.PruneStale = true
+	cfg.PruneGracePeriodSeconds = 3600
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	r.state("churned.example.com")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if _, ok := r.hostStates["churned.example.com"]; ok {
+		t.Fatalf("expected state of an unmanaged host without a pending prune to be dropped")
+	}
+	for _, host := range []string{"gone.example.com", "removed.example.com"} {
+		if st, ok := r.hostStates[host]; !ok || st.absentSince.IsZero() {
+			t.Fatalf("expected %s to wait out the grace period", host)
+		}
+	}
+
+	// The record is deleted by hand during the grace period: nothing is left to prune.
+	cf.records["z1"] = cf.records["z1"][:1]
+	r.clock = newFakeClock(time.Unix(60, 0))
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if _, ok := r.hostStates["removed.example.com"]; ok {
+		t.Fatalf("expected state of a host whose record vanished to be dropped")
+	}
+	if _, ok := r.hostStates["gone.example.com"]; !ok {
+		t.Fatalf("expected pending prune to be kept")
+	}
+}
+
+func TestHostBackoffSkipsFailingHost(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	broken := true
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if broken && req.URL.Query().Get("name") == "bad.example.com" {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"bad.example.com", "good.example.com"}
+	cfg.HostBackoffMaxCycles = 2
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	// Cycles 1-3 fail, then the host sits out 1 cycle; cycle 5 fails again and it sits out 2 (capped).
+	var badActions []string
+	for i := 0; i < 8; i++ {
+		results, err := r.reconcile(context.Background())
+		if err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		for _, result := range results {
+			switch result.Domain {
+			case "bad.example.com":
+				badActions = append(badActions, result.Action)
+			case "good.example.com":
+				if result.Action == ActionFailed || result.Action == ActionSkipped {
+					t.Fatalf("expected healthy host to be unaffected, got %+v", result)
+				}
+			}
+		}
+	}
+	want := []string{ActionFailed, ActionFailed, ActionFailed, ActionSkipped, ActionFailed, ActionSkipped, ActionSkipped, ActionFailed}
+	if strings.Join(badActions, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected backoff schedule:\n got %v\nwant %v", badActions, want)
+	}
+
+	// Recovery clears the streak once the host is tried again.
+	broken = false
+	for i := 0; i < 3; i++ {
+		if _, err := r.reconcile(context.Background()); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+	}
+	if st := r.hostStates["bad.example.com"]; st.failures != 0 || r.backingOff("bad.example.com") {
+		t.Fatalf("expected backoff to be cleared after a success, got %+v", st)
+	}
+}
+
+func TestUnchangedLogWindowCompactsLogs(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com", "b.example.com"}
+	cfg.UnchangedLogWindowSeconds = 600
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	clock := newFakeClock(time.Unix(0, 0))
+	r.clock = clock
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	cycle := func() string {
+		logs.Reset()
+		if _, err := r.reconcile(context.Background()); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		return logs.String()
+	}
+	cycle() // creates both records
+
+	if out := cycle(); strings.Count(out, "already synced") != 2 || strings.Contains(out, "domains unchanged") {
+		t.Fatalf("expected first unchanged cycle to log every host, got:\n%s", out)
+	}
+	if out := cycle(); strings.Contains(out, "already synced") || !strings.Contains(out, "[DEBUG] 2 domains unchanged") {
+		t.Fatalf("expected repeated lines to be summarized, got:\n%s", out)
+	}
+
+	// A change is logged right away and the host's next unchanged cycle is logged again.
+	cf.records["z1"][0].Content = "198.51.100.1"
+	if out := cycle(); !strings.Contains(out, "[DEBUG] 1 domains unchanged") {
+		t.Fatalf("expected only the unchanged host in the summary, got:\n%s", out)
+	}
+	if out := cycle(); strings.Count(out, "already synced") != 1 {
+		t.Fatalf("expected the changed host to be logged once more, got:\n%s", out)
+	}
+
+	// After the window the line is repeated.
+	clock.Advance(10 * time.Minute)
+	if out := cycle(); strings.Count(out, "already synced") != 2 {
+		t.Fatalf("expected lines to resurface after the window, got:\n%s", out)
+	}
+}
@@ -0,0 +1,387 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IP families accepted by resolvePublicIP.
+const (
+	ipFamilyV4  = "v4"
+	ipFamilyV6  = "v6"
+	ipFamilyAny = "any"
+)
+
+// cloudflareIPRanges are Cloudflare's published edge ranges (https://www.cloudflare.com/ips/).
+// An IP source reporting one of these is looking at a proxied record, not at this origin.
+var cloudflareIPRanges = mustParseCIDRs(
+	"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+	"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+	"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+	"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+	"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+	"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+)
+
+// errDeniedIP marks an IP source answer inside DenyIPs.
+var errDeniedIP = errors.New("address is in denyIps")
+
+// ipSourceContentTypes are the media types accepted from IP sources with StrictIPContentType.
+var ipSourceContentTypes = []string{"text/plain", "application/json"}
+
+// ipResolver resolves the public address from an ordered list of sources.
+type ipResolver struct {
+	client *http.Client
+	family string
+	// rejectCloudflare skips answers inside cloudflareIPRanges and tries the next source.
+	rejectCloudflare bool
+	// deny skips answers inside these networks (DenyIPs) and tries the next source.
+	deny []*net.IPNet
+	// strictParsing requires the whole trimmed body to be the address instead of scanning for it.
+	strictParsing bool
+	// strictContentType accepts only answers served as one of ipSourceContentTypes.
+	strictContentType bool
+	// rejectMapped refuses IPv4-mapped IPv6 answers (::ffff:a.b.c.d) instead of unwrapping them.
+	rejectMapped bool
+	// stats, when set, reorders sources by past reliability and latency and records every attempt.
+	stats *ipSourceStats
+	// sticky, when set, starts from the source that last answered for this family.
+	sticky *stickySources
+	// warnf, when set, reports each skipped Cloudflare edge answer, which the resolve error would
+	// otherwise drop once a later source succeeds.
+	warnf func(format string, args ...interface{})
+}
+
+func resolvePublicIPv4(ctx context.Context, sources []string, client *http.Client) (string, error) {
+	return resolvePublicIP(ctx, sources, ipFamilyV4, client)
+}
+
+// resolvePublicIP returns the first address reported by sources that belongs to the requested family.
+func resolvePublicIP(ctx context.Context, sources []string, family string, client *http.Client) (string, error) {
+	return ipResolver{client: client, family: family}.resolve(ctx, sources)
+}
+
+func (p ipResolver) resolve(ctx context.Context, sources []string) (string, error) {
+	family := p.family
+	if family != ipFamilyV4 && family != ipFamilyV6 && family != ipFamilyAny {
+		return "", fmt.Errorf("unsupported ip family %q", family)
+	}
+	if p.stats != nil {
+		sources = p.stats.order(sources)
+	}
+	if p.sticky != nil {
+		sources = p.sticky.order(family, sources)
+	}
+	var errs []string
+	denied := 0
+	for _, source := range sources {
+		var started time.Time
+		if p.stats != nil {
+			started = p.stats.clock.Now()
+		}
+		candidate, err := p.query(ctx, source)
+		if p.stats != nil {
+			p.stats.record(source, err == nil, p.stats.clock.Now().Sub(started))
+		}
+		if err != nil {
+			if errors.Is(err, errDeniedIP) {
+				denied++
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v", source, err))
+			continue
+		}
+		if p.sticky != nil {
+			p.sticky.remember(family, source)
+		}
+		return candidate, nil
+	}
+	if denied > 0 && denied == len(sources) {
+		return "", fmt.Errorf("every IP source returned an address in denyIps: %s", strings.Join(errs, "; "))
+	}
+	return "", fmt.Errorf("all IP sources failed: %s", strings.Join(errs, "; "))
+}
+
+// query asks one source for the public address and validates the answer.
+func (p ipResolver) query(ctx context.Context, source string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	raw, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		return "", readErr
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("status=%d", resp.StatusCode)
+	}
+	if p.strictContentType {
+		if err := checkIPSourceContentType(resp.Header.Get("Content-Type")); err != nil {
+			return "", err
+		}
+	}
+
+	candidate, parsed, err := extractIP(string(raw), p.family, p.strictParsing)
+	if err != nil {
+		return "", err
+	}
+	if candidate, err = p.unwrapMapped(candidate, parsed); err != nil {
+		return "", err
+	}
+	if p.rejectCloudflare && ipInNets(parsed, cloudflareIPRanges) {
+		if p.warnf != nil {
+			p.warnf("ip source %s returned Cloudflare edge ip %s; skipping it", source, candidate)
+		}
+		return "", fmt.Errorf("%s is a Cloudflare edge ip", candidate)
+	}
+	if ipInNets(parsed, p.deny) {
+		return "", fmt.Errorf("%s: %w", candidate, errDeniedIP)
+	}
+	return candidate, nil
+}
+
+// unwrapMapped handles IPv4-mapped IPv6 answers such as ::ffff:203.0.113.8 from dual-stack sources. They
+// parse as IPv4, so they are returned in dotted form, which is what an A record takes, or rejected with
+// rejectMapped. Other answers are returned unchanged.
+func (p ipResolver) unwrapMapped(candidate string, parsed net.IP) (string, error) {
+	v4 := parsed.To4()
+	if v4 == nil || !strings.Contains(candidate, ":") {
+		return candidate, nil
+	}
+	if p.rejectMapped {
+		return "", fmt.Errorf("%s is an IPv4-mapped IPv6 address (rejectIpv4Mapped)", candidate)
+	}
+	return v4.String(), nil
+}
+
+// maxScannedIPBody bounds the answers extractIP scans; anything longer is not an IP echo service.
+const maxScannedIPBody = 512
+
+// extractIP finds the address of family in an IP source answer. Strict mode requires the trimmed body
+// to be exactly the address. Otherwise the body is split on anything that cannot be part of an
+// address, which handles quotes, JSON arrays and trailing comments, and the one address found is
+// returned. Bodies with several different addresses are rejected as ambiguous.
+func extractIP(body, family string, strict bool) (string, net.IP, error) {
+	trimmed := strings.TrimSpace(body)
+	if parsed := net.ParseIP(trimmed); ipMatchesFamily(parsed, family) {
+		return trimmed, parsed, nil
+	}
+	if strict || len(trimmed) > maxScannedIPBody {
+		return "", nil, fmt.Errorf("invalid %s ip %q", family, trimmed)
+	}
+	tokens := strings.FieldsFunc(trimmed, func(c rune) bool {
+		return !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == '.' || c == ':')
+	})
+	var found string
+	var foundIP net.IP
+	for _, token := range tokens {
+		parsed := net.ParseIP(token)
+		if !ipMatchesFamily(parsed, family) {
+			continue
+		}
+		if foundIP != nil && !foundIP.Equal(parsed) {
+			return "", nil, fmt.Errorf("ambiguous answer %q: several %s addresses", trimmed, family)
+		}
+		found, foundIP = token, parsed
+	}
+	if foundIP == nil {
+		return "", nil, fmt.Errorf("invalid %s ip %q", family, trimmed)
+	}
+	return found, foundIP, nil
+}
+
+// ipSourceStats tracks per-source success rate and latency for adaptive source ordering.
+// Stats are dropped every resetAfter so a source that recovers can win its place back.
+type ipSourceStats struct {
+	clock      clock
+	resetAfter time.Duration
+
+	mu       sync.Mutex
+	since    time.Time
+	bySource map[string]*sourceStat
+}
+
+type sourceStat struct {
+	attempts  int
+	successes int
+	// latency is the total time of successful lookups.
+	latency time.Duration
+}
+
+func newIPSourceStats(c clock, resetAfter time.Duration) *ipSourceStats {
+	return &ipSourceStats{clock: c, resetAfter: resetAfter, since: c.Now(), bySource: make(map[string]*sourceStat)}
+}
+
+func (s *ipSourceStats) record(source string, ok bool, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stat := s.bySource[source]
+	if stat == nil {
+		stat = &sourceStat{}
+		s.bySource[source] = stat
+	}
+	stat.attempts++
+	if ok {
+		stat.successes++
+		stat.latency += latency
+	}
+}
+
+// order returns sources sorted by success rate, then mean latency. Sources without stats count as
+// fully reliable but slowest, so they are tried after proven sources and before failing ones.
+// Ties keep the configured order.
+func (s *ipSourceStats) order(sources []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := s.clock.Now(); s.resetAfter > 0 && now.Sub(s.since) >= s.resetAfter {
+		s.bySource = make(map[string]*sourceStat)
+		s.since = now
+	}
+
+	type score struct {
+		rate    float64
+		latency time.Duration
+		known   bool
+	}
+	scores := make(map[string]score, len(sources))
+	for _, source := range sources {
+		stat := s.bySource[source]
+		if stat == nil || stat.attempts == 0 {
+			scores[source] = score{rate: 1}
+			continue
+		}
+		sc := score{rate: float64(stat.successes) / float64(stat.attempts), known: true}
+		if stat.successes > 0 {
+			sc.latency = stat.latency / time.Duration(stat.successes)
+		}
+		scores[source] = sc
+	}
+
+	ordered := append([]string(nil), sources...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := scores[ordered[i]], scores[ordered[j]]
+		if a.rate != b.rate {
+			return a.rate > b.rate
+		}
+		if a.known != b.known {
+			return a.known
+		}
+		return a.latency < b.latency
+	})
+	return ordered
+}
+
+// stickySources remembers, per IP family, the source that last returned a valid address, for
+// StickyIPSource. It is safe for concurrent use.
+type stickySources struct {
+	mu       sync.Mutex
+	byFamily map[string]string
+}
+
+func newStickySources() *stickySources {
+	return &stickySources{byFamily: make(map[string]string)}
+}
+
+func (s *stickySources) remember(family, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byFamily[family] = source
+}
+
+// order rotates sources to start at the last good source, so the ones after it are tried next and
+// the ones before it last. Sources are returned unchanged when the last good one is no longer listed.
+func (s *stickySources) order(family string, sources []string) []string {
+	s.mu.Lock()
+	last := s.byFamily[family]
+	s.mu.Unlock()
+	for i, source := range sources {
+		if source == last {
+			return append(append([]string(nil), sources[i:]...), sources[:i]...)
+		}
+	}
+	return sources
+}
+
+func ipMatchesFamily(ip net.IP, family string) bool {
+	if ip == nil {
+		return false
+	}
+	switch family {
+	case ipFamilyV4:
+		return ip.To4() != nil
+	case ipFamilyV6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDenyIPs turns DenyIPs entries, single addresses or CIDRs, into networks; a single address
+// becomes a /32 or /128.
+func parseDenyIPs(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for i, entry := range entries {
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 128
+			if v4 := ip.To4(); v4 != nil {
+				ip, bits = v4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denyIps[%d] %q: expected an IP address or a CIDR such as 192.0.2.0/24", i, entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	out := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// checkIPSourceContentType rejects answers that are not plain text or JSON, such as a captive portal's
+// HTML page that happens to contain an address.
+func checkIPSourceContentType(header string) error {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fmt.Errorf("unexpected content type %q", header)
+	}
+	if !hasField(ipSourceContentTypes, mediaType) {
+		return fmt.Errorf("unexpected content type %q: expected text/plain or application/json", mediaType)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ipSourceServer(t *testing.T, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestResolverRejectsCloudflareEdgeIPs(t *testing.T) {
+	edge := ipSourceServer(t, "104.16.1.1")
+	origin := ipSourceServer(t, "203.0.113.8")
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	resolver := ipResolver{client: client, family: ipFamilyV4, rejectCloudflare: true, warnf: func(format string, args ...interface{}) {
+		logger.Printf("[WARN] "+format, args...)
+	}}
+	got, err := resolver.resolve(context.Background(), []string{edge, origin})
+	if err != nil || got != "203.0.113.8" {
+		t.Fatalf("expected edge ip to be skipped, got %q err=%v", got, err)
+	}
+	if want := "[WARN] ip source " + edge + " returned Cloudflare edge ip 104.16.1.1; skipping it"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("expected a warning naming the source and address, got %q", buf.String())
+	}
+
+	if _, err := resolver.resolve(context.Background(), []string{edge}); err == nil {
+		t.Fatalf("expected error when only an edge ip is available")
+	}
+
+	resolver.rejectCloudflare = false
+	got, err = resolver.resolve(context.Background(), []string{edge, origin})
+	if err != nil || got != "104.16.1.1" {
+		t.Fatalf("expected edge ip to be accepted when rejection is off, got %q err=%v", got, err)
+	}
+}
+
+func TestIPSourceStatsOrder(t *testing.T) {
+	fake := newFakeClock(time.Unix(0, 0))
+	stats := newIPSourceStats(fake, time.Hour)
+	sources := []string{"a", "b", "c", "d"}
+
+	if got := stats.order(sources); !equalStrings(got, sources) {
+		t.Fatalf("expected configured order without stats, got %v", got)
+	}
+
+	stats.record("a", false, 0)
+	stats.record("b", true, 300*time.Millisecond)
+	stats.record("c", true, 50*time.Millisecond)
+	// d has no stats: trusted, but tried after proven sources and before failing ones.
+	if got := stats.order(sources); !equalStrings(got, []string{"c", "b", "d", "a"}) {
+		t.Fatalf("unexpected adaptive order: %v", got)
+	}
+
+	fake.Advance(time.Hour)
+	if got := stats.order(sources); !equalStrings(got, sources) {
+		t.Fatalf("expected stats reset to restore configured order, got %v", got)
+	}
+}
+
+func TestResolverAdaptiveOrderPrefersWorkingSource(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(broken.Close)
+	calls := 0
+	good := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		_, _ = rw.Write([]byte("203.0.113.8"))
+	}))
+	t.Cleanup(good.Close)
+
+	resolver := ipResolver{
+		client: &http.Client{Timeout: 2 * time.Second},
+		family: ipFamilyV4,
+		stats:  newIPSourceStats(newFakeClock(time.Unix(0, 0)), time.Hour),
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := resolver.resolve(context.Background(), []string{broken.URL, good.URL}); err != nil || got != "203.0.113.8" {
+			t.Fatalf("resolve %d: got %q err=%v", i, got, err)
+		}
+	}
+	if stat := resolver.stats.bySource[broken.URL]; stat == nil || stat.attempts != 1 {
+		t.Fatalf("expected failing source to be tried once and then demoted, got %+v", stat)
+	}
+	if calls != 2 {
+		t.Fatalf("expected working source to answer both cycles, got %d", calls)
+	}
+}
+
+func TestResolverStickySource(t *testing.T) {
+	healthy := map[string]bool{}
+	calls := map[string]int{}
+	source := func(name, ip string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			calls[name]++
+			if !healthy[name] {
+				rw.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = rw.Write([]byte(ip))
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	sources := []string{source("a", "203.0.113.1"), source("b", "203.0.113.2"), source("c", "203.0.113.3")}
+	resolver := ipResolver{client: &http.Client{Timeout: 2 * time.Second}, family: ipFamilyV4, sticky: newStickySources()}
+	resolve := func(want string) {
+		t.Helper()
+		if got, err := resolver.resolve(context.Background(), sources); err != nil || got != want {
+			t.Fatalf("got %q err=%v, want %s", got, err, want)
+		}
+	}
+
+	healthy["b"], healthy["c"] = true, true
+	resolve("203.0.113.2")
+	// The first source recovers, but the last good one keeps answering.
+	healthy["a"] = true
+	resolve("203.0.113.2")
+	if calls["a"] != 1 || calls["b"] != 2 {
+		t.Fatalf("expected lookups to stick to b, got calls %v", calls)
+	}
+	// b fails: the sources after it are tried before wrapping around.
+	healthy["b"] = false
+	resolve("203.0.113.3")
+	resolve("203.0.113.3")
+	if calls["a"] != 1 || calls["b"] != 3 || calls["c"] != 2 {
+		t.Fatalf("expected fallback to c and then to stick to it, got calls %v", calls)
+	}
+
+	resolver.sticky = nil
+	resolve("203.0.113.1")
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStrictContentTypeRejectsHTML(t *testing.T) {
+	portal := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// A captive portal whose page body still parses as an address.
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = rw.Write([]byte("10.1.2.3"))
+	}))
+	defer portal.Close()
+	origin := ipSourceServer(t, "203.0.113.8")
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	lenient := ipResolver{client: client, family: ipFamilyV4}
+	if got, err := lenient.resolve(context.Background(), []string{portal.URL}); err != nil || got != "10.1.2.3" {
+		t.Fatalf("expected lenient resolver to accept the body, got %q (%v)", got, err)
+	}
+
+	strict := ipResolver{client: client, family: ipFamilyV4, strictContentType: true}
+	got, err := strict.resolve(context.Background(), []string{portal.URL, origin})
+	if err != nil || got != "203.0.113.8" {
+		t.Fatalf("expected html answer rejected and next source used, got %q (%v)", got, err)
+	}
+	if _, err := strict.resolve(context.Background(), []string{portal.URL}); err == nil || !strings.Contains(err.Error(), "text/html") {
+		t.Fatalf("expected content type error, got %v", err)
+	}
+}
+
+func TestExtractIPFormats(t *testing.T) {
+	cases := []struct {
+		body   string
+		family string
+		want   string
+	}{
+		{"203.0.113.8\n", ipFamilyV4, "203.0.113.8"},
+		{`"203.0.113.8"`, ipFamilyV4, "203.0.113.8"},
+		{`["203.0.113.8"]`, ipFamilyV4, "203.0.113.8"},
+		{"203.0.113.8 # your address", ipFamilyV4, "203.0.113.8"},
+		{"Current IP Address: 203.0.113.8", ipFamilyV4, "203.0.113.8"},
+		{`["2001:db8::1"]`, ipFamilyV6, "2001:db8::1"},
+		{`{"v6":"2001:db8::1","v4":"203.0.113.8"}`, ipFamilyV4, "203.0.113.8"},
+		{"203.0.113.8, 203.0.113.8", ipFamilyV4, "203.0.113.8"},
+	}
+	for _, tc := range cases {
+		got, _, err := extractIP(tc.body, tc.family, false)
+		if err != nil || got != tc.want {
+			t.Errorf("extractIP(%q) = %q, %v; want %q", tc.body, got, err, tc.want)
+		}
+	}
+	for _, body := range []string{"no address here", "203.0.113.8 198.51.100.1", strings.Repeat("x", maxScannedIPBody) + " 203.0.113.8"} {
+		if got, _, err := extractIP(body, ipFamilyV4, false); err == nil {
+			t.Errorf("extractIP(%q) = %q, expected an error", body, got)
+		}
+	}
+	if _, _, err := extractIP(`"203.0.113.8"`, ipFamilyV4, true); err == nil {
+		t.Errorf("expected strict parsing to reject a quoted answer")
+	}
+	if got, _, err := extractIP(" 203.0.113.8\n", ipFamilyV4, true); err != nil || got != "203.0.113.8" {
+		t.Errorf("expected strict parsing to accept a bare address, got %q (%v)", got, err)
+	}
+}
+
+func TestResolverSkipsDeniedIPs(t *testing.T) {
+	sinkhole := ipSourceServer(t, "198.51.100.23")
+	vpn := ipSourceServer(t, "10.8.4.2")
+	origin := ipSourceServer(t, "203.0.113.8")
+	deny, err := parseDenyIPs([]string{"198.51.100.23", "10.8.0.0/16"})
+	if err != nil {
+		t.Fatalf("parseDenyIPs failed: %v", err)
+	}
+	resolver := ipResolver{client: &http.Client{Timeout: 2 * time.Second}, family: ipFamilyV4, deny: deny}
+
+	got, err := resolver.resolve(context.Background(), []string{sinkhole, vpn, origin})
+	if err != nil || got != "203.0.113.8" {
+		t.Fatalf("expected the single ip and the cidr to be skipped, got %q err=%v", got, err)
+	}
+	_, err = resolver.resolve(context.Background(), []string{sinkhole, vpn})
+	if err == nil || !strings.Contains(err.Error(), "every IP source returned an address in denyIps") {
+		t.Fatalf("expected a denyIps error when every source is denied, got %v", err)
+	}
+
+	if _, err := parseDenyIPs([]string{"198.51.100.0/33"}); err == nil {
+		t.Fatalf("expected an invalid CIDR to be rejected")
+	}
+}
+
+func TestResolverIPv4MappedAnswers(t *testing.T) {
+	mapped := ipSourceServer(t, "::ffff:203.0.113.8\n")
+	plain := ipSourceServer(t, "198.51.100.4")
+	resolver := ipResolver{client: &http.Client{Timeout: 2 * time.Second}, family: ipFamilyV4}
+
+	got, err := resolver.resolve(context.Background(), []string{mapped, plain})
+	if err != nil || got != "203.0.113.8" {
+		t.Fatalf("expected the mapped answer to be unwrapped for the A record, got %q err=%v", got, err)
+	}
+	resolver.rejectMapped = true
+	got, err = resolver.resolve(context.Background(), []string{mapped, plain})
+	if err != nil || got != "198.51.100.4" {
+		t.Fatalf("expected the mapped answer to be skipped, got %q err=%v", got, err)
+	}
+	resolver = ipResolver{client: &http.Client{Timeout: 2 * time.Second}, family: ipFamilyV6}
+	if _, err := resolver.resolve(context.Background(), []string{mapped}); err == nil {
+		t.Fatalf("expected a mapped answer never to count as IPv6")
+	}
+}
@@ -0,0 +1,209 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var defaultIPv6Sources = []string{
+	"https://api6.ipify.org",
+	"https://ipv6.icanhazip.com",
+}
+
+// validateIPv6Suffixes checks ipv6SuffixPerHost against ipv6PrefixLen: every suffix must be an IPv6
+// interface identifier that leaves the prefix bits zero.
+func validateIPv6Suffixes(cfg Config) error {
+	if len(cfg.IPv6SuffixPerHost) == 0 {
+		return nil
+	}
+	if cfg.IPv6PrefixLen < 16 || cfg.IPv6PrefixLen > 64 {
+		return fmt.Errorf("invalid ipv6PrefixLen %d: expected 16-64 with ipv6SuffixPerHost", cfg.IPv6PrefixLen)
+	}
+	for host, suffix := range cfg.IPv6SuffixPerHost {
+		parsed := net.ParseIP(suffix)
+		if parsed == nil || parsed.To4() != nil {
+			return fmt.Errorf("invalid ipv6SuffixPerHost[%s] %q: expected an IPv6 suffix such as ::1", host, suffix)
+		}
+		if !parsed.Mask(net.CIDRMask(cfg.IPv6PrefixLen, 128)).Equal(net.IPv6zero) {
+			return fmt.Errorf("invalid ipv6SuffixPerHost[%s] %q: sets bits inside the /%d prefix", host, suffix, cfg.IPv6PrefixLen)
+		}
+	}
+	return nil
+}
+
+// normalizeFamilies lowercases and trims family tokens, dropping empty ones.
+func normalizeFamilies(tokens []string) []string {
+	var out []string
+	for _, token := range tokens {
+		if token = strings.ToLower(strings.TrimSpace(token)); token != "" {
+			out = append(out, token)
+		}
+	}
+	return out
+}
+
+// validateHostFamilies checks every family token is v4 or v6, and that a host listed with v6 has an
+// ipv6SuffixPerHost entry to build its AAAA record from.
+func validateHostFamilies(cfg Config) error {
+	for i, family := range cfg.DefaultFamilies {
+		if family != ipFamilyV4 && family != ipFamilyV6 {
+			return fmt.Errorf("invalid defaultFamilies[%d] %q: expected v4 or v6", i, family)
+		}
+	}
+	for host, families := range cfg.HostFamilies {
+		if len(families) == 0 {
+			return fmt.Errorf("invalid hostFamilies[%s]: expected at least one of v4, v6", host)
+		}
+		for _, family := range families {
+			switch family {
+			case ipFamilyV4:
+			case ipFamilyV6:
+				if _, ok := cfg.IPv6SuffixPerHost[host]; !ok {
+					return fmt.Errorf("invalid hostFamilies[%s]: v6 needs an ipv6SuffixPerHost entry for the host", host)
+				}
+			default:
+				return fmt.Errorf("invalid hostFamilies[%s] %q: expected v4 or v6", host, family)
+			}
+		}
+	}
+	return nil
+}
+
+// hostHasFamily reports whether family is reconciled for host, from HostFamilies or DefaultFamilies.
+func (r *Runner) hostHasFamily(host, family string) bool {
+	families, ok := r.cfg.HostFamilies[host]
+	if !ok {
+		families = r.cfg.DefaultFamilies
+	}
+	return hasField(families, family)
+}
+
+// combineIPv6 ORs the interface identifier suffix onto prefix and checks the result is a global
+// unicast address.
+func combineIPv6(prefix net.IP, suffix string) (string, error) {
+	id := net.ParseIP(suffix).To16()
+	combined := make(net.IP, net.IPv6len)
+	for i := range combined {
+		combined[i] = prefix[i] | id[i]
+	}
+	if !combined.IsGlobalUnicast() || combined.IsPrivate() {
+		return "", fmt.Errorf("%s is not a global unicast address", combined)
+	}
+	return combined.String(), nil
+}
+
+// syncAAAARecords publishes an AAAA record for every ipv6SuffixPerHost entry, on the delegated prefix
+// of the current public IPv6 address. Like SRV records it runs after the A records and never changes
+// the cycle's domain results.
+func (r *Runner) syncAAAARecords(ctx context.Context, zones []cfZone, managed []string) {
+	public, err := r.ipResolver(ipFamilyV6).resolve(ctx, r.cfg.IPv6Sources)
+	if err != nil {
+		r.warnf("public ipv6 lookup failed; AAAA records left unchanged: %v", err)
+		return
+	}
+	prefix := net.ParseIP(public).Mask(net.CIDRMask(r.cfg.IPv6PrefixLen, 128))
+	r.debugf("delegated prefix %s/%d from %s", prefix, r.cfg.IPv6PrefixLen, public)
+
+	hosts := make([]string, 0, len(r.cfg.IPv6SuffixPerHost))
+	for host := range r.cfg.IPv6SuffixPerHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		if !hasField(managed, host) {
+			r.debugf("domain=%s AAAA skipped (host is not managed)", host)
+			continue
+		}
+		if !r.hostHasFamily(host, ipFamilyV6) {
+			r.debugf("domain=%s AAAA skipped (v6 not in its families)", host)
+			continue
+		}
+		content, err := combineIPv6(prefix, r.cfg.IPv6SuffixPerHost[host])
+		if err != nil {
+			r.warnf("domain=%s AAAA skipped: %v", host, err)
+			continue
+		}
+		zone, err := r.resolveZone(host, zones)
+		if err != nil {
+			r.warnf("domain=%s AAAA skipped: %v", host, err)
+			continue
+		}
+		if err := r.syncAAAARecord(ctx, zone, host, content); err != nil {
+			r.syncFailuref(err, "domain=%s AAAA sync failed", host)
+		}
+	}
+}
+
+func (r *Runner) syncAAAARecord(ctx context.Context, zone *cfZone, host, content string) error {
+	records, err := r.client.listAAAARecords(ctx, zone.ID, host)
+	if err != nil {
+		return err
+	}
+	proxied, ttl := r.recordDefaults(host, zone.Name)
+	desired := cfRecord{Name: host, Type: "AAAA", Content: content, Proxied: proxied, TTL: ttl, Comment: r.managedComment(zone.Name)}
+	if hasReconciledRecord(records, desired, r.cfg.ReconcileFields) {
+		r.debugf("domain=%s AAAA already synced", host)
+		return nil
+	}
+	if r.writesHeld {
+		r.infof("would set AAAA record domain=%s ip=%s (%s)", host, content, r.holdReason)
+		return nil
+	}
+	if len(records) == 0 {
+		r.infof("create AAAA record domain=%s ip=%s", host, content)
+		created := desired
+		created.Comment = r.buildComment(zone.Name, host, content)
+		_, err = r.client.writeAAAARecord(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zone.ID), created)
+		return err
+	}
+	record := pickRecord(records)
+	want := r.updatedRecord(zone.Name, record, desired)
+	r.infof("update AAAA record domain=%s old=%s new=%s", host, record.Content, content)
+	_, err = r.client.writeAAAARecord(ctx, http.MethodPut, fmt.Sprintf("/zones/%s/dns_records/%s", zone.ID, record.ID), want)
+	return err
+}
+
+func (c *cloudflareClient) listAAAARecords(ctx context.Context, zoneID, host string) ([]cfRecord, error) {
+	path := fmt.Sprintf("/zones/%s/dns_records?type=AAAA&name=%s&per_page=100", zoneID, nameQuery(host))
+	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var records []cfRecord
+	if err := json.Unmarshal(env.Result, &records); err != nil {
+		return nil, fmt.Errorf("invalid dns records payload: %w", err)
+	}
+	filtered := make([]cfRecord, 0, len(records))
+	for _, record := range records {
+		if strings.EqualFold(record.Name, host) && record.Type == "AAAA" {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered, nil
+}
+
+// writeAAAARecord creates (POST) or replaces (PUT) an AAAA record from record's fields.
+func (c *cloudflareClient) writeAAAARecord(ctx context.Context, method, path string, record cfRecord) (*cfRecord, error) {
+	payload := map[string]interface{}{
+		"type":    "AAAA",
+		"name":    record.Name,
+		"content": record.Content,
+		"ttl":     c.writableTTL(record.Name, record.Proxied, record.TTL),
+		"proxied": record.Proxied,
+		"comment": record.Comment,
+	}
+	env, err := c.writeRecord(ctx, method, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	var written cfRecord
+	if err := json.Unmarshal(env.Result, &written); err != nil {
+		return nil, fmt.Errorf("invalid write record payload: %w", err)
+	}
+	return &written, nil
+}
@@ -0,0 +1,186 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestCombineIPv6(t *testing.T) {
+	prefix := net.ParseIP("2001:db8:1234:5600::").Mask(net.CIDRMask(56, 128))
+	got, err := combineIPv6(prefix, "::1a2b:3c4d:5e6f:7080")
+	if err != nil || got != "2001:db8:1234:5600:1a2b:3c4d:5e6f:7080" {
+		t.Fatalf("unexpected combined address %q err=%v", got, err)
+	}
+
+	ula := net.ParseIP("fd00:1:2:3::").Mask(net.CIDRMask(56, 128))
+	if _, err := combineIPv6(ula, "::1"); err == nil {
+		t.Fatalf("expected a unique local prefix to be rejected")
+	}
+}
+
+func TestValidateIPv6Suffixes(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.IPv6SuffixPerHost = map[string]string{"nas.example.com": "::1"}
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected ipv6SuffixPerHost without ipv6PrefixLen to be rejected")
+	}
+	cfg.IPv6PrefixLen = 56
+	if err := validateConfig(normalizeConfig(*cfg)); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	cfg.IPv6SuffixPerHost = map[string]string{"nas.example.com": "0:0:0:ff00::1"}
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected a suffix reaching into the prefix to be rejected")
+	}
+	cfg.IPv6SuffixPerHost = map[string]string{"nas.example.com": "10.0.0.1"}
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected an IPv4 suffix to be rejected")
+	}
+}
+
+func TestAAAARecordFollowsDelegatedPrefix(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"nas.example.com", "web.example.com"}
+	cfg.IPv6PrefixLen = 56
+	cfg.IPv6SuffixPerHost = map[string]string{"NAS.example.com": "::11", "other.example.com": "::12"}
+	cfg.IPv6Sources = []string{ipSourceServer(t, "2001:db8:1234:5601:aaaa:bbbb:cccc:dddd")}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	var aaaa []cfRecord
+	for _, record := range cf.records["z1"] {
+		if record.Type == "AAAA" {
+			aaaa = append(aaaa, record)
+		}
+	}
+	if len(aaaa) != 1 || aaaa[0].Name != "nas.example.com" || aaaa[0].Content != "2001:db8:1234:5600::11" {
+		t.Fatalf("expected one AAAA record on the delegated prefix, got %+v", aaaa)
+	}
+
+	// The ISP hands out a new prefix.
+	r.cfg.IPv6Sources = []string{ipSourceServer(t, "2001:db8:9999:aa00::1")}
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, record := range cf.records["z1"] {
+		if record.Type == "AAAA" && record.Content != "2001:db8:9999:aa00::11" {
+			t.Fatalf("expected AAAA record to follow the new prefix, got %+v", record)
+		}
+	}
+	if cf.countCalls("PUT") != 1 {
+		t.Fatalf("expected the AAAA record to be updated in place, got %v", cf.calls)
+	}
+}
+
+func TestHostFamiliesSelectRecordTypes(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"dual.example.com", "legacy.example.com", "v6only.example.com", "default.example.com"}
+	cfg.IPv6PrefixLen = 56
+	cfg.IPv6SuffixPerHost = map[string]string{
+		"dual.example.com":    "::11",
+		"legacy.example.com":  "::12",
+		"v6only.example.com":  "::13",
+		"default.example.com": "::14",
+	}
+	cfg.HostFamilies = map[string][]string{
+		"dual.example.com":   {"v4", "v6"},
+		"Legacy.example.com": {" V4 "},
+		"v6only.example.com": {"v6"},
+	}
+	cfg.IPv6Sources = []string{ipSourceServer(t, "2001:db8:1234:5601::1")}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, result := range results {
+		actions[result.Domain] = result.Action
+	}
+	if actions["v6only.example.com"] != ActionSkipped || actions["legacy.example.com"] != ActionCreated {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	got := map[string]bool{}
+	for _, record := range cf.records["z1"] {
+		got[record.Type+" "+record.Name] = true
+	}
+	want := map[string]bool{
+		"A dual.example.com":       true,
+		"AAAA dual.example.com":    true,
+		"A legacy.example.com":     true,
+		"AAAA v6only.example.com":  true,
+		"A default.example.com":    true,
+		"AAAA default.example.com": true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected records %v, got %v", want, got)
+	}
+	for key := range want {
+		if !got[key] {
+			t.Fatalf("expected records %v, got %v", want, got)
+		}
+	}
+
+	cf = newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg.HostFamilies = nil
+	cfg.DefaultFamilies = []string{"v4"}
+	r = newTestRunner(t, cfg, cf, "203.0.113.7")
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, record := range cf.records["z1"] {
+		if record.Type != "A" {
+			t.Fatalf("expected only A records with defaultFamilies [v4], got %+v", cf.records["z1"])
+		}
+	}
+}
+
+func TestHostFamiliesPruneAOfV6OnlyHost(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"v6only.example.com"}
+	cfg.PruneStale = true
+	cfg.IPv6PrefixLen = 56
+	cfg.IPv6SuffixPerHost = map[string]string{"v6only.example.com": "::13"}
+	cfg.HostFamilies = map[string][]string{"v6only.example.com": {"v6"}}
+	cfg.IPv6Sources = []string{ipSourceServer(t, "2001:db8:1234:5601::1")}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	cf.records["z1"] = []cfRecord{{ID: "a1", Name: "v6only.example.com", Type: "A", Content: "203.0.113.7", Comment: r.managedComment("example.com")}}
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(cf.records["z1"]) != 1 || cf.records["z1"][0].Type != "AAAA" {
+		t.Fatalf("expected the stale A record pruned and only the AAAA record left, got %+v", cf.records["z1"])
+	}
+}
+
+func TestValidateHostFamilies(t *testing.T) {
+	for name, mutate := range map[string]func(*Config){
+		"unknown token":     func(cfg *Config) { cfg.HostFamilies = map[string][]string{"nas.example.com": {"ipv4"}} },
+		"empty list":        func(cfg *Config) { cfg.HostFamilies = map[string][]string{"nas.example.com": {" "}} },
+		"v6 without suffix": func(cfg *Config) { cfg.HostFamilies = map[string][]string{"web.example.com": {"v6"}} },
+		"bad default":       func(cfg *Config) { cfg.DefaultFamilies = []string{"v4", "both"} },
+	} {
+		cfg := CreateConfig()
+		cfg.IPv6PrefixLen = 56
+		cfg.IPv6SuffixPerHost = map[string]string{"nas.example.com": "::1"}
+		mutate(cfg)
+		if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+			t.Fatalf("%s: expected config to be rejected", name)
+		}
+	}
+	cfg := CreateConfig()
+	cfg.IPv6PrefixLen = 56
+	cfg.IPv6SuffixPerHost = map[string]string{"nas.example.com": "::1"}
+	cfg.HostFamilies = map[string][]string{"NAS.example.com": {"v6"}, "web.example.com": {"v4"}}
+	if err := validateConfig(normalizeConfig(*cfg)); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
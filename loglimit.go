@@ -0,0 +1,93 @@
+package ddns_traefik_plugin
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// logLimiter caps WARN and ERROR lines per message type with a token bucket per type: each type may log
+// LogRateLimitPerMinute lines in a burst, refilled at that rate. It is safe for concurrent use.
+type logLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*logBucket
+}
+
+type logBucket struct {
+	tokens     float64
+	refilled   time.Time
+	suppressed int
+}
+
+func newLogLimiter(perMinute int) *logLimiter {
+	return &logLimiter{perMinute: perMinute, buckets: make(map[string]*logBucket)}
+}
+
+// allow reports whether a line of type key may be logged now, counting it as suppressed otherwise.
+func (l *logLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &logBucket{tokens: float64(l.perMinute), refilled: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.refilled).Minutes() * float64(l.perMinute)
+	if limit := float64(l.perMinute); b.tokens > limit {
+		b.tokens = limit
+	}
+	b.refilled = now
+	if b.tokens < 1 {
+		b.suppressed++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// takeSuppressed returns and resets the suppressed count of every message type that dropped lines.
+func (l *logLimiter) takeSuppressed() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make(map[string]int)
+	for key, b := range l.buckets {
+		if b.suppressed > 0 {
+			out[key] = b.suppressed
+			b.suppressed = 0
+		}
+	}
+	return out
+}
+
+// logAllowed applies LogRateLimitPerMinute to a WARN or ERROR line whose message type is key.
+func (r *Runner) logAllowed(level, key string) bool {
+	limit := r.logLimiter()
+	return limit == nil || limit.allow(level+" "+key, r.clock.Now())
+}
+
+// logLimiter returns the LogRateLimitPerMinute limiter, or nil when the option is off. Lines are logged
+// from any goroutine, so it is read under cfgMu.
+func (r *Runner) logLimiter() *logLimiter {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.logLimit
+}
+
+// reportSuppressedLogs logs one summary line per message type that was rate limited since the last
+// call, so an error storm stays visible after its lines are dropped.
+func (r *Runner) reportSuppressedLogs() {
+	limit := r.logLimiter()
+	if limit == nil {
+		return
+	}
+	suppressed := limit.takeSuppressed()
+	keys := make([]string, 0, len(suppressed))
+	for key := range suppressed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.logger.Printf("[WARN] suppressed %d log lines of type %q (logRateLimitPerMinute=%d)", suppressed[key], key, limit.perMinute)
+	}
+}
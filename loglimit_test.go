@@ -0,0 +1,60 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLimiterTokenBucket(t *testing.T) {
+	l := newLogLimiter(2)
+	now := time.Unix(0, 0)
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if l.allow("WARN a", now) {
+			allowed++
+		}
+	}
+	if allowed != 2 || !l.allow("WARN b", now) {
+		t.Fatalf("expected a burst of 2 per message type, got %d", allowed)
+	}
+	if got := l.takeSuppressed(); got["WARN a"] != 3 || len(got) != 1 {
+		t.Fatalf("unexpected suppressed counts: %v", got)
+	}
+	if !l.allow("WARN a", now.Add(30*time.Second)) || l.allow("WARN a", now.Add(30*time.Second)) {
+		t.Fatalf("expected one token to be refilled after half a minute")
+	}
+}
+
+func TestLogRateLimitDuringErrorStorm(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method == http.MethodPost {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	for i := 0; i < 10; i++ {
+		cfg.Domains = append(cfg.Domains, fmt.Sprintf("host%d.example.com", i))
+	}
+	cfg.LogRateLimitPerMinute = 3
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	r.clock = newFakeClock(time.Unix(0, 0))
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	r.runSyncCycle(context.Background())
+	out := logs.String()
+	if n := strings.Count(out, "[ERROR] domain="); n != 3 {
+		t.Fatalf("expected 3 sync failure lines, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, `[WARN] suppressed 7 log lines of type "ERROR domain=%s sync failed" (logRateLimitPerMinute=3)`) {
+		t.Fatalf("expected a suppression summary, got:\n%s", out)
+	}
+}
@@ -0,0 +1,187 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// mailHintPrefix is the label under a mail host where MailHostHints publishes its forward-confirmation TXT.
+const mailHintPrefix = "_fcrdns."
+
+// reverseName returns the in-addr.arpa name a PTR record for the IPv4 address ip lives at.
+func reverseName(ip string) string {
+	v4 := net.ParseIP(ip).To4()
+	if v4 == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0])
+}
+
+// mailHintContent is the TXT value published for a mail host: the address its A record holds and the
+// PTR the ISP must publish for forward-confirmed reverse DNS to pass.
+func mailHintContent(host, ip string) string {
+	return fmt.Sprintf("ip=%s ptr=%s expects=%s", ip, reverseName(ip), host)
+}
+
+// syncMailHints runs after the A records when MailHostHints is on. Managed hosts that an MX record in
+// their zone points at get a TXT at _fcrdns.<host> recording the address and the PTR it needs, and the
+// PTR to request from the ISP is logged whenever the address changes; Cloudflare cannot publish reverse
+// DNS for addresses it does not own. Owned hints of managed hosts that are no longer MX targets are
+// deleted. Failures are logged and never change the cycle's domain results.
+func (r *Runner) syncMailHints(ctx context.Context, zones []cfZone, results []DomainStatus) {
+	var byZone []mailHintZone
+	index := make(map[string]int)
+	for _, status := range results {
+		host := status.Domain
+		if status.Record != "" {
+			host = status.Record
+		}
+		zone, err := r.resolveZone(host, zones)
+		if err != nil {
+			continue
+		}
+		i, ok := index[zone.ID]
+		if !ok {
+			i = len(byZone)
+			index[zone.ID] = i
+			byZone = append(byZone, mailHintZone{zone: zone})
+		}
+		byZone[i].hosts = append(byZone[i].hosts, mailHintHost{host: host, status: status})
+	}
+	for _, group := range byZone {
+		r.syncZoneMailHints(ctx, group.zone, group.hosts)
+	}
+}
+
+// mailHintZone is the managed hosts of one zone whose mail host hints are synced together.
+type mailHintZone struct {
+	zone  *cfZone
+	hosts []mailHintHost
+}
+
+type mailHintHost struct {
+	host   string
+	status DomainStatus
+}
+
+func (r *Runner) syncZoneMailHints(ctx context.Context, zone *cfZone, hosts []mailHintHost) {
+	targets, err := r.mxTargets(ctx, zone)
+	if err != nil {
+		r.errorf("zone=%s mail host hints skipped (list MX records: %v)", zone.Name, err)
+		return
+	}
+	records, err := r.client.listZoneRecords(ctx, zone.ID, "TXT")
+	if err != nil {
+		r.errorf("zone=%s mail host hints skipped (list TXT records: %v)", zone.Name, err)
+		return
+	}
+	hints := make(map[string][]cfRecord)
+	for _, record := range r.withoutSkipped(records) {
+		if name := normalizeHost(record.Name); strings.HasPrefix(name, mailHintPrefix) {
+			hints[name] = append(hints[name], record)
+		}
+	}
+	for _, h := range hosts {
+		name := mailHintPrefix + h.host
+		if _, isMailHost := targets[h.host]; !isMailHost {
+			for _, record := range hints[name] {
+				if r.ownsRecord(zone.Name, record) {
+					r.deleteMailHint(ctx, zone, name, record, "no longer an MX target")
+				}
+			}
+			continue
+		}
+		status := h.status
+		if status.Action == ActionSkipped || status.Action == ActionFailed || status.IP == "" {
+			continue
+		}
+		if err := r.syncMailHint(ctx, zone, h.host, status.IP, hints[name]); err != nil {
+			r.errorf("domain=%s mail host hint failed: %v", h.host, err)
+			continue
+		}
+		if st := r.state(h.host); st.mailHintIP != status.IP {
+			r.infof("mail host=%s ip=%s: set reverse DNS (PTR %s) to %s at your ISP; Cloudflare cannot publish it",
+				h.host, status.IP, reverseName(status.IP), h.host)
+			st.mailHintIP = status.IP
+		}
+	}
+}
+
+// mxTargets returns the hosts the zone's MX records point at.
+func (r *Runner) mxTargets(ctx context.Context, zone *cfZone) (map[string]struct{}, error) {
+	records, err := r.client.listZoneRecords(ctx, zone.ID, "MX")
+	if err != nil {
+		return nil, err
+	}
+	targets := make(map[string]struct{}, len(records))
+	for _, record := range records {
+		targets[normalizeHost(strings.TrimSuffix(record.Content, "."))] = struct{}{}
+	}
+	return targets, nil
+}
+
+// syncMailHint brings the hint TXT records at _fcrdns.<host> to one record holding the current
+// address: a matching record is kept, otherwise the first is updated or a record created, and any
+// other record there is deleted.
+func (r *Runner) syncMailHint(ctx context.Context, zone *cfZone, host, ip string, records []cfRecord) error {
+	name := mailHintPrefix + host
+	desired := mailHintContent(host, ip)
+	keep := -1
+	for i, record := range records {
+		if unquoteTXT(record.Content) == desired {
+			r.debugf("domain=%s mail host hint unchanged", host)
+			keep = i
+			break
+		}
+	}
+	var err error
+	if keep < 0 {
+		switch {
+		case r.writesHeld && len(records) == 0:
+			r.infof("would create TXT record domain=%s content=%q (%s)", name, desired, r.holdReason)
+		case r.writesHeld:
+			r.infof("would update TXT record domain=%s content=%q (%s)", name, desired, r.holdReason)
+		case len(records) == 0:
+			r.infof("create TXT record domain=%s content=%q", name, desired)
+			_, err = r.client.writeRecord(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zone.ID), r.mailHintPayload(zone.Name, host, ip))
+		default:
+			r.infof("update TXT record domain=%s content=%q", name, desired)
+			path := fmt.Sprintf("/zones/%s/dns_records/%s", zone.ID, records[0].ID)
+			_, err = r.client.writeRecord(ctx, http.MethodPut, path, r.mailHintPayload(zone.Name, host, ip))
+		}
+		if err != nil {
+			return err
+		}
+		keep = 0
+	}
+	for i, record := range records {
+		if i != keep {
+			r.deleteMailHint(ctx, zone, name, record, "duplicate")
+		}
+	}
+	return nil
+}
+
+func (r *Runner) deleteMailHint(ctx context.Context, zone *cfZone, name string, record cfRecord, reason string) {
+	if r.writesHeld {
+		r.infof("would delete TXT record domain=%s content=%q: %s (%s)", name, unquoteTXT(record.Content), reason, r.holdReason)
+		return
+	}
+	r.infof("delete TXT record domain=%s content=%q: %s", name, unquoteTXT(record.Content), reason)
+	if err := r.client.deleteRecord(ctx, zone.ID, record.ID); err != nil {
+		r.errorf("domain=%s mail host hint delete failed: %v", name, err)
+	}
+}
+
+func (r *Runner) mailHintPayload(zone, host, ip string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "TXT",
+		"name":    mailHintPrefix + host,
+		"content": mailHintContent(host, ip),
+		"ttl":     autoTTL,
+		"comment": r.buildComment(zone, host, ip),
+	}
+}
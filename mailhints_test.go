@@ -0,0 +1,102 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestMailHostHintsFollowMailHostAddress(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "mx1", Name: "example.com", Type: "MX", Content: "mail.example.com"}}
+	ip := "203.0.113.7"
+	cfg := CreateConfig()
+	cfg.Domains = []string{"mail.example.com", "www.example.com"}
+	cfg.MailHostHints = true
+	cfg.ResolveContent = func(host string) (string, error) { return ip, nil }
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	hints := func() []string {
+		var out []string
+		for _, record := range cf.records["z1"] {
+			if record.Type == "TXT" {
+				out = append(out, record.Name+" "+record.Content)
+			}
+		}
+		return out
+	}
+	for _, step := range []struct {
+		ip, want string
+		logged   bool
+	}{
+		{"203.0.113.7", "_fcrdns.mail.example.com ip=203.0.113.7 ptr=7.113.0.203.in-addr.arpa expects=mail.example.com", true},
+		{"203.0.113.7", "_fcrdns.mail.example.com ip=203.0.113.7 ptr=7.113.0.203.in-addr.arpa expects=mail.example.com", false},
+		{"198.51.100.9", "_fcrdns.mail.example.com ip=198.51.100.9 ptr=9.100.51.198.in-addr.arpa expects=mail.example.com", true},
+	} {
+		ip = step.ip
+		logs.Reset()
+		if _, err := r.reconcile(context.Background()); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		if got := hints(); len(got) != 1 || got[0] != step.want {
+			t.Fatalf("ip %s: expected one hint %q, got %v", step.ip, step.want, got)
+		}
+		if logged := strings.Contains(logs.String(), "set reverse DNS"); logged != step.logged {
+			t.Fatalf("ip %s: expected reverse DNS line logged=%v, got:\n%s", step.ip, step.logged, logs.String())
+		}
+	}
+}
+
+func TestMailHostHintsCollapseDuplicatesAndPruneFormerMailHosts(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"mail.example.com"}
+	cfg.MailHostHints = true
+	cfg.CommentTemplate = "ip={ip}"
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	comment := r.managedComment("example.com")
+	cf.records["z1"] = []cfRecord{
+		{ID: "mx1", Name: "example.com", Type: "MX", Content: "mail.example.com"},
+		{ID: "t1", Name: "_fcrdns.mail.example.com", Type: "TXT", Content: "ip=198.51.100.9", Comment: comment},
+		{ID: "t2", Name: "_fcrdns.mail.example.com", Type: "TXT", Content: "ip=198.51.100.10", Comment: comment},
+	}
+
+	hints := func() []cfRecord {
+		var out []cfRecord
+		for _, record := range cf.records["z1"] {
+			if record.Type == "TXT" {
+				out = append(out, record)
+			}
+		}
+		return out
+	}
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	got := hints()
+	want := "ip=203.0.113.7 ptr=7.113.0.203.in-addr.arpa expects=mail.example.com"
+	if len(got) != 1 || got[0].ID != "t1" || got[0].Content != want {
+		t.Fatalf("expected t1 updated and the duplicate deleted, got %+v", got)
+	}
+	if got[0].Comment != comment+" ip=203.0.113.7" {
+		t.Fatalf("expected the hint to carry the rendered comment, got %q", got[0].Comment)
+	}
+
+	var withoutMX []cfRecord
+	for _, record := range cf.records["z1"] {
+		if record.Type != "MX" {
+			withoutMX = append(withoutMX, record)
+		}
+	}
+	cf.records["z1"] = withoutMX
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if got := hints(); len(got) != 0 {
+		t.Fatalf("expected the hint pruned once the host is no longer an MX target, got %+v", got)
+	}
+}
@@ -0,0 +1,130 @@
+package ddns_traefik_plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// maintenanceWindow is one parsed MaintenanceWindows entry.
+// Times are minutes since midnight; end <= start means the window runs past midnight.
+type maintenanceWindow struct {
+	days  [7]bool
+	start int
+	end   int
+}
+
+// parseMaintenanceWindow parses "<days> <HH:MM>-<HH:MM>", where days is "*", "daily",
+// a weekday (Mon), a range (Mon-Fri) or a comma-separated mix (Mon,Wed,Fri-Sun).
+func parseMaintenanceWindow(raw string) (maintenanceWindow, error) {
+	var w maintenanceWindow
+	fields := strings.Fields(raw)
+	if len(fields) != 2 {
+		return w, fmt.Errorf("invalid maintenance window %q: expected \"<days> <HH:MM>-<HH:MM>\"", raw)
+	}
+
+	days := strings.ToLower(fields[0])
+	if days == "*" || days == "daily" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	} else {
+		for _, part := range strings.Split(days, ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, ok := weekdayNames[from]
+			if !ok {
+				return w, fmt.Errorf("invalid maintenance window %q: unknown day %q", raw, from)
+			}
+			last := first
+			if isRange {
+				if last, ok = weekdayNames[to]; !ok {
+					return w, fmt.Errorf("invalid maintenance window %q: unknown day %q", raw, to)
+				}
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return w, fmt.Errorf("invalid maintenance window %q: expected a HH:MM-HH:MM time range", raw)
+	}
+	var err error
+	if w.start, err = parseClockMinutes(from); err != nil {
+		return w, fmt.Errorf("invalid maintenance window %q: %w", raw, err)
+	}
+	if w.end, err = parseClockMinutes(to); err != nil {
+		return w, fmt.Errorf("invalid maintenance window %q: %w", raw, err)
+	}
+	return w, nil
+}
+
+func parseClockMinutes(value string) (int, error) {
+	hh, mm, ok := strings.Cut(value, ":")
+	hours, errH := strconv.Atoi(hh)
+	minutes, errM := strconv.Atoi(mm)
+	// 24:00 is accepted as end of day.
+	if !ok || errH != nil || errM != nil || hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hours*60 + minutes, nil
+}
+
+// contains reports whether t (already in the window's timezone) falls inside the window.
+// Overnight windows belong to the day they start on.
+func (w maintenanceWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	return minute < w.end && w.days[(t.Weekday()+6)%7]
+}
+
+func parseMaintenanceWindows(entries []string) ([]maintenanceWindow, error) {
+	windows := make([]maintenanceWindow, 0, len(entries))
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		w, err := parseMaintenanceWindow(entry)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// writesAllowed reports whether record changes are permitted at now.
+// Without MaintenanceWindows writes are always allowed.
+func (r *Runner) writesAllowed(now time.Time) bool {
+	if len(r.maintenanceWindows) == 0 {
+		return true
+	}
+	local := now.In(r.maintenanceLocation)
+	for _, w := range r.maintenanceWindows {
+		if w.contains(local) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	at := func(day int, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		window string
+		at     time.Time
+		want   bool
+	}{
+		{"Mon-Fri 09:00-17:00", at(1, 9, 0), true},
+		{"Mon-Fri 09:00-17:00", at(1, 17, 0), false},
+		{"Mon-Fri 09:00-17:00", at(6, 10, 0), false},
+		{"Sat,Sun 00:00-24:00", at(7, 23, 59), true},
+		{"Fri-Mon 12:00-13:00", at(1, 12, 30), true},
+		{"Fri-Mon 12:00-13:00", at(3, 12, 30), false},
+		{"Sat 22:00-02:00", at(6, 23, 0), true},
+		{"Sat 22:00-02:00", at(7, 1, 59), true},
+		{"Sat 22:00-02:00", at(7, 22, 30), false},
+		{"daily 02:00-04:00", at(3, 3, 0), true},
+	}
+	for _, tc := range cases {
+		w, err := parseMaintenanceWindow(tc.window)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.window, err)
+		}
+		if got := w.contains(tc.at); got != tc.want {
+			t.Errorf("%q contains %s = %v, want %v", tc.window, tc.at.Format(time.RFC1123), got, tc.want)
+		}
+	}
+}
+
+func TestMaintenanceWindowRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{"Mon-Fri", "Funday 09:00-17:00", "Mon 9-17", "Mon 25:00-26:00", "Mon 09:60-10:00"} {
+		cfg := normalizeConfig(Config{MaintenanceWindows: []string{raw}})
+		if err := validateConfig(cfg); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+	cfg := normalizeConfig(Config{MaintenanceTimezone: "Mars/Olympus"})
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected unknown timezone to be rejected")
+	}
+}
+
+func TestMaintenanceWindowsHoldWrites(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "198.51.100.1"}}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "new.example.com"}
+	cfg.MaintenanceWindows = []string{"Mon-Fri 09:00-17:00"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+	// 2024-01-06 is a Saturday.
+	fake := newFakeClock(time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC))
+	r.clock = fake
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, status := range results {
+		if status.Action != ActionSkipped {
+			t.Fatalf("expected held writes to report skipped, got %+v", status)
+		}
+	}
+	if cf.countCalls(http.MethodPost) != 0 || cf.countCalls(http.MethodPut) != 0 || cf.countCalls(http.MethodPatch) != 0 {
+		t.Fatalf("expected no writes outside maintenance windows, got %v", cf.calls)
+	}
+
+	fake.Advance(45 * time.Hour) // Monday 09:00
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if cf.countCalls(http.MethodPost) != 1 {
+		t.Fatalf("expected held create to apply inside the window, got %v", cf.calls)
+	}
+}
+
+func TestMaintenanceTimezone(t *testing.T) {
+	location, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "token"
+	cfg.MaintenanceWindows = []string{"Mon 09:00-10:00"}
+	cfg.MaintenanceTimezone = "Asia/Tokyo"
+	r, err := newRunner(cfg)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	// Monday 09:30 in Tokyo is Monday 00:30 UTC.
+	if !r.writesAllowed(time.Date(2024, 1, 1, 9, 30, 0, 0, location)) {
+		t.Fatalf("expected window to be evaluated in maintenanceTimezone")
+	}
+	if r.writesAllowed(time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected 09:30 UTC (18:30 Tokyo) to be outside the window")
+	}
+}
@@ -0,0 +1,153 @@
+package ddns_traefik_plugin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// timingBuckets are the histogram upper bounds in seconds, from a cached lookup to a slow API call.
+var timingBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// timingWindow is how long observations are kept before a histogram starts over, so the
+// distribution reflects recent behavior rather than the whole process lifetime.
+const timingWindow = time.Hour
+
+// Timed operations, as named in RunnerStatus.Timings.
+const (
+	timingIPResolution = "ipResolution"
+	timingZoneListing  = "zoneListing"
+	timingDomainSync   = "domainSync"
+)
+
+// timingMetrics maps each timed operation to its Prometheus metric name and help text.
+var timingMetrics = []struct{ key, name, help string }{
+	{timingIPResolution, "ddns_ip_resolution_duration_seconds", "Time to resolve the public IP from the IP sources."},
+	{timingZoneListing, "ddns_zone_listing_duration_seconds", "Time to list the zones visible to the token."},
+	{timingDomainSync, "ddns_domain_sync_duration_seconds", "Time to reconcile one host."},
+}
+
+// TimingSnapshot is a latency histogram as reported by the status endpoint. Buckets are cumulative
+// counts keyed by upper bound in seconds; P50 and P99 are the upper bound of the bucket holding
+// that quantile, or -1 when it falls above the largest bucket.
+type TimingSnapshot struct {
+	Count      uint64            `json:"count"`
+	SumSeconds float64           `json:"sumSeconds"`
+	P50        float64           `json:"p50"`
+	P99        float64           `json:"p99"`
+	Buckets    map[string]uint64 `json:"buckets"`
+	Since      time.Time         `json:"since"`
+}
+
+// histogram counts observations into timingBuckets. It is safe for concurrent use.
+type histogram struct {
+	mu     sync.Mutex
+	since  time.Time
+	counts []uint64 // per bucket, plus one overflow slot
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(now time.Time, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rotate(now)
+	seconds := d.Seconds()
+	i := 0
+	for i < len(timingBuckets) && seconds > timingBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// rotate starts over once the window has passed. Callers hold h.mu.
+func (h *histogram) rotate(now time.Time) {
+	if h.since.IsZero() || now.Sub(h.since) >= timingWindow {
+		h.counts = make([]uint64, len(timingBuckets)+1)
+		h.sum, h.count, h.since = 0, 0, now
+	}
+}
+
+func (h *histogram) snapshot(now time.Time) TimingSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rotate(now)
+	snap := TimingSnapshot{Count: h.count, SumSeconds: h.sum, P50: -1, P99: -1, Buckets: make(map[string]uint64, len(timingBuckets)), Since: h.since}
+	var cumulative uint64
+	for i, bound := range timingBuckets {
+		cumulative += h.counts[i]
+		snap.Buckets[formatBound(bound)] = cumulative
+		if h.count == 0 {
+			continue
+		}
+		if snap.P50 < 0 && cumulative*100 >= h.count*50 {
+			snap.P50 = bound
+		}
+		if snap.P99 < 0 && cumulative*100 >= h.count*99 {
+			snap.P99 = bound
+		}
+	}
+	if h.count == 0 {
+		snap.P50, snap.P99 = 0, 0
+	}
+	return snap
+}
+
+// timings holds one histogram per timed operation.
+type timings struct {
+	byKey map[string]*histogram
+}
+
+func newTimings() *timings {
+	t := &timings{byKey: make(map[string]*histogram, len(timingMetrics))}
+	for _, metric := range timingMetrics {
+		t.byKey[metric.key] = &histogram{counts: make([]uint64, len(timingBuckets)+1)}
+	}
+	return t
+}
+
+// observeSince records the time elapsed from start for key.
+func (r *Runner) observeSince(key string, start time.Time) {
+	now := r.clock.Now()
+	r.timings.byKey[key].observe(now, now.Sub(start))
+}
+
+func (t *timings) snapshot(now time.Time) map[string]TimingSnapshot {
+	out := make(map[string]TimingSnapshot, len(t.byKey))
+	for key, h := range t.byKey {
+		out[key] = h.snapshot(now)
+	}
+	return out
+}
+
+// writePrometheus writes the histograms in the Prometheus text exposition format.
+func (t *timings) writePrometheus(w io.Writer, now time.Time) {
+	for _, metric := range timingMetrics {
+		snap := t.byKey[metric.key].snapshot(now)
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", metric.name, metric.help, metric.name)
+		for _, bound := range timingBuckets {
+			fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", metric.name, formatBound(bound), snap.Buckets[formatBound(bound)])
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", metric.name, snap.Count)
+		fmt.Fprintf(w, "%s_sum %s\n", metric.name, strconv.FormatFloat(snap.SumSeconds, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count %d\n", metric.name, snap.Count)
+	}
+}
+
+func (r *Runner) metricsHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.timings.writePrometheus(rw, r.clock.Now())
+}
+
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
@@ -0,0 +1,78 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramBucketsAndQuantiles(t *testing.T) {
+	h := &histogram{counts: make([]uint64, len(timingBuckets)+1)}
+	now := time.Unix(0, 0)
+	for i := 0; i < 98; i++ {
+		h.observe(now, 20*time.Millisecond)
+	}
+	h.observe(now, 3*time.Second)
+	h.observe(now, time.Minute)
+
+	snap := h.snapshot(now)
+	if snap.Count != 100 || snap.Buckets["0.025"] != 98 || snap.Buckets["5"] != 99 || snap.Buckets["10"] != 99 {
+		t.Fatalf("unexpected buckets: %+v", snap)
+	}
+	if snap.P50 != 0.025 || snap.P99 != 5 {
+		t.Fatalf("expected p50=0.025 p99=5, got p50=%v p99=%v", snap.P50, snap.P99)
+	}
+
+	// Observations older than the window are dropped.
+	snap = h.snapshot(now.Add(timingWindow))
+	if snap.Count != 0 || snap.Buckets["10"] != 0 || snap.P99 != 0 {
+		t.Fatalf("expected histogram to start over after the window, got %+v", snap)
+	}
+}
+
+func TestTimingsPrometheusExposition(t *testing.T) {
+	timings := newTimings()
+	now := time.Unix(0, 0)
+	timings.byKey[timingZoneListing].observe(now, 300*time.Millisecond)
+
+	var out bytes.Buffer
+	timings.writePrometheus(&out, now)
+	for _, want := range []string{
+		"# TYPE ddns_zone_listing_duration_seconds histogram\n",
+		"ddns_zone_listing_duration_seconds_bucket{le=\"0.25\"} 0\n",
+		"ddns_zone_listing_duration_seconds_bucket{le=\"0.5\"} 1\n",
+		"ddns_zone_listing_duration_seconds_bucket{le=\"+Inf\"} 1\n",
+		"ddns_zone_listing_duration_seconds_sum 0.3\n",
+		"ddns_zone_listing_duration_seconds_count 1\n",
+		"ddns_ip_resolution_duration_seconds_count 0\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCycleRecordsTimings(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com", "b.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	status := r.Status()
+	if status.Timings[timingIPResolution].Count != 1 || status.Timings[timingZoneListing].Count != 1 || status.Timings[timingDomainSync].Count != 2 {
+		t.Fatalf("unexpected timing counts: %+v", status.Timings)
+	}
+
+	rec := httptest.NewRecorder()
+	r.statusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ddns_domain_sync_duration_seconds_count 2\n") {
+		t.Fatalf("unexpected /metrics response %d:\n%s", rec.Code, rec.Body.String())
+	}
+}
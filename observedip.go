@@ -0,0 +1,86 @@
+package ddns_traefik_plugin
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// observedIPSamples is the size of the rolling window of request source IPs.
+const observedIPSamples = 64
+
+// observedIPs is a lock-free ring of recent request source IPv4 addresses.
+// ServeHTTP only does two atomic operations per request; the worker reads the ring once per cycle.
+type observedIPs struct {
+	cursor  uint32
+	samples [observedIPSamples]uint32
+}
+
+func (o *observedIPs) add(ip net.IP) {
+	v4 := ip.To4()
+	if v4 == nil {
+		return
+	}
+	slot := (atomic.AddUint32(&o.cursor, 1) - 1) % observedIPSamples
+	atomic.StoreUint32(&o.samples[slot], binary.BigEndian.Uint32(v4))
+}
+
+// mostCommon returns the most frequent sampled address, or "" when nothing was sampled.
+func (o *observedIPs) mostCommon() string {
+	counts := make(map[uint32]int, observedIPSamples)
+	var best uint32
+	for i := range o.samples {
+		v := atomic.LoadUint32(&o.samples[i])
+		if v == 0 {
+			continue
+		}
+		counts[v]++
+		if counts[v] > counts[best] {
+			best = v
+		}
+	}
+	if best == 0 {
+		return ""
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, best)
+	return ip.String()
+}
+
+// requestSourceIP returns the client address of req. X-Forwarded-For is honored only when the
+// direct peer is a trusted proxy, and then only depth hops from the right, which are the entries
+// appended by trusted proxies; anything further left is client-controlled.
+func requestSourceIP(req *http.Request, trusted []*net.IPNet, depth int) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || depth <= 0 || !ipInNets(peer, trusted) {
+		return peer
+	}
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) < depth {
+		return nil
+	}
+	return net.ParseIP(hops[len(hops)-depth])
+}
+
+// isPublicIPv4 filters samples that can never be this site's public address.
+func isPublicIPv4(ip net.IP) bool {
+	v4 := ip.To4()
+	if v4 == nil {
+		return false
+	}
+	return !v4.IsPrivate() && !v4.IsLoopback() && !v4.IsLinkLocalUnicast() && !v4.IsUnspecified() &&
+		!v4.IsMulticast() && !ipInNets(v4, cloudflareIPRanges)
+}
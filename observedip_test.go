@@ -0,0 +1,147 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestSourceIP(t *testing.T) {
+	trusted := mustParseCIDRs("10.0.0.0/8")
+	cases := []struct {
+		name   string
+		remote string
+		xff    string
+		depth  int
+		want   string
+	}{
+		{"untrusted peer ignores header", "198.51.100.9:1234", "203.0.113.7", 1, "198.51.100.9"},
+		{"trusted peer uses rightmost hop", "10.0.0.2:1234", "1.2.3.4, 203.0.113.7", 1, "203.0.113.7"},
+		{"depth skips proxy hops", "10.0.0.2:1234", "1.2.3.4, 203.0.113.7, 10.0.0.3", 2, "203.0.113.7"},
+		{"too few hops", "10.0.0.2:1234", "203.0.113.7", 2, "<nil>"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		req.Header.Set("X-Forwarded-For", tc.xff)
+		if got := requestSourceIP(req, trusted, tc.depth).String(); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestObservedIPsMostCommon(t *testing.T) {
+	var o observedIPs
+	if got := o.mostCommon(); got != "" {
+		t.Fatalf("expected no sample, got %q", got)
+	}
+	for i := 0; i < observedIPSamples; i++ {
+		o.add(net.ParseIP("203.0.113.7"))
+	}
+	// Older samples roll out of the window.
+	for i := 0; i < observedIPSamples/2+1; i++ {
+		o.add(net.ParseIP("198.51.100.9"))
+	}
+	if got := o.mostCommon(); got != "198.51.100.9" {
+		t.Fatalf("expected most common recent ip, got %q", got)
+	}
+}
+
+func TestObservedIPUsedWhenSourcesFail(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	r := newTestRunner(t, cfg, cf, "not-an-ip")
+
+	if _, err := r.reconcile(context.Background()); err == nil {
+		t.Fatalf("expected resolution failure without observed ips")
+	}
+
+	r.observed.add(net.ParseIP("203.0.113.7"))
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].IP != "203.0.113.7" || results[0].Action != ActionCreated {
+		t.Fatalf("expected record created with observed ip, got %+v", results)
+	}
+}
+
+func TestFallbackIPsSkipDenyIPs(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "198.51.100.1"}}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.DenyIPs = []string{"10.0.0.0/8"}
+	cfg.OnIPResolutionFailure = ipFailureUseLastKnown
+	r := newTestRunner(t, cfg, cf, "not-an-ip")
+	var buf bytes.Buffer
+	r.logger = log.New(&buf, "", 0)
+	r.observed.add(net.ParseIP("10.1.2.3"))
+	r.lastKnownIP = "10.4.5.6"
+
+	if _, err := r.reconcile(context.Background()); err == nil {
+		t.Fatalf("expected resolution failure when every fallback ip is denied")
+	}
+	if n := cf.countCalls("PUT") + cf.countCalls("PATCH") + cf.countCalls("POST"); n != 0 {
+		t.Fatalf("expected no writes with denied fallback ips, got %v", cf.calls)
+	}
+	for _, want := range []string{"not using observed request ip 10.1.2.3", "not reusing last known ip 10.4.5.6"} {
+		found := false
+		for _, line := range strings.Split(buf.String(), "\n") {
+			found = found || (strings.HasPrefix(line, "[WARN] ") && strings.Contains(line, want))
+		}
+		if !found {
+			t.Fatalf("expected a warning %q, got %q", want, buf.String())
+		}
+	}
+
+	r.lastKnownIP = "203.0.113.7"
+	results, err := r.reconcile(context.Background())
+	if err != nil || len(results) != 1 || results[0].IP != "203.0.113.7" {
+		t.Fatalf("expected the allowed last known ip to be used, got %+v (%v)", results, err)
+	}
+}
+
+func TestServeHTTPSamplesOnlyWhenEnabled(t *testing.T) {
+	resetGlobalRunner()
+	defer resetGlobalRunner()
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+	cfg := CreateConfig()
+	cfg.APIToken = "token"
+	cfg.Enabled = false
+	plain, err := New(context.Background(), next, cfg, "plain")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	cfg.ObserveRequestIP = true
+	observing, err := New(context.Background(), next, cfg, "observing")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, handler := range []http.Handler{plain, observing} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.1.10:4000"
+	observing.ServeHTTP(httptest.NewRecorder(), req)
+
+	counts := 0
+	for _, v := range globalRunner.observed.samples {
+		if v != 0 {
+			counts++
+		}
+	}
+	if counts != 1 || globalRunner.observed.mostCommon() != "203.0.113.7" {
+		t.Fatalf("expected one public sample from the observing middleware, got %d (%s)", counts, globalRunner.observed.mostCommon())
+	}
+}
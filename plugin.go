@@ -2,20 +2,74 @@ package ddns_traefik_plugin
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
+	"unicode"
 )
 
 // Host(...) parser used to extract static domains from router rules.
-var hostCallPattern = regexp.MustCompile(`Host\(([^)]*)\)`)
-var backtickPattern = regexp.MustCompile("`([^`]+)`")
+// The argument may span lines, and whitespace may separate Host from its parenthesis.
+var hostCallPattern = regexp.MustCompile(`Host\s*\(([^)]*)\)`)
+
+// hostSNICallPattern matches the HostSNI(...) matcher of TCP routers, read only by extractHostPorts.
+var hostSNICallPattern = regexp.MustCompile(`HostSNI\s*\(([^)]*)\)`)
+
+// hostLiteralPattern matches backtick, double- and single-quoted host literals;
+// Traefik accepts all three in Host(...) depending on version and provider.
+var hostLiteralPattern = regexp.MustCompile("`([^`]+)`|\"([^\"]+)\"|'([^']+)'")
+
+// ruleEscapes undoes quote escaping left in rules serialized into labels or JSON strings,
+// for example Host(\`app.example.com\`), which would otherwise leave a backslash in the host.
+var ruleEscapes = strings.NewReplacer("\\`", "`", `\"`, `"`, `\'`, "'")
+
+// Record fields that can be selected in Config.ReconcileFields.
+const (
+	reconcileContent = "content"
+	reconcileProxied = "proxied"
+	reconcileTTL     = "ttl"
+	reconcileComment = "comment"
+)
+
+// defaultMinSyncIntervalSeconds is the sync interval floor. clampSyncInterval reads
+// MIN_SYNC_INTERVAL_SECONDS at validate time, each time a config is checked and applied, to override it.
+const defaultMinSyncIntervalSeconds = 30
+
+// maxCommentLength is the longest DNS record comment Cloudflare accepts on non-enterprise plans.
+const maxCommentLength = 100
+
+// defaultManagedComment is the plugin's ManagedComment; cliManagedComment is MANAGED_COMMENT's default
+// in the CLI. Both are owned by default so records survive a switch between the two.
+const (
+	defaultManagedComment = "managed-by=traefik-plugin-ddns"
+	cliManagedComment     = "managed-by=ddns-traefik-sync"
+)
+
+var defaultOwnedComments = []string{defaultManagedComment, cliManagedComment}
+
+// autoTTL is Cloudflare's "automatic" TTL value and the default for Config.TTL.
+const autoTTL = 1
+
+var defaultReconcileFields = []string{reconcileContent}
+
+// OnIPResolutionFailure policies.
+const (
+	ipFailureSkip         = "skip"
+	ipFailureUseLastKnown = "use-last-known"
+	ipFailureAlert        = "alert"
+)
 
 var defaultIPSources = []string{
 	"https://api.ipify.org",
@@ -34,10 +88,31 @@ var (
 type Config struct {
 	// Enabled controls whether this middleware instance registers domains with the global worker.
 	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// Primary makes this middleware authoritative for runner-wide settings (token, zone, account, timing, sync
+	// behavior); other middlewares contribute hosts and their proxied/TTL settings. At most one middleware may
+	// set it. Without a primary the first middleware Traefik initializes wins, which can change across reloads.
+	// FailOnEmpty is not taken from the primary; see FailOnEmpty.
+	Primary bool `json:"primary,omitempty" yaml:"primary,omitempty"`
+	// HostConflictPolicy decides whose DefaultProxied, TTL and DomainOverrides apply to a host registered by
+	// several middlewares with different settings: primary (the primary middleware when it registers the
+	// host, else the most recently registered one) or last (always the most recently registered one).
+	// Conflicts are logged. Default: primary.
+	HostConflictPolicy string `json:"hostConflictPolicy,omitempty" yaml:"hostConflictPolicy,omitempty"`
 	// APIToken is the Cloudflare API token (required).
 	APIToken string `json:"apiToken,omitempty" yaml:"apiToken,omitempty"`
 	// Zone optionally restricts management to one Cloudflare zone (example: example.com).
 	Zone string `json:"zone,omitempty" yaml:"zone,omitempty"`
+	// AccountID optionally restricts zone listing to one Cloudflare account (recommended for multi-account tokens).
+	AccountID string `json:"accountId,omitempty" yaml:"accountId,omitempty"`
+	// StaticZones replaces zone listing entirely: zones are matched against this list and the zones API is never
+	// called, for firewalled setups where listing zones is blocked. Default: unset (list zones every cycle).
+	StaticZones []StaticZone `json:"staticZones,omitempty" yaml:"staticZones,omitempty"`
+	// AllowPartialZoneList keeps the zones already fetched when a later page of the zone listing fails, logging
+	// a warning that the list may be incomplete, so hosts in those zones still sync. Default: false (the cycle fails).
+	AllowPartialZoneList bool `json:"allowPartialZoneList,omitempty" yaml:"allowPartialZoneList,omitempty"`
+	// InitialDelaySeconds delays the first sync cycle after startup so Traefik can load all dynamic config.
+	// Default: 0 (sync immediately).
+	InitialDelaySeconds int `json:"initialDelaySeconds,omitempty" yaml:"initialDelaySeconds,omitempty"`
 	// SyncIntervalSeconds defines how often DNS checks run. Default: 300.
 	SyncIntervalSeconds int `json:"syncIntervalSeconds,omitempty" yaml:"syncIntervalSeconds,omitempty"`
 	// RequestTimeoutSeconds is the timeout for HTTP calls to IP providers and Cloudflare. Default: 10.
@@ -46,34 +121,428 @@ type Config struct {
 	AutoDiscoverHost bool `json:"autoDiscoverHost,omitempty" yaml:"autoDiscoverHost,omitempty"`
 	// RouterRule is a Traefik router rule string (for example Host(`app.example.com`)).
 	RouterRule string `json:"routerRule,omitempty" yaml:"routerRule,omitempty"`
+	// RouterRules lists additional router rules; hosts from all rules (and RouterRule) are merged.
+	RouterRules []string `json:"routerRules,omitempty" yaml:"routerRules,omitempty"`
 	// Domains is a manual list of FQDNs to always manage.
 	Domains []string `json:"domains,omitempty" yaml:"domains,omitempty"`
 	// DomainsCSV is an alternative manual input for domains: comma-separated values.
 	DomainsCSV string `json:"domainsCsv,omitempty" yaml:"domainsCsv,omitempty"`
+	// SelfHost is the host name the plugin's own infrastructure (Traefik dashboard, status endpoint) is routed
+	// on. It and the host part of StatusAddr are left out of the managed set unless ManageSelfHost is set.
+	SelfHost string `json:"selfHost,omitempty" yaml:"selfHost,omitempty"`
+	// ManageSelfHost keeps SelfHost and the StatusAddr host in the managed set.
+	ManageSelfHost bool `json:"manageSelfHost,omitempty" yaml:"manageSelfHost,omitempty"`
+	// AllowReservedTLDs manages hosts under special-use TLDs (localhost, test, invalid, example, local), which
+	// are otherwise dropped from discovery and Domains because they can never exist in Cloudflare.
+	AllowReservedTLDs bool `json:"allowReservedTlds,omitempty" yaml:"allowReservedTlds,omitempty"`
+	// PreserveHostCase sends record names with the casing they were written with in Domains, DomainsCSV or
+	// a router rule, for display in the dashboard. Existing records still match case-insensitively. Default:
+	// false (lowercase).
+	PreserveHostCase bool `json:"preserveHostCase,omitempty" yaml:"preserveHostCase,omitempty"`
+	// ExcludeDomains lists hosts this middleware never manages, even if discovered or listed in Domains.
+	ExcludeDomains []string `json:"excludeDomains,omitempty" yaml:"excludeDomains,omitempty"`
+	// AutoWWW also manages www.<apex> for every managed apex host (host equal to its zone name).
+	AutoWWW bool `json:"autoWww,omitempty" yaml:"autoWww,omitempty"`
+	// NameTemplate rewrites the managed record name. Placeholders: {host} (full host), {label} (host without zone),
+	// {zone}. Example: {label}.dyn.{zone} manages app.dyn.example.com for app.example.com. Default: unset (host as-is).
+	NameTemplate string `json:"nameTemplate,omitempty" yaml:"nameTemplate,omitempty"`
+	// AllowApexCNAMEOverride replaces a (flattened) CNAME at a zone apex with the managed A record.
+	// Without it an apex that already has a CNAME is skipped with a warning.
+	AllowApexCNAMEOverride bool `json:"allowApexCnameOverride,omitempty" yaml:"allowApexCnameOverride,omitempty"`
 	// DefaultProxied is applied only when creating new A records.
 	DefaultProxied bool `json:"defaultProxied,omitempty" yaml:"defaultProxied,omitempty"`
+	// TTL is the record TTL in seconds; 1 means automatic. Default: 1.
+	TTL int `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	// ZoneDefaults overrides DefaultProxied and TTL for records in a zone, keyed by zone name.
+	ZoneDefaults map[string]RecordDefaults `json:"zoneDefaults,omitempty" yaml:"zoneDefaults,omitempty"`
+	// ZoneComments overrides ManagedComment for records in a zone, keyed by zone name. Ownership in that
+	// zone is then judged on the zone's comment (or AdditionalOwnedComments), not on ManagedComment.
+	ZoneComments map[string]string `json:"zoneComments,omitempty" yaml:"zoneComments,omitempty"`
+	// DomainOverrides overrides ZoneDefaults, DefaultProxied and TTL for one record name.
+	DomainOverrides map[string]RecordDefaults `json:"domainOverrides,omitempty" yaml:"domainOverrides,omitempty"`
 	// IPSources is the ordered list of public IP endpoints.
 	IPSources []string `json:"ipSources,omitempty" yaml:"ipSources,omitempty"`
+	// AppendDefaultIPSources keeps the built-in IP sources as fallbacks after a custom IPSources list.
+	AppendDefaultIPSources bool `json:"appendDefaultIpSources,omitempty" yaml:"appendDefaultIpSources,omitempty"`
+	// MaxCreatesPerCycle caps new records per cycle as a guard against runaway discovery; the remaining
+	// creates are deferred to later cycles with a warning. Default: 0 (unlimited).
+	MaxCreatesPerCycle int `json:"maxCreatesPerCycle,omitempty" yaml:"maxCreatesPerCycle,omitempty"`
+	// HostBackoffMaxCycles caps how many cycles a host that keeps failing is skipped for. Backoff starts
+	// after 3 consecutive failures at 1 cycle and doubles with every further failure. Default: 16.
+	HostBackoffMaxCycles int `json:"hostBackoffMaxCycles,omitempty" yaml:"hostBackoffMaxCycles,omitempty"`
+	// UnchangedLogWindowSeconds compacts the logs of stable setups: a host's "already synced" line is not
+	// repeated while the host stayed unchanged since the previous cycle, for up to this long, and each cycle
+	// logs one "N domains unchanged" summary instead. Default: 0 (log every host every cycle).
+	UnchangedLogWindowSeconds int `json:"unchangedLogWindowSeconds,omitempty" yaml:"unchangedLogWindowSeconds,omitempty"`
+	// CycleBudgetSeconds is a wall-clock budget for a whole cycle. Once it is used up no further hosts are
+	// started: the cycle logs how far it got, reports the rest as skipped and syncs them first next cycle.
+	// SRV, AAAA, mail hint and prune steps wait for a cycle that fits. Default: 0 (no budget).
+	CycleBudgetSeconds int `json:"cycleBudgetSeconds,omitempty" yaml:"cycleBudgetSeconds,omitempty"`
+	// AdoptExistingOnFirstRun stamps ManagedComment onto existing uncommented A records of managed hosts
+	// the first time a zone is synced, bringing them under ownership (pruning included). Default: false.
+	AdoptExistingOnFirstRun bool `json:"adoptExistingOnFirstRun,omitempty" yaml:"adoptExistingOnFirstRun,omitempty"`
+	// PruneStale deletes A records carrying ManagedComment whose host is no longer registered.
+	PruneStale bool `json:"pruneStale,omitempty" yaml:"pruneStale,omitempty"`
+	// PruneGracePeriodSeconds is how long a host must stay absent before its record is pruned. Default: 0.
+	PruneGracePeriodSeconds int `json:"pruneGracePeriodSeconds,omitempty" yaml:"pruneGracePeriodSeconds,omitempty"`
+	// PruneObserveUntil is a staged rollout period after startup during which prune only logs
+	// "[PRUNE-OBSERVE] would delete" lines: a duration such as "72h" or a cycle count such as "20cycles".
+	// Default: unset (prune deletes right away).
+	PruneObserveUntil string `json:"pruneObserveUntil,omitempty" yaml:"pruneObserveUntil,omitempty"`
+	// FailOnEmpty makes startup fail when the first middleware registers no hosts. It is only checked on the
+	// middleware that starts the worker (the first one Traefik initializes), whether or not that one sets
+	// Primary; set it on every middleware that could start first.
+	FailOnEmpty bool `json:"failOnEmpty,omitempty" yaml:"failOnEmpty,omitempty"`
+	// CustomHostnameMode provisions hosts as Cloudflare for SaaS custom hostnames in Zone instead of A records.
+	CustomHostnameMode bool `json:"customHostnameMode,omitempty" yaml:"customHostnameMode,omitempty"`
+	// CustomHostnameSSLMethod is the certificate validation method for custom hostnames: http, txt or email. Default: http.
+	CustomHostnameSSLMethod string `json:"customHostnameSslMethod,omitempty" yaml:"customHostnameSslMethod,omitempty"`
+	// RejectCloudflareIPs ignores IP source answers inside Cloudflare's edge ranges and tries the next source.
+	// Prevents writing a proxy IP as the origin. Default: true.
+	RejectCloudflareIPs bool `json:"rejectCloudflareIps,omitempty" yaml:"rejectCloudflareIps,omitempty"`
+	// DenyIPs lists addresses and CIDRs that are never published: an IP source answering with one is
+	// skipped and the next source tried, for known-bad answers such as a sinkhole or an old VPN exit.
+	DenyIPs []string `json:"denyIps,omitempty" yaml:"denyIps,omitempty"`
+	// RequireActiveZone skips hosts whose zone is not active in Cloudflare (for example pending activation
+	// or moved to another account), warning once per zone, instead of failing every write. Default: true.
+	RequireActiveZone bool `json:"requireActiveZone,omitempty" yaml:"requireActiveZone,omitempty"`
+	// HealthCheckURL, when set, is probed at the start of each cycle; if it fails, no records are created or updated.
+	HealthCheckURL string `json:"healthCheckUrl,omitempty" yaml:"healthCheckUrl,omitempty"`
+	// HealthCheckTimeoutSeconds is the per-attempt timeout for HealthCheckURL. Default: 5.
+	HealthCheckTimeoutSeconds int `json:"healthCheckTimeoutSeconds,omitempty" yaml:"healthCheckTimeoutSeconds,omitempty"`
+	// FailoverIP is published instead of the resolved public IP after FailoverThreshold consecutive failed
+	// health checks, until the same number of consecutive healthy checks. Requires HealthCheckURL.
+	FailoverIP string `json:"failoverIp,omitempty" yaml:"failoverIp,omitempty"`
+	// FailoverThreshold is the number of consecutive checks needed to fail over or fail back. Default: 3.
+	FailoverThreshold int `json:"failoverThreshold,omitempty" yaml:"failoverThreshold,omitempty"`
+	// MaintenanceWindows limits record changes to approved windows such as "Mon-Fri 09:00-17:00" or "Sat 22:00-02:00".
+	// Outside every window the plugin still resolves and diffs, but only logs the changes it would make.
+	// Default: unset (changes allowed at any time).
+	MaintenanceWindows []string `json:"maintenanceWindows,omitempty" yaml:"maintenanceWindows,omitempty"`
+	// MaintenanceTimezone is the IANA timezone MaintenanceWindows are evaluated in. Default: UTC.
+	MaintenanceTimezone string `json:"maintenanceTimezone,omitempty" yaml:"maintenanceTimezone,omitempty"`
+	// DryRun resolves and diffs every cycle like normal but never writes to Cloudflare. Default: false.
+	DryRun bool `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
+	// DryRunFormat is how DryRun reports changes: "log" for one line per change, or "plan" for a grouped
+	// per-host diff printed to stdout at the end of each cycle. Default: log.
+	DryRunFormat string `json:"dryRunFormat,omitempty" yaml:"dryRunFormat,omitempty"`
+	// ObserveRequestIP samples the source IP of requests passing through this middleware and uses the most
+	// common public IPv4 among recent samples when every IP source fails. Easily spoofed: leave TrustedProxies
+	// empty unless Traefik sits behind proxies you control.
+	ObserveRequestIP bool `json:"observeRequestIp,omitempty" yaml:"observeRequestIp,omitempty"`
+	// TrustedProxies lists CIDRs whose X-Forwarded-For header is honored when ObserveRequestIP is on.
+	TrustedProxies []string `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty"`
+	// ForwardedForDepth is how many X-Forwarded-For entries, counted from the right, trusted proxies append.
+	// The entry at that depth is used as the client IP. Default: 1.
+	ForwardedForDepth int `json:"forwardedForDepth,omitempty" yaml:"forwardedForDepth,omitempty"`
+	// AdaptiveIPSources tries the historically most reliable and fastest IP source first instead of
+	// keeping the configured order. The configured order is used until stats exist.
+	AdaptiveIPSources bool `json:"adaptiveIpSources,omitempty" yaml:"adaptiveIpSources,omitempty"`
+	// AdaptiveIPSourcesResetSeconds is how often the adaptive stats are discarded. Default: 86400.
+	AdaptiveIPSourcesResetSeconds int `json:"adaptiveIpSourcesResetSeconds,omitempty" yaml:"adaptiveIpSourcesResetSeconds,omitempty"`
+	// StickyIPSource starts each lookup at the source that last returned a valid address, falling back
+	// through the ones after it, instead of at the top of the list. Default: false.
+	StickyIPSource bool `json:"stickyIpSource,omitempty" yaml:"stickyIpSource,omitempty"`
+	// StrictIPParsing requires an IP source answer to be exactly one address after trimming whitespace.
+	// By default the address is also found inside quotes, a JSON array or before a trailing comment.
+	StrictIPParsing bool `json:"strictIpParsing,omitempty" yaml:"strictIpParsing,omitempty"`
+	// RejectIPv4Mapped skips IP source answers in IPv4-mapped IPv6 form (::ffff:203.0.113.8) and tries the
+	// next source. Default: false (they are unwrapped to 203.0.113.8).
+	RejectIPv4Mapped bool `json:"rejectIpv4Mapped,omitempty" yaml:"rejectIpv4Mapped,omitempty"`
+	// StrictIPContentType rejects IP source answers not served as text/plain or application/json, so a
+	// captive portal or error page containing an IP-like string is never used. Default: false.
+	StrictIPContentType bool `json:"strictIpContentType,omitempty" yaml:"strictIpContentType,omitempty"`
+	// InsecureSkipVerifyIPSources disables TLS verification for IP source lookups only (self-signed echo services).
+	// Cloudflare API calls always verify certificates.
+	InsecureSkipVerifyIPSources bool `json:"insecureSkipVerifyIpSources,omitempty" yaml:"insecureSkipVerifyIpSources,omitempty"`
+	// SkipComment marks records the plugin must never touch: any record whose comment contains it
+	// is ignored for updates and pruning, and its host is left alone (example: ddns:ignore).
+	SkipComment string `json:"skipComment,omitempty" yaml:"skipComment,omitempty"`
+	// SeedFromZoneExport lists each zone's A records in bulk and reconciles hosts against that in-memory
+	// view instead of looking up every host, which saves most read calls for zones with many hosts.
+	// The plugin's own writes update the view; other changes are seen at the next refresh.
+	SeedFromZoneExport bool `json:"seedFromZoneExport,omitempty" yaml:"seedFromZoneExport,omitempty"`
+	// SeedRefreshSeconds is how long a zone seed is reused before it is fetched again. Default: 3600.
+	SeedRefreshSeconds int `json:"seedRefreshSeconds,omitempty" yaml:"seedRefreshSeconds,omitempty"`
+	// RespectExternalDNS leaves alone every host that external-dns claims through its TXT registry
+	// (heritage=external-dns), so both tools do not overwrite each other in a shared zone.
+	RespectExternalDNS bool `json:"respectExternalDns,omitempty" yaml:"respectExternalDns,omitempty"`
+	// RecordTags are written as Cloudflare record tags (for example env:prod) on every create and update.
+	// Tags are Enterprise-only; when the API rejects them tagging is disabled with a warning. Default: unset.
+	RecordTags []string `json:"recordTags,omitempty" yaml:"recordTags,omitempty"`
 	// ManagedComment is added to newly created records.
 	ManagedComment string `json:"managedComment,omitempty" yaml:"managedComment,omitempty"`
+	// AdditionalOwnedComments are older managed comments that still mark a record as owned, for
+	// ownership checks and pruning, so records created by the CLI or an earlier version are not
+	// orphaned. Default: the plugin and CLI defaults while ManagedComment is the default, so instances
+	// with their own comment never claim records of other instances; an explicit empty list means none.
+	AdditionalOwnedComments []string `json:"additionalOwnedComments,omitempty" yaml:"additionalOwnedComments,omitempty"`
+	// CommentTemplate is appended to ManagedComment on every create and update, with {host}, {ip}, {time}
+	// (RFC 3339, UTC) and {instance} (this machine's host name) filled in per record. Ownership still
+	// matches on the ManagedComment prefix. Default: unset.
+	CommentTemplate string `json:"commentTemplate,omitempty" yaml:"commentTemplate,omitempty"`
+	// SRVRecords are SRV records kept alongside the A records, for services behind Traefik TCP routers.
+	// Each Target should be a host this plugin manages so the service follows the dynamic IP.
+	SRVRecords []SRVRecord `json:"srvRecords,omitempty" yaml:"srvRecords,omitempty"`
+	// FollowHosts points a managed host at whatever a reference hostname currently resolves to, keyed by host,
+	// instead of the public IP. The reference is looked up through DNS (cached for a minute) and must answer
+	// with a public IPv4 address; otherwise the host is skipped for the cycle. Default: unset.
+	FollowHosts map[string]string `json:"followHosts,omitempty" yaml:"followHosts,omitempty"`
+	// IPv6SuffixPerHost publishes an AAAA record for each listed host: the delegated prefix of the current
+	// public IPv6 address (IPv6PrefixLen bits) followed by the host's fixed interface identifier, for example
+	// "::1a2b:3c4d:5e6f:7080". Listed hosts must also be managed. Default: unset (no AAAA records).
+	IPv6SuffixPerHost map[string]string `json:"ipv6SuffixPerHost,omitempty" yaml:"ipv6SuffixPerHost,omitempty"`
+	// IPv6PrefixLen is the length of the delegated prefix, for example 56. Required with IPv6SuffixPerHost.
+	IPv6PrefixLen int `json:"ipv6PrefixLen,omitempty" yaml:"ipv6PrefixLen,omitempty"`
+	// IPv6Sources return the public IPv6 address the delegated prefix is taken from. Default: built-in list.
+	IPv6Sources []string `json:"ipv6Sources,omitempty" yaml:"ipv6Sources,omitempty"`
+	// HostFamilies picks the address families reconciled for a host: v4 (A record), v6 (AAAA record, which
+	// also needs an IPv6SuffixPerHost entry) or both, for example {"legacy.example.com": ["v4"]}.
+	HostFamilies map[string][]string `json:"hostFamilies,omitempty" yaml:"hostFamilies,omitempty"`
+	// DefaultFamilies applies to hosts not listed in HostFamilies. Default: v4, v6.
+	DefaultFamilies []string `json:"defaultFamilies,omitempty" yaml:"defaultFamilies,omitempty"`
+	// MailHostHints publishes a TXT at _fcrdns.<host> for every managed host an MX record in its zone points at,
+	// recording its address and the PTR name, and logs the reverse DNS to request from the ISP when the address
+	// changes. PTR records themselves stay with the address owner. Default: false.
+	MailHostHints bool `json:"mailHostHints,omitempty" yaml:"mailHostHints,omitempty"`
+	// OnIPResolutionFailure decides what a cycle does when every IP source fails: skip (leave records
+	// untouched), use-last-known (reconcile with the last resolved IP) or alert (skip and call WebhookURL).
+	// Default: skip.
+	OnIPResolutionFailure string `json:"onIpResolutionFailure,omitempty" yaml:"onIpResolutionFailure,omitempty"`
+	// WebhookURL receives a JSON POST for alert events.
+	WebhookURL string `json:"webhookUrl,omitempty" yaml:"webhookUrl,omitempty"`
+	// WebhookOnChange also POSTs a records_changed event to WebhookURL after a cycle that created or
+	// updated records. Default: false.
+	WebhookOnChange bool `json:"webhookOnChange,omitempty" yaml:"webhookOnChange,omitempty"`
+	// WebhookBatchWindowSeconds debounces records_changed events: changes are collected until none arrived
+	// for this long and then sent as one event. Default: 0 (one event per cycle).
+	WebhookBatchWindowSeconds int `json:"webhookBatchWindowSeconds,omitempty" yaml:"webhookBatchWindowSeconds,omitempty"`
+	// WebhookBatchMaxDelaySeconds caps how long a batched change waits, however often new changes re-arm
+	// the window. Default: 10 times WebhookBatchWindowSeconds.
+	WebhookBatchMaxDelaySeconds int `json:"webhookBatchMaxDelaySeconds,omitempty" yaml:"webhookBatchMaxDelaySeconds,omitempty"`
+	// StatusAddr is a listen address (for example :8099) for the GET /status endpoint and the
+	// POST /pause and POST /resume control endpoints. Default: unset (no server).
+	StatusAddr string `json:"statusAddr,omitempty" yaml:"statusAddr,omitempty"`
+	// ControlToken must be sent as "Authorization: Bearer <token>" to use the control endpoints.
+	// Control endpoints are disabled while it is empty.
+	ControlToken string `json:"controlToken,omitempty" yaml:"controlToken,omitempty"`
+	// ReportFile is a path rewritten atomically after every cycle with a JSON report: timestamps, public IP,
+	// per-action counts and per-domain statuses. Default: unset.
+	ReportFile string `json:"reportFile,omitempty" yaml:"reportFile,omitempty"`
+	// LastErrorFile is a path written atomically with the error details after a cycle that failed or had a
+	// failed domain, and removed after a fully successful cycle, for monitors that only watch a file. Default: unset.
+	LastErrorFile string `json:"lastErrorFile,omitempty" yaml:"lastErrorFile,omitempty"`
+	// EventSocket is a Unix socket path where cycle starts and ends, per-domain changes and errors are
+	// streamed as JSON lines to every connected client. Slow clients miss events instead of blocking the worker.
+	EventSocket string `json:"eventSocket,omitempty" yaml:"eventSocket,omitempty"`
+	// OnCycleComplete is called after every sync cycle with per-domain results and the cycle-level error, if any.
+	// Only available when embedding the runner as a library; panics are recovered and logged.
+	OnCycleComplete func(results []DomainStatus, err error) `json:"-" yaml:"-"`
+	// ResolveContent, when set, picks the IPv4 address for each host in place of the public IP. A host
+	// whose call fails or returns an invalid address is skipped for the cycle. Only available when
+	// embedding the runner as a library; panics are recovered and count as errors.
+	ResolveContent func(host string) (ip string, err error) `json:"-" yaml:"-"`
+	// LogRateLimitPerMinute caps WARN and ERROR lines of the same message type (for example "domain=... sync
+	// failed" across all domains) to this many per minute, with a burst of the same size. Dropped lines are
+	// counted and summarized once per cycle. Default: 0 (unlimited).
+	LogRateLimitPerMinute int `json:"logRateLimitPerMinute,omitempty" yaml:"logRateLimitPerMinute,omitempty"`
+	// ReconcileFields lists record fields whose drift triggers an update: content, proxied, ttl, comment.
+	// Content is always compared. Default: content.
+	ReconcileFields []string `json:"reconcileFields,omitempty" yaml:"reconcileFields,omitempty"`
+}
+
+// RecordDefaults are the proxied and TTL settings of one ZoneDefaults or DomainOverrides entry.
+// An unset Proxied or a TTL of 0 inherits from the next less specific level.
+type RecordDefaults struct {
+	Proxied *bool `json:"proxied,omitempty" yaml:"proxied,omitempty"`
+	TTL     int   `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
+
+// apply layers d over proxied and ttl, keeping whichever of them d leaves unset.
+func (d RecordDefaults) apply(proxied bool, ttl int) (bool, int) {
+	if d.Proxied != nil {
+		proxied = *d.Proxied
+	}
+	if d.TTL > 0 {
+		ttl = d.TTL
+	}
+	return proxied, ttl
+}
+
+// Actions reported in DomainStatus.Action.
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+	ActionSkipped   = "skipped"
+	ActionFailed    = "failed"
+)
+
+// DomainStatus is the outcome of reconciling one host during a sync cycle.
+type DomainStatus struct {
+	Domain string `json:"domain"`
+	// Record is the managed record name when NameTemplate rewrites Domain.
+	Record string `json:"record,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// secretConfigFields are masked by Config.String. Webhook URLs often embed a token in their path.
+var secretConfigFields = map[string]bool{"apiToken": true, "controlToken": true, "webhookUrl": true}
+
+// String renders the configuration as an aligned key/value table with secrets masked.
+func (c Config) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		value := v.Field(i).Interface()
+		if secretConfigFields[name] {
+			value = "(unset)"
+			if v.Field(i).String() != "" {
+				value = "(redacted)"
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%v\n", name, value)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(b.String(), "\n")
 }
 
 type Middleware struct {
 	next http.Handler
 	name string
+
+	// observed is set when ObserveRequestIP is on; request source IPs are sampled into it.
+	observed *observedIPs
+	trusted  []*net.IPNet
+	xffDepth int
+}
+
+// registration is what one middleware instance contributes to the runner.
+type registration struct {
+	hosts   map[string]struct{}
+	autoWWW bool
+	exclude map[string]struct{}
+	// cased maps hosts written with capitals to that spelling, for PreserveHostCase.
+	cased map[string]string
+	// defaults and overrides are the middleware's DefaultProxied/TTL and DomainOverrides, for the hosts
+	// whose settings come from it; order is when it last registered.
+	defaults  RecordDefaults
+	overrides map[string]RecordDefaults
+	order     int
 }
 
 // Runner is the singleton background worker shared by all middleware instances.
 type Runner struct {
 	logger *log.Logger
+	// cfg, webhookClient and logLimit are replaced by adoptPrimary holding both syncMu and cfgMu. Code
+	// running inside a sync cycle holds syncMu and reads them directly; other goroutines (status server,
+	// webhook batch timer, RegisterConfig, the worker loop) go through config, webhookTarget and
+	// logLimiter.
 	cfg    Config
+	cfgMu  sync.RWMutex
 	client *cloudflareClient
+	// ipClient is dedicated to IP source lookups so its TLS settings never leak into Cloudflare calls.
+	ipClient *http.Client
+	// instance fills {instance} in CommentTemplate.
+	instance string
+	// ctx is cancelled by Stop.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// webhookClient delivers WebhookURL notifications.
+	webhookClient *http.Client
+	clock         clock
+	// changeBatch debounces records_changed events; afterFunc arms its timer.
+	changeBatch changeBatch
+	afterFunc   func(d time.Duration, f func()) *time.Timer
+	// events streams to EventSocket clients; nil when unset.
+	events *eventHub
+	// observed collects request source IPs from middlewares with ObserveRequestIP.
+	observed observedIPs
+	// logLimit applies LogRateLimitPerMinute; nil when the option is off.
+	logLimit *logLimiter
+	// ipStats drives AdaptiveIPSources ordering; nil when the option is off.
+	ipStats *ipSourceStats
+	// stickyIP holds the last good source per family for StickyIPSource; nil when the option is off.
+	stickyIP *stickySources
 
-	hostsMu sync.RWMutex
-	hosts   map[string]struct{}
+	hostsMu       sync.RWMutex
+	registrations map[string]registration
+	// primary names the middleware that set Config.Primary; empty while none has registered.
+	primary string
+	// settingsFrom names the middleware the runner-wide settings come from: primary, or else the first
+	// one registered. hostSettings maps each host to the middleware whose proxied/TTL settings it gets;
+	// see resolveHostSettings.
+	settingsFrom        string
+	hostSettings        map[string]string
+	hostConflictsWarned map[string]string
+	registrationSeq     int
+
+	syncMu             sync.Mutex
+	lastKnownIP        string
+	paused             bool
+	lastCycleAt        time.Time
+	lastCycleErr       string
+	multiAccountWarned bool
+	readyLogged        bool
+	// duplicateZonesWarned is set once same-named zones in different accounts were reported.
+	duplicateZonesWarned bool
+	// inactiveZonesWarned holds IDs of zones reported as not active, so RequireActiveZone warns once per zone.
+	inactiveZonesWarned map[string]struct{}
+	// denyNets is DenyIPs parsed.
+	denyNets []*net.IPNet
+	// pruneObserve tracks the PruneObserveUntil period.
+	pruneObserve pruneObserve
 
-	syncMu      sync.Mutex
-	lastKnownIP string
+	// Failover state, driven by evaluateHealth.
+	failedOver      bool
+	healthFailures  int
+	healthSuccesses int
+	// Parsed MaintenanceWindows; writesHeld is set per cycle in DryRun or when outside all of them, and
+	// holdReason says which.
+	maintenanceWindows  []maintenanceWindow
+	maintenanceLocation *time.Location
+	writesHeld          bool
+	holdReason          string
+	// plan collects the changes held back this cycle for DryRunFormat plan; planOut receives it.
+	plan    []plannedChange
+	planOut io.Writer
+	// Per-cycle create accounting for MaxCreatesPerCycle.
+	createsThisCycle int
+	createsDeferred  int
+	// unchangedSuppressed counts "already synced" lines held back this cycle by UnchangedLogWindowSeconds.
+	unchangedSuppressed int
+	// budgetCarry holds the hosts the last over-budget cycle did not reach, in order.
+	budgetCarry []string
+	// createForbidden holds zone IDs where the token was refused permission to create records.
+	createForbidden map[string]struct{}
+	// failures classifies this cycle's Cloudflare failures; transientCycles counts consecutive cycles with
+	// transient failures for alerting.
+	failures        cycleFailures
+	transientCycles int
+	// adoptedZones holds zone IDs whose first cycle already ran AdoptExistingOnFirstRun; adoptFailed
+	// holds the zones where an adoption write failed this cycle, which are tried again next cycle.
+	adoptedZones map[string]struct{}
+	adoptFailed  map[string]struct{}
+	// seeds caches bulk A-record listings by zone ID for SeedFromZoneExport.
+	seeds map[string]*zoneSeed
+	// lookupHost resolves FollowHosts references; followed caches the answers.
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	followed   map[string]followedAnswer
+	// hostStates holds per-host state between cycles; see hostState.
+	hostStates map[string]*hostState
+	// cycle numbers reconcile runs, for per-host backoff.
+	cycle int
+	// timings are latency histograms for /metrics and /status.
+	timings *timings
 }
 
 func CreateConfig() *Config {
@@ -84,7 +553,10 @@ func CreateConfig() *Config {
 		AutoDiscoverHost:      true,
 		DefaultProxied:        false,
 		IPSources:             append([]string(nil), defaultIPSources...),
-		ManagedComment:        "managed-by=traefik-plugin-ddns",
+		RejectCloudflareIPs:   true,
+		RequireActiveZone:     true,
+		ManagedComment:        defaultManagedComment,
+		ReconcileFields:       append([]string(nil), defaultReconcileFields...),
 	}
 }
 
@@ -99,6 +571,9 @@ func New(ctx context.Context, next http.Handler, cfg *Config, name string) (http
 	}
 
 	effective := normalizeConfig(*cfg)
+	if err := validateConfig(effective); err != nil {
+		return nil, err
+	}
 
 	globalRunnerOnce.Do(func() {
 		globalRunner, globalRunnerErr = newRunner(effective)
@@ -116,259 +591,1845 @@ func New(ctx context.Context, next http.Handler, cfg *Config, name string) (http
 
 	// Register hosts for this middleware instance into the global worker.
 	if effective.Enabled {
-		globalRunner.RegisterConfig(name, effective)
+		if err := globalRunner.RegisterConfig(name, effective); err != nil {
+			return nil, err
+		}
 	}
 
-	return &Middleware{next: next, name: name}, nil
+	m := &Middleware{next: next, name: name}
+	if effective.ObserveRequestIP {
+		m.observed = &globalRunner.observed
+		m.trusted = mustParseCIDRs(effective.TrustedProxies...)
+		m.xffDepth = effective.ForwardedForDepth
+	}
+	return m, nil
 }
 
 // ServeHTTP is intentionally passive: request flow is never blocked by DDNS work.
 func (m *Middleware) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if m.observed != nil {
+		if ip := requestSourceIP(req, m.trusted, m.xffDepth); isPublicIPv4(ip) {
+			m.observed.add(ip)
+		}
+	}
 	m.next.ServeHTTP(rw, req)
 }
 
+// NewRunner builds a standalone runner for library use; call Start to begin syncing.
+func NewRunner(cfg Config) (*Runner, error) {
+	effective := normalizeConfig(cfg)
+	if err := validateConfig(effective); err != nil {
+		return nil, err
+	}
+	r, err := newRunner(effective)
+	if err != nil {
+		return nil, err
+	}
+	if effective.Enabled {
+		if err := r.RegisterConfig("runner", effective); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
 func newRunner(cfg Config) (*Runner, error) {
 	token := strings.TrimSpace(cfg.APIToken)
 	if token == "" {
 		return nil, fmt.Errorf("cloudflare token missing: set apiToken in middleware config")
 	}
+	if cfg.FailOnEmpty && (!cfg.Enabled || len(configHosts(cfg)) == 0) {
+		return nil, errors.New("no domains to manage: set domains, domainsCsv, routerRule or routerRules (failOnEmpty is enabled)")
+	}
 
 	logger := log.New(os.Stdout, "ddns-traefik-plugin ", log.LstdFlags)
+	if interval, clamped := clampSyncInterval(cfg.SyncIntervalSeconds); clamped {
+		logger.Printf("[WARN] syncIntervalSeconds=%d is below the %ds minimum; using %ds", cfg.SyncIntervalSeconds, interval, interval)
+		cfg.SyncIntervalSeconds = interval
+	}
+	windows, err := parseMaintenanceWindows(cfg.MaintenanceWindows)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := parseDenyIPs(cfg.DenyIPs)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(cfg.MaintenanceTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenanceTimezone %q: %w", cfg.MaintenanceTimezone, err)
+	}
 	httpClient := &http.Client{Timeout: time.Duration(cfg.RequestTimeoutSeconds) * time.Second}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	r := &Runner{
-		logger: logger,
-		cfg:    cfg,
-		client: newCloudflareClient(token, httpClient, logger),
-		hosts:  make(map[string]struct{}),
-	}
-	r.infof("worker started")
-	return r, nil
-}
+		ctx:                 ctx,
+		cancel:              cancel,
+		logger:              logger,
+		cfg:                 cfg,
+		client:              newCloudflareClient(token, httpClient, logger),
+		ipClient:            newIPSourceClient(cfg),
+		webhookClient:       httpClient,
+		afterFunc:           time.AfterFunc,
+		clock:               realClock{},
+		registrations:       make(map[string]registration),
+		hostConflictsWarned: make(map[string]string),
+		hostStates:          make(map[string]*hostState),
+		lookupHost:          net.DefaultResolver.LookupHost,
+		followed:            make(map[string]followedAnswer),
+		seeds:               make(map[string]*zoneSeed),
+		adoptedZones:        make(map[string]struct{}),
+		adoptFailed:         make(map[string]struct{}),
+		createForbidden:     make(map[string]struct{}),
+		inactiveZonesWarned: make(map[string]struct{}),
+		planOut:             os.Stdout,
+		timings:             newTimings(),
 
-func (r *Runner) RegisterConfig(name string, cfg Config) {
-	// Keep auth/network config from first initialized middleware only.
-	if cfg.Zone != "" && !strings.EqualFold(strings.TrimSpace(cfg.Zone), strings.TrimSpace(r.cfg.Zone)) && r.cfg.Zone != "" {
-		r.warnf("middleware=%s zone %q ignored; global zone is %q", name, cfg.Zone, r.cfg.Zone)
+		maintenanceWindows:  windows,
+		maintenanceLocation: location,
+		denyNets:            denyNets,
 	}
-
-	for _, domain := range cfg.Domains {
-		r.addHost(normalizeHost(domain))
+	r.client.accountID = strings.TrimSpace(cfg.AccountID)
+	if r.instance, err = os.Hostname(); err != nil {
+		r.instance = "unknown"
+	}
+	if cfg.LogRateLimitPerMinute > 0 {
+		r.logLimit = newLogLimiter(cfg.LogRateLimitPerMinute)
+	}
+	if cfg.AdaptiveIPSources {
+		r.ipStats = newIPSourceStats(r.clock, time.Duration(cfg.AdaptiveIPSourcesResetSeconds)*time.Second)
+	}
+	if cfg.StickyIPSource {
+		r.stickyIP = newStickySources()
 	}
-	if cfg.AutoDiscoverHost && cfg.RouterRule != "" {
-		for _, host := range extractHosts(cfg.RouterRule) {
-			r.addHost(host)
+	r.client.recordTags = cfg.RecordTags
+	r.client.staticZones = staticCFZones(cfg.StaticZones)
+	r.client.partialZones = cfg.AllowPartialZoneList
+	if len(cfg.StaticZones) > 0 {
+		r.infof("using %d static zones from staticZones; zones are never listed", len(cfg.StaticZones))
+	}
+	if cfg.StatusAddr != "" {
+		if err := r.serveStatus(); err != nil {
+			return nil, fmt.Errorf("status server %s: %w", cfg.StatusAddr, err)
+		}
+	}
+	if cfg.EventSocket != "" {
+		if r.events, err = newEventHub(cfg.EventSocket); err != nil {
+			return nil, fmt.Errorf("event socket %s: %w", cfg.EventSocket, err)
 		}
 	}
+	if cfg.InsecureSkipVerifyIPSources {
+		r.warnf("TLS verification DISABLED for IP sources (insecureSkipVerifyIpSources=true); Cloudflare API calls still verify")
+	}
+	r.infof("worker started version=%s", Version())
+	r.infof("effective config:\n%s", cfg)
+	return r, nil
 }
 
-func (r *Runner) addHost(host string) {
-	host = normalizeHost(host)
-	if host == "" {
-		return
+// newIPSourceClient builds the HTTP client used for public IP lookups.
+func newIPSourceClient(cfg Config) *http.Client {
+	timeout := time.Duration(cfg.RequestTimeoutSeconds) * time.Second
+	if !cfg.InsecureSkipVerifyIPSources {
+		return &http.Client{Timeout: timeout}
 	}
-	r.hostsMu.Lock()
-	r.hosts[host] = struct{}{}
-	r.hostsMu.Unlock()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return &http.Client{Timeout: timeout, Transport: transport}
 }
 
-func (r *Runner) snapshotHosts() []string {
-	r.hostsMu.RLock()
-	defer r.hostsMu.RUnlock()
-	out := make([]string, 0, len(r.hosts))
-	for host := range r.hosts {
-		out = append(out, host)
+func (r *Runner) ipResolver(family string) ipResolver {
+	return ipResolver{
+		client:            r.ipClient,
+		family:            family,
+		rejectCloudflare:  r.cfg.RejectCloudflareIPs,
+		deny:              r.denyNets,
+		strictContentType: r.cfg.StrictIPContentType,
+		strictParsing:     r.cfg.StrictIPParsing,
+		rejectMapped:      r.cfg.RejectIPv4Mapped,
+		stats:             r.ipStats,
+		sticky:            r.stickyIP,
+		warnf:             r.warnf,
 	}
-	return out
 }
 
-func (r *Runner) Start() {
-	ticker := time.NewTicker(time.Duration(r.cfg.SyncIntervalSeconds) * time.Second)
-	defer ticker.Stop()
+// RegisterConfig adds the hosts of one middleware instance. Runner-wide settings come from the middleware
+// with Primary set, or from the first one registered when none is; a second primary is an error.
+func (r *Runner) RegisterConfig(name string, cfg Config) error {
+	r.hostsMu.Lock()
+	primary := r.primary
+	if cfg.Primary {
+		if primary != "" && primary != name {
+			r.hostsMu.Unlock()
+			return fmt.Errorf("middleware=%s sets primary but middleware=%s already does: set primary on exactly one middleware", name, primary)
+		}
+		r.primary = name
+	}
+	r.hostsMu.Unlock()
 
-	r.runSyncCycle(context.Background())
+	switch {
+	case cfg.Primary:
+		if err := r.adoptPrimary(cfg); err != nil {
+			return fmt.Errorf("middleware=%s: %w", name, err)
+		}
+		if primary == "" {
+			r.infof("middleware=%s is primary; runner-wide settings come from it", name)
+		}
+	case cfg.Zone != "" && !strings.EqualFold(strings.TrimSpace(cfg.Zone), strings.TrimSpace(r.config().Zone)) && r.config().Zone != "":
+		if zone := r.config().Zone; primary != "" {
+			r.warnf("middleware=%s zone %q ignored; global zone %q comes from primary middleware=%s", name, cfg.Zone, zone, primary)
+		} else {
+			r.warnf("middleware=%s zone %q ignored; global zone is %q (set primary on one middleware to choose)", name, cfg.Zone, zone)
+		}
+	}
 
-	for range ticker.C {
-		r.runSyncCycle(context.Background())
+	// Each middleware owns its registration so a reloaded config can drop hosts.
+	reg := registration{
+		hosts:   make(map[string]struct{}),
+		autoWWW: cfg.AutoWWW,
+		exclude: make(map[string]struct{}),
+		cased:   hostCasing(cfg),
+		defaults: RecordDefaults{
+			Proxied: &cfg.DefaultProxied,
+			TTL:     cfg.TTL,
+		},
+		overrides: cfg.DomainOverrides,
+	}
+	for _, host := range configHosts(cfg) {
+		reg.hosts[host] = struct{}{}
+	}
+	for _, host := range cfg.ExcludeDomains {
+		if host = normalizeHost(host); host != "" {
+			reg.exclude[host] = struct{}{}
+		}
+	}
+	r.hostsMu.Lock()
+	r.registrationSeq++
+	reg.order = r.registrationSeq
+	r.registrations[name] = reg
+	if r.primary != "" {
+		r.settingsFrom = r.primary
+	} else if r.settingsFrom == "" {
+		r.settingsFrom = name
 	}
+	r.resolveHostSettings()
+	r.hostsMu.Unlock()
+	return nil
 }
 
-func (r *Runner) runSyncCycle(ctx context.Context) {
-	if !r.cfg.Enabled {
-		return
+// adoptPrimary replaces the runner-wide settings with the primary middleware's config. It waits for an
+// in-flight cycle. Listeners and the sync schedule are bound when the runner starts, so a primary that
+// registers after another middleware started the runner only warns when those differ.
+func (r *Runner) adoptPrimary(cfg Config) error {
+	windows, err := parseMaintenanceWindows(cfg.MaintenanceWindows)
+	if err != nil {
+		return err
+	}
+	denyNets, err := parseDenyIPs(cfg.DenyIPs)
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(cfg.MaintenanceTimezone)
+	if err != nil {
+		return fmt.Errorf("invalid maintenanceTimezone %q: %w", cfg.MaintenanceTimezone, err)
+	}
+	if interval, clamped := clampSyncInterval(cfg.SyncIntervalSeconds); clamped {
+		cfg.SyncIntervalSeconds = interval
 	}
 
 	r.syncMu.Lock()
 	defer r.syncMu.Unlock()
-
-	hosts := r.snapshotHosts()
-	if len(hosts) == 0 {
-		r.debugf("no hosts registered for sync")
-		return
+	if cfg.StatusAddr != r.cfg.StatusAddr || cfg.EventSocket != r.cfg.EventSocket ||
+		cfg.SyncIntervalSeconds != r.cfg.SyncIntervalSeconds || cfg.InitialDelaySeconds != r.cfg.InitialDelaySeconds {
+		r.warnf("primary statusAddr, eventSocket, syncIntervalSeconds and initialDelaySeconds apply after a restart; the runner was started by another middleware")
+		cfg.StatusAddr, cfg.EventSocket = r.cfg.StatusAddr, r.cfg.EventSocket
+		cfg.SyncIntervalSeconds, cfg.InitialDelaySeconds = r.cfg.SyncIntervalSeconds, r.cfg.InitialDelaySeconds
 	}
-
-	publicIP, err := resolvePublicIPv4(ctx, r.cfg.IPSources, r.client.httpClient)
-	if err != nil {
-		r.errorf("ip resolution failed: %v", err)
-		return
+	// New clients rather than new timeouts on the current ones, which other goroutines may be using.
+	timeout := time.Duration(cfg.RequestTimeoutSeconds) * time.Second
+	webhookClient := &http.Client{Timeout: timeout}
+	logLimit := r.logLimiter()
+	if cfg.LogRateLimitPerMinute > 0 && logLimit == nil {
+		logLimit = newLogLimiter(cfg.LogRateLimitPerMinute)
 	}
-
-	zones, err := r.client.listZones(ctx)
-	if err != nil {
-		r.errorf("failed listing zones: %v", err)
-		return
+	r.cfgMu.Lock()
+	r.cfg = cfg
+	r.webhookClient = webhookClient
+	r.logLimit = logLimit
+	r.cfgMu.Unlock()
+	r.client.apiToken = strings.TrimSpace(cfg.APIToken)
+	r.client.accountID = strings.TrimSpace(cfg.AccountID)
+	r.client.recordTags = cfg.RecordTags
+	r.client.staticZones = staticCFZones(cfg.StaticZones)
+	r.client.partialZones = cfg.AllowPartialZoneList
+	r.client.httpClient = &http.Client{Timeout: timeout}
+	r.ipClient = newIPSourceClient(cfg)
+	if cfg.AdaptiveIPSources && r.ipStats == nil {
+		r.ipStats = newIPSourceStats(r.clock, time.Duration(cfg.AdaptiveIPSourcesResetSeconds)*time.Second)
 	}
-
-	if r.lastKnownIP != "" && r.lastKnownIP == publicIP {
-		r.debugf("public ip unchanged (%s), still validating records", publicIP)
+	if cfg.StickyIPSource && r.stickyIP == nil {
+		r.stickyIP = newStickySources()
 	}
+	r.maintenanceWindows = windows
+	r.maintenanceLocation = location
+	r.denyNets = denyNets
+	return nil
+}
+
+// config returns the runner-wide settings for goroutines that do not hold syncMu.
+func (r *Runner) config() Config {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.cfg
+}
 
-	for _, domain := range hosts {
-		zone := r.resolveZone(domain, zones)
-		if zone == nil {
-			r.warnf("domain=%s skipped (no matching zone)", domain)
+// configHosts returns the normalized hosts one middleware config asks to manage, minus its exclusions.
+func configHosts(cfg Config) []string {
+	excluded := make(map[string]struct{})
+	for _, domain := range cfg.ExcludeDomains {
+		excluded[normalizeHost(domain)] = struct{}{}
+	}
+	set := make(map[string]struct{})
+	for _, domain := range cfg.Domains {
+		if host := normalizeHost(domain); host != "" {
+			set[host] = struct{}{}
+		}
+	}
+	if cfg.AutoDiscoverHost {
+		rules := append([]string{cfg.RouterRule}, cfg.RouterRules...)
+		for _, rule := range rules {
+			for _, host := range extractHosts(rule) {
+				set[host] = struct{}{}
+			}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for host := range set {
+		if _, skip := excluded[host]; skip {
 			continue
 		}
-		if err := r.syncDomain(ctx, zone, domain, publicIP); err != nil {
-			r.errorf("domain=%s sync failed: %v", domain, err)
+		if !cfg.AllowReservedTLDs && hasReservedTLD(host) {
+			continue
 		}
+		out = append(out, host)
 	}
-	r.lastKnownIP = publicIP
+	return out
 }
 
-func (r *Runner) resolveZone(domain string, zones []cfZone) *cfZone {
-	if r.cfg.Zone == "" {
-		return bestZoneForDomain(domain, zones)
+// hostCasing maps each host from Domains and router rules to the casing it was written with, when that
+// differs from the normalized host only by case. The first spelling wins.
+func hostCasing(cfg Config) map[string]string {
+	cased := make(map[string]string)
+	add := func(raw string) {
+		raw = strings.TrimSpace(raw)
+		host := normalizeHost(raw)
+		if host == "" || raw == host || !strings.EqualFold(raw, host) {
+			return
+		}
+		if _, seen := cased[host]; !seen {
+			cased[host] = raw
+		}
 	}
-	for i := range zones {
-		zoneName := strings.ToLower(strings.TrimSpace(zones[i].Name))
-		target := strings.ToLower(strings.TrimSpace(r.cfg.Zone))
-		if zoneName == target && (domain == zoneName || strings.HasSuffix(domain, "."+zoneName)) {
-			return &zones[i]
+	for _, domain := range cfg.Domains {
+		add(domain)
+	}
+	if cfg.AutoDiscoverHost {
+		for _, rule := range append([]string{cfg.RouterRule}, cfg.RouterRules...) {
+			for _, literal := range extractHostLiterals(rule) {
+				add(literal)
+			}
 		}
 	}
-	return nil
+	return cased
 }
 
-func (r *Runner) syncDomain(ctx context.Context, zone *cfZone, domain, publicIP string) error {
-	records, err := r.client.listARecords(ctx, zone.ID, domain)
-	if err != nil {
-		return err
+// payloadName is the record name sent on create and update: host itself, or with PreserveHostCase the
+// casing a middleware wrote it with. When several middlewares cased the host, the one whose settings
+// the host gets (see resolveHostSettings) wins, then the earliest registered. Lookups always match
+// names case-insensitively.
+func (r *Runner) payloadName(host string) string {
+	if !r.cfg.PreserveHostCase {
+		return host
 	}
-
-	if hasDesiredARecord(records, domain, publicIP) {
-		r.debugf("domain=%s already synced", domain)
-		return nil
+	r.hostsMu.RLock()
+	defer r.hostsMu.RUnlock()
+	if cased, ok := r.registrations[r.hostSettings[host]].cased[host]; ok {
+		return cased
 	}
-
-	if len(records) == 0 {
-		r.infof("create A record domain=%s ip=%s", domain, publicIP)
-		_, err := r.client.createARecord(ctx, zone.ID, domain, publicIP, r.cfg.DefaultProxied, r.cfg.ManagedComment)
-		return err
+	best, found := "", false
+	bestOrder := 0
+	for _, reg := range r.registrations {
+		if cased, ok := reg.cased[host]; ok && (!found || reg.order < bestOrder) {
+			best, bestOrder, found = cased, reg.order, true
+		}
+	}
+	if found {
+		return best
 	}
+	return host
+}
 
-	record := pickRecord(records)
-	r.infof("update A record domain=%s old=%s new=%s", domain, record.Content, publicIP)
-	_, err = r.client.updateARecord(ctx, zone.ID, record.ID, domain, publicIP, record.Proxied, record.Comment)
-	return err
+// reservedTLDs are special-use top-level domains (RFC 2606, RFC 6761, RFC 6762) that only appear in
+// development and LAN setups and can never be a Cloudflare zone.
+var reservedTLDs = []string{"localhost", "test", "invalid", "example", "local"}
+
+// hasReservedTLD reports whether host is, or ends in, a reserved TLD.
+func hasReservedTLD(host string) bool {
+	tld := host[strings.LastIndex(host, ".")+1:]
+	return hasField(reservedTLDs, tld)
 }
 
-func extractHosts(rule string) []string {
-	rule = strings.TrimSpace(rule)
-	if rule == "" {
-		return nil
+func (r *Runner) snapshotHosts() []string {
+	r.hostsMu.RLock()
+	defer r.hostsMu.RUnlock()
+	set := make(map[string]struct{})
+	for _, reg := range r.registrations {
+		for host := range reg.hosts {
+			set[host] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for host := range set {
+		out = append(out, host)
 	}
+	return out
+}
 
-	callMatches := hostCallPattern.FindAllStringSubmatch(rule, -1)
-	outSet := make(map[string]struct{})
-	for _, call := range callMatches {
-		if len(call) < 2 {
+// withWWWAliases adds www.<apex> for every apex host registered by a middleware with AutoWWW,
+// unless that middleware excludes the alias or it is already managed.
+func (r *Runner) withWWWAliases(hosts []string, zones []cfZone) []string {
+	seen := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		seen[host] = struct{}{}
+	}
+	r.hostsMu.RLock()
+	defer r.hostsMu.RUnlock()
+	for _, reg := range r.registrations {
+		if !reg.autoWWW {
 			continue
 		}
-		for _, token := range backtickPattern.FindAllStringSubmatch(call[1], -1) {
-			if len(token) < 2 {
+		for host := range reg.hosts {
+			zone, _ := r.resolveZone(host, zones)
+			if zone == nil || !strings.EqualFold(strings.TrimSpace(zone.Name), host) {
 				continue
 			}
-			host := normalizeHost(token[1])
-			if host == "" {
+			alias := "www." + host
+			if _, ok := seen[alias]; ok {
 				continue
 			}
-			outSet[host] = struct{}{}
+			if _, ok := reg.exclude[alias]; ok {
+				continue
+			}
+			seen[alias] = struct{}{}
+			hosts = append(hosts, alias)
 		}
 	}
+	return hosts
+}
 
-	out := make([]string, 0, len(outSet))
-	for host := range outSet {
+// selfHosts returns the infrastructure hosts excluded from management: SelfHost and the StatusAddr host
+// when it is a name rather than an address.
+func (r *Runner) selfHosts() []string {
+	if r.cfg.ManageSelfHost {
+		return nil
+	}
+	var out []string
+	if host := normalizeHost(r.cfg.SelfHost); host != "" {
 		out = append(out, host)
 	}
+	if host, _, err := net.SplitHostPort(r.cfg.StatusAddr); err == nil && host != "" && net.ParseIP(host) == nil {
+		if host = normalizeHost(host); host != "" && !hasField(out, host) {
+			out = append(out, host)
+		}
+	}
 	return out
 }
 
-func normalizeHost(host string) string {
-	host = strings.ToLower(strings.TrimSpace(host))
-	host = strings.Trim(host, "`")
-	host = strings.Trim(host, " ")
-	if parts := strings.Split(host, ":"); len(parts) == 2 {
-		host = parts[0]
-	}
-	if strings.Contains(host, "*") {
-		return ""
+// withoutSelfHosts drops selfHosts from hosts.
+func (r *Runner) withoutSelfHosts(hosts []string) []string {
+	self := r.selfHosts()
+	if len(self) == 0 {
+		return hosts
 	}
-	return strings.Trim(host, "[]")
-}
-
-func hasDesiredARecord(records []cfRecord, domain, publicIP string) bool {
-	for _, record := range records {
-		if !strings.EqualFold(record.Name, domain) {
-			continue
-		}
-		if !strings.EqualFold(record.Type, "A") {
+	out := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if hasField(self, host) {
+			r.debugf("domain=%s excluded as the plugin's own host (set manageSelfHost to manage it)", host)
 			continue
 		}
-		if strings.TrimSpace(record.Content) == publicIP {
-			return true
-		}
+		out = append(out, host)
 	}
-	return false
+	return out
 }
 
-func normalizeConfig(cfg Config) Config {
-	if cfg.SyncIntervalSeconds <= 0 {
-		cfg.SyncIntervalSeconds = 300
-	}
-	if cfg.RequestTimeoutSeconds <= 0 {
-		cfg.RequestTimeoutSeconds = 10
-	}
-	if len(cfg.IPSources) == 0 {
-		cfg.IPSources = append([]string(nil), defaultIPSources...)
-	}
-	if cfg.ManagedComment == "" {
-		cfg.ManagedComment = "managed-by=traefik-plugin-ddns"
+// Start runs a cycle after InitialDelaySeconds and then every SyncIntervalSeconds until Stop.
+func (r *Runner) Start() {
+	if !r.waitInitialDelay() {
+		return
 	}
-	// Support manual domain configuration via CSV in addition to list form.
-	if cfg.DomainsCSV != "" {
-		for _, entry := range strings.Split(cfg.DomainsCSV, ",") {
-			host := normalizeHost(entry)
-			if host != "" {
-				cfg.Domains = append(cfg.Domains, host)
+	ticker := r.clock.NewTicker(time.Duration(r.config().SyncIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	r.runSyncCycle(r.ctx)
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case _, ok := <-ticker.Chan():
+			if !ok {
+				return
 			}
+			r.runSyncCycle(r.ctx)
 		}
 	}
-	return cfg
 }
 
-func (r *Runner) debugf(format string, args ...interface{}) {
-	r.logger.Printf("[DEBUG] "+format, args...)
+// Stop ends Start, including a pending initial delay, and cancels the requests of an in-flight cycle.
+// Batched records_changed notifications are sent before it returns.
+func (r *Runner) Stop() {
+	r.cancel()
+	r.flushChanges()
 }
 
-func (r *Runner) infof(format string, args ...interface{}) {
-	r.logger.Printf("[INFO] "+format, args...)
+// waitInitialDelay holds the first cycle for InitialDelaySeconds so Traefik can finish loading its
+// dynamic config. It reports false when the runner was stopped meanwhile.
+func (r *Runner) waitInitialDelay() bool {
+	initialDelay := r.config().InitialDelaySeconds
+	if initialDelay <= 0 {
+		return true
+	}
+	r.infof("first sync cycle in %ds (initialDelaySeconds)", initialDelay)
+	delay := r.clock.NewTicker(time.Duration(initialDelay) * time.Second)
+	defer delay.Stop()
+	select {
+	case <-r.ctx.Done():
+		return false
+	case <-delay.Chan():
+		return true
+	}
 }
 
-func (r *Runner) warnf(format string, args ...interface{}) {
-	r.logger.Printf("[WARN] "+format, args...)
+func (r *Runner) runSyncCycle(ctx context.Context) {
+	if !r.config().Enabled {
+		return
+	}
+
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if r.paused {
+		r.infof("paused; skipping sync cycle")
+		return
+	}
+
+	started := r.clock.Now()
+	r.emit(event{Type: eventCycleStart})
+	r.failures = cycleFailures{}
+	results, err := r.reconcile(ctx)
+	r.lastCycleAt = r.clock.Now()
+	r.lastCycleErr = ""
+	if err != nil {
+		r.lastCycleErr = err.Error()
+	}
+	r.emitCycle(results, err)
+	r.reportSuppressedLogs()
+	r.alertOnFailures(ctx)
+	r.notifyChanges(ctx, results)
+	r.writeCycleReport(started, results, err)
+	r.updateLastErrorFile(results, err)
+	r.notifyCycleComplete(results, err)
 }
 
-func (r *Runner) errorf(format string, args ...interface{}) {
-	r.logger.Printf("[ERROR] "+format, args...)
+// reconcile runs one sync pass and reports per-domain outcomes.
+// The returned error covers failures that aborted the whole cycle.
+func (r *Runner) reconcile(ctx context.Context) ([]DomainStatus, error) {
+	cycleStarted := r.clock.Now()
+	hosts := r.snapshotHosts()
+	if len(hosts) == 0 {
+		r.debugf("no hosts registered for sync")
+		return nil, nil
+	}
+
+	r.plan = nil
+	r.client.listings = newRecordCache()
+	defer func() { r.client.listings = nil }()
+	switch {
+	case r.cfg.DryRun:
+		r.writesHeld, r.holdReason = true, "dry run"
+	case !r.writesAllowed(r.clock.Now()):
+		r.writesHeld, r.holdReason = true, "outside maintenance windows"
+		r.infof("outside maintenance windows; record changes are logged but not applied this cycle")
+	default:
+		r.writesHeld, r.holdReason = false, ""
+	}
+
+	if r.cfg.CustomHostnameMode {
+		r.syncCustomHostnames(ctx, hosts)
+		if r.planning() {
+			renderPlan(r.planOut, r.plan)
+		}
+		return nil, nil
+	}
+
+	useFailover := false
+	if r.cfg.HealthCheckURL != "" {
+		failover, err := r.evaluateHealth(ctx)
+		if err != nil {
+			r.warnf("health check %s failed (%v); skipping record changes this cycle", r.cfg.HealthCheckURL, err)
+			return nil, fmt.Errorf("health check failed: %w", err)
+		}
+		useFailover = failover
+	}
+
+	publicIP := r.cfg.FailoverIP
+	if !useFailover {
+		var err error
+		started := r.clock.Now()
+		publicIP, err = r.ipResolver(ipFamilyV4).resolve(ctx, r.cfg.IPSources)
+		r.observeSince(timingIPResolution, started)
+		if err != nil {
+			if publicIP, err = r.ipResolutionFallback(ctx, err); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	started := r.clock.Now()
+	zones, err := r.client.listZones(ctx)
+	r.observeSince(timingZoneListing, started)
+	if err != nil {
+		r.syncFailuref(err, "failed listing zones")
+		return nil, fmt.Errorf("failed listing zones: %w", err)
+	}
+	zones = zonesInAccount(zones, r.client.accountID)
+	if r.client.accountID == "" && !r.multiAccountWarned && countAccounts(zones) > 1 {
+		r.warnf("token can access zones in %d accounts; set accountId to limit zone listing", countAccounts(zones))
+		r.multiAccountWarned = true
+	}
+	if r.client.accountID == "" && !r.duplicateZonesWarned {
+		if dups := duplicateZoneNames(zones); len(dups) > 0 {
+			r.warnf("zones %s exist in more than one account; set accountId so records are never written to the wrong account", strings.Join(dups, ", "))
+			r.duplicateZonesWarned = true
+		}
+	}
+
+	hosts = r.withoutSelfHosts(r.withWWWAliases(hosts, zones))
+	if !r.readyLogged {
+		// One line of resolved runtime state once the first cycle gets this far, next to the config dump.
+		r.readyLogged = true
+		r.infof("ready: ip=%s zones=%d hosts=%d interval=%ds proxied-default=%t",
+			publicIP, len(zones), len(hosts), r.cfg.SyncIntervalSeconds, r.cfg.DefaultProxied)
+	}
+	if r.cfg.CycleBudgetSeconds > 0 {
+		hosts = r.budgetOrder(hosts)
+	}
+
+	r.createsThisCycle, r.createsDeferred = 0, 0
+	r.unchangedSuppressed = 0
+	r.cycle++
+
+	if r.lastKnownIP != "" && r.lastKnownIP == publicIP {
+		r.debugf("public ip unchanged (%s), still validating records", publicIP)
+	}
+
+	results := make([]DomainStatus, 0, len(hosts))
+	// externalDNS caches external-dns registries by zone ID for this cycle.
+	externalDNS := make(map[string]map[string]struct{})
+	// managed holds the record names this cycle wants to exist, which is what pruning compares against.
+	managed := make([]string, 0, len(hosts))
+	// v6Only holds the hosts whose families leave out v4: still registered, but without an A record.
+	var v6Only []string
+	visitedZones := make(map[string]struct{})
+	outOfBudget := false
+	for i, domain := range hosts {
+		if r.budgetExhausted(cycleStarted) {
+			results = r.carryOver(hosts[i:], results, publicIP, cycleStarted)
+			outOfBudget = true
+			break
+		}
+		status := DomainStatus{Domain: domain, IP: publicIP}
+		zone, err := r.resolveZone(domain, zones)
+		if err != nil {
+			r.warnf("domain=%s skipped: %v", domain, err)
+			status.Action = ActionSkipped
+			status.Error = err.Error()
+			results = append(results, status)
+			managed = append(managed, domain)
+			continue
+		}
+		if r.cfg.RequireActiveZone && !r.zoneActive(zone) {
+			r.debugf("domain=%s skipped (zone %s is %s)", domain, zone.Name, zone.Status)
+			status.Action = ActionSkipped
+			status.Error = fmt.Sprintf("zone %s is %s", zone.Name, zone.Status)
+			results = append(results, status)
+			managed = append(managed, domain)
+			continue
+		}
+		name := domain
+		if r.cfg.NameTemplate != "" {
+			name = applyNameTemplate(r.cfg.NameTemplate, domain, zone.Name)
+			status.Record = name
+			target, _ := r.resolveZone(name, zones)
+			if target == nil {
+				r.warnf("domain=%s skipped (templated name %s is outside managed zones)", domain, name)
+				status.Action = ActionSkipped
+				status.Error = "templated name outside managed zones"
+				results = append(results, status)
+				continue
+			}
+			zone = target
+		}
+		status.Zone = zone.Name
+		if !r.hostHasFamily(name, ipFamilyV4) {
+			// Left out of managed so pruning removes an A record the host no longer wants.
+			r.debugf("domain=%s A skipped (v4 not in its families)", domain)
+			status.Action = ActionSkipped
+			status.Error = "v4 not in hostFamilies"
+			results = append(results, status)
+			v6Only = append(v6Only, name)
+			continue
+		}
+		managed = append(managed, name)
+		if r.cfg.RespectExternalDNS {
+			owned, err := r.ownedByExternalDNS(ctx, zone, name, externalDNS)
+			if err != nil {
+				r.errorf("domain=%s skipped (external-dns registry lookup failed: %v)", domain, err)
+			} else if owned {
+				r.warnf("domain=%s skipped (conflict: owned by external-dns)", domain)
+			}
+			if owned {
+				status.Action = ActionSkipped
+				status.Error = "owned by external-dns"
+				results = append(results, status)
+				continue
+			}
+		}
+		if r.backingOff(name) {
+			r.debugf("domain=%s skipped (backing off after %d failures)", domain, r.hostStates[name].failures)
+			status.Action = ActionSkipped
+			status.Error = "backing off after repeated failures"
+			results = append(results, status)
+			continue
+		}
+		content := publicIP
+		if reference, ok := r.cfg.FollowHosts[domain]; ok {
+			ip, err := r.followedContent(ctx, reference)
+			if err != nil {
+				r.warnf("domain=%s skipped (follow: %v)", domain, err)
+				status.Action = ActionSkipped
+				status.Error = "follow: " + err.Error()
+				results = append(results, status)
+				continue
+			}
+			content = ip
+			status.IP = ip
+		} else if r.cfg.ResolveContent != nil {
+			ip, err := r.resolveContent(domain)
+			if err != nil {
+				r.warnf("domain=%s skipped (resolveContent: %v)", domain, err)
+				status.Action = ActionSkipped
+				status.Error = "resolveContent: " + err.Error()
+				results = append(results, status)
+				continue
+			}
+			content = ip
+			status.IP = ip
+		}
+		visitedZones[zone.ID] = struct{}{}
+		started := r.clock.Now()
+		action, err := r.syncDomain(ctx, zone, name, content)
+		r.observeSince(timingDomainSync, started)
+		status.Action = action
+		if err != nil {
+			r.syncFailuref(err, "domain=%s sync failed", domain)
+			status.Action = ActionFailed
+			status.Error = err.Error()
+		}
+		r.recordHostResult(name, err != nil)
+		results = append(results, status)
+	}
+	r.lastKnownIP = publicIP
+	r.finishAdoption(visitedZones)
+	if r.unchangedSuppressed > 0 {
+		r.debugf("%d domains unchanged", r.unchangedSuppressed)
+	}
+	if r.createsDeferred > 0 {
+		r.warnf("CREATE CAP REACHED: created %d records this cycle (maxCreatesPerCycle=%d); %d more deferred to later cycles",
+			r.createsThisCycle, r.cfg.MaxCreatesPerCycle, r.createsDeferred)
+	}
+
+	// An over-budget cycle has not seen every host, so managed is incomplete: pruning or forgetting
+	// host state on it would treat unreached hosts as gone.
+	if !outOfBudget {
+		registered := append(append([]string(nil), managed...), v6Only...)
+		if len(r.cfg.SRVRecords) > 0 {
+			r.syncSRVRecords(ctx, zones, registered)
+		}
+		if len(r.cfg.IPv6SuffixPerHost) > 0 {
+			r.syncAAAARecords(ctx, zones, registered)
+		}
+		if r.cfg.MailHostHints {
+			r.syncMailHints(ctx, zones, results)
+		}
+
+		if r.cfg.PruneStale {
+			r.pruneStale(ctx, zones, managed, r.clock.Now())
+		}
+		r.forgetStaleHosts(registered)
+	}
+	if r.planning() {
+		renderPlan(r.planOut, r.plan)
+	}
+	return results, nil
+}
+
+// resolveContent asks ResolveContent for the address of host and checks it is an IPv4 address.
+func (r *Runner) resolveContent(host string) (ip string, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			ip, err = "", fmt.Errorf("callback panicked: %v", rec)
+		}
+	}()
+	ip, err = r.cfg.ResolveContent(host)
+	if err != nil {
+		return "", err
+	}
+	ip = strings.TrimSpace(ip)
+	if !ipMatchesFamily(net.ParseIP(ip), ipFamilyV4) {
+		return "", fmt.Errorf("invalid IPv4 %q", ip)
+	}
+	return ip, nil
+}
+
+// notifyCycleComplete invokes OnCycleComplete, shielding the worker from callback panics.
+func (r *Runner) notifyCycleComplete(results []DomainStatus, err error) {
+	if r.cfg.OnCycleComplete == nil {
+		return
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.errorf("onCycleComplete callback panicked: %v", rec)
+		}
+	}()
+	r.cfg.OnCycleComplete(results, err)
+}
+
+// pruneStale deletes owned A records whose host has been absent for at least the grace period.
+// Hosts that reappear have their absence timer cleared.
+func (r *Runner) pruneStale(ctx context.Context, zones []cfZone, hosts []string, now time.Time) {
+	current := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		current[host] = struct{}{}
+		if st, ok := r.hostStates[host]; ok {
+			st.absentSince = time.Time{}
+		}
+	}
+	grace := time.Duration(r.cfg.PruneGracePeriodSeconds) * time.Second
+	observing := r.pruneObserving(now)
+	// pending holds absent hosts whose owned record still exists; complete is false when a listing
+	// failed, in which case absence timers are kept rather than guessed at.
+	pending := make(map[string]struct{})
+	complete := true
+
+	for _, zone := range zones {
+		if r.cfg.Zone != "" && !strings.EqualFold(strings.TrimSpace(zone.Name), strings.TrimSpace(r.cfg.Zone)) {
+			continue
+		}
+		records, err := r.client.listZoneARecords(ctx, zone.ID)
+		if err != nil {
+			r.syncFailuref(err, "zone=%s prune listing failed", zone.Name)
+			complete = false
+			continue
+		}
+		for _, record := range records {
+			host := normalizeHost(record.Name)
+			if _, ok := current[host]; ok || !r.ownsRecord(zone.Name, record) || r.isSkipped(record) {
+				continue
+			}
+			pending[host] = struct{}{}
+			st := r.state(host)
+			seen := !st.absentSince.IsZero()
+			if !seen {
+				st.absentSince = now
+			}
+			since := st.absentSince
+			if now.Sub(since) < grace {
+				if !seen {
+					r.infof("domain=%s no longer registered; prune after %s", host, grace)
+				}
+				continue
+			}
+			if r.writesHeld {
+				current := record
+				r.planChange(plannedChange{Action: planDelete, Name: host, Current: &current}, "would prune A record domain=%s ip=%s", host, record.Content)
+				continue
+			}
+			if observing {
+				r.infof("[PRUNE-OBSERVE] would delete A record domain=%s ip=%s", host, record.Content)
+				continue
+			}
+			r.infof("prune A record domain=%s ip=%s", host, record.Content)
+			if err := r.client.deleteRecord(ctx, zone.ID, record.ID); err != nil {
+				r.syncFailuref(err, "domain=%s prune failed", host)
+				continue
+			}
+			delete(r.hostStates, host)
+			delete(pending, host)
+		}
+	}
+	if !complete {
+		return
+	}
+	// An absent host whose record disappeared by other means has nothing left to prune.
+	for host, st := range r.hostStates {
+		if _, ok := pending[host]; !ok && !st.absentSince.IsZero() {
+			delete(r.hostStates, host)
+		}
+	}
+}
+
+// zoneActive reports whether zone is active. Zones without a status (for example staticZones) count as
+// active. The first time a zone is seen inactive it is reported with a WARN; it is reported again if it
+// becomes active and then inactive once more.
+func (r *Runner) zoneActive(zone *cfZone) bool {
+	if zone.Status == "" || zone.Status == "active" {
+		delete(r.inactiveZonesWarned, zone.ID)
+		return true
+	}
+	if _, warned := r.inactiveZonesWarned[zone.ID]; !warned {
+		r.warnf("zone=%s status is %q, not active: its hosts are skipped until it is. Finish activation in the Cloudflare "+
+			"dashboard (nameservers must point at Cloudflare) or check the zone was not moved to another account", zone.Name, zone.Status)
+		r.inactiveZonesWarned[zone.ID] = struct{}{}
+	}
+	return false
+}
+
+// resolveZone picks the zone for domain. The error tells apart a Zone override that the token
+// cannot see from a domain that is simply not under that zone.
+func (r *Runner) resolveZone(domain string, zones []cfZone) (*cfZone, error) {
+	return resolveZoneFor(r.cfg.Zone, domain, zones)
+}
+
+func resolveZoneFor(zoneOverride, domain string, zones []cfZone) (*cfZone, error) {
+	if zoneOverride == "" {
+		if zone := bestZoneForDomain(domain, zones); zone != nil {
+			return zone, nil
+		}
+		return nil, fmt.Errorf("no zone visible to the token matches %s", domain)
+	}
+	target := strings.ToLower(strings.TrimSpace(zoneOverride))
+	for i := range zones {
+		if strings.ToLower(strings.TrimSpace(zones[i].Name)) != target {
+			continue
+		}
+		if domain == target || strings.HasSuffix(domain, "."+target) {
+			// A child zone delegated from the configured one owns its names; records for them in the
+			// parent would be shadowed by the delegation and never answered.
+			if child := bestZoneForDomain(domain, zones); child != nil && !strings.EqualFold(strings.TrimSpace(child.Name), target) {
+				return nil, fmt.Errorf("%s belongs to delegated zone %s, not configured zone %s", domain, child.Name, target)
+			}
+			return &zones[i], nil
+		}
+		return nil, fmt.Errorf("%s is not under configured zone %s", domain, target)
+	}
+	return nil, fmt.Errorf("configured zone %s not found among zones visible to the token (check zone, token permissions and accountId)", target)
+}
+
+// syncDomain reconciles one host and returns the action taken.
+func (r *Runner) syncDomain(ctx context.Context, zone *cfZone, domain, publicIP string) (string, error) {
+	records, err := r.listARecords(ctx, zone, domain)
+	if err != nil {
+		return ActionFailed, err
+	}
+	if visible := r.withoutSkipped(records); len(visible) != len(records) {
+		if len(visible) == 0 {
+			r.debugf("domain=%s left alone (records marked %q)", domain, r.cfg.SkipComment)
+			return ActionSkipped, nil
+		}
+		records = visible
+	}
+
+	if err := r.adoptUnowned(ctx, zone, domain, records); err != nil {
+		return ActionFailed, err
+	}
+
+	proxied, ttl := r.recordDefaults(domain, zone.Name)
+	desired := cfRecord{
+		Name:    domain,
+		Type:    "A",
+		Content: publicIP,
+		Proxied: proxied,
+		TTL:     ttl,
+		// Drift is judged on the managed prefix; the templated part is rendered when writing.
+		Comment: r.managedComment(zone.Name),
+	}
+	if desired.Proxied && len(records) > 0 {
+		if proxiable := pickRecord(records).Proxiable; proxiable != nil && !*proxiable {
+			r.fallBackToDNSOnly(domain, "cloudflare marks the record proxiable=false")
+			desired.Proxied = false
+		}
+	}
+	if hasReconciledRecord(records, desired, r.cfg.ReconcileFields) {
+		r.logUnchanged(domain)
+		return ActionUnchanged, nil
+	}
+
+	if r.writesHeld {
+		if len(records) == 0 {
+			r.planChange(plannedChange{Action: planCreate, Name: domain, Desired: &desired}, "would create A record domain=%s ip=%s", domain, publicIP)
+		} else {
+			current := pickRecord(records)
+			want := r.updatedRecord(zone.Name, current, desired)
+			r.planChange(plannedChange{Action: planUpdate, Name: domain, Current: &current, Desired: &want},
+				"would update A record domain=%s old=%s new=%s", domain, current.Content, publicIP)
+		}
+		return ActionSkipped, nil
+	}
+
+	if len(records) == 0 {
+		if _, forbidden := r.createForbidden[zone.ID]; forbidden {
+			r.debugf("domain=%s not created (zone %s is read-only for this token)", domain, zone.Name)
+			return ActionSkipped, nil
+		}
+		if r.cfg.MaxCreatesPerCycle > 0 && r.createsThisCycle >= r.cfg.MaxCreatesPerCycle {
+			r.createsDeferred++
+			r.debugf("domain=%s create deferred (maxCreatesPerCycle reached)", domain)
+			return ActionSkipped, nil
+		}
+		replaced, action, err := r.resolveCNAMEConflict(ctx, zone, domain)
+		if action != "" || err != nil {
+			return action, err
+		}
+		r.createsThisCycle++
+		r.infof("create A record domain=%s ip=%s", domain, publicIP)
+		created, err := r.client.createARecord(ctx, zone.ID, r.payloadName(domain), publicIP, desired.Proxied, desired.TTL, r.buildComment(zone.Name, domain, publicIP))
+		if err != nil && desired.Proxied && isNotProxiable(err) {
+			r.fallBackToDNSOnly(domain, err.Error())
+			desired.Proxied = false
+			created, err = r.client.createARecord(ctx, zone.ID, r.payloadName(domain), publicIP, false, desired.TTL, r.buildComment(zone.Name, domain, publicIP))
+		}
+		r.seedWritten(zone.ID, created)
+		if err != nil && replaced != nil && !isAlreadyExists(err) {
+			r.restoreCNAME(ctx, zone, *replaced)
+		}
+		if err != nil {
+			if isForbidden(err) {
+				r.createForbidden[zone.ID] = struct{}{}
+				r.warnf("zone=%s token may not create records; skipping creates in this zone until restart: %v", zone.Name, err)
+			}
+			if isAlreadyExists(err) {
+				r.infof("domain=%s was created concurrently; updating the existing record instead", domain)
+				return r.adoptExisting(ctx, zone, desired, err)
+			}
+			return ActionFailed, err
+		}
+		return ActionCreated, nil
+	}
+
+	return r.updateRecord(ctx, zone, pickRecord(records), desired)
+}
+
+// adoptExisting re-lists a record another instance created between our list and create, and
+// reconciles it like any existing record. createErr is returned if the record cannot be found.
+func (r *Runner) adoptExisting(ctx context.Context, zone *cfZone, desired cfRecord, createErr error) (string, error) {
+	records, err := r.client.listARecords(ctx, zone.ID, desired.Name)
+	if err != nil {
+		return ActionFailed, err
+	}
+	visible := r.withoutSkipped(records)
+	if len(visible) == 0 {
+		if len(records) > 0 {
+			return ActionSkipped, nil
+		}
+		return ActionFailed, createErr
+	}
+	if hasReconciledRecord(visible, desired, r.cfg.ReconcileFields) {
+		return ActionUnchanged, nil
+	}
+	return r.updateRecord(ctx, zone, pickRecord(visible), desired)
+}
+
+// updateRecord rewrites record towards desired.
+func (r *Runner) updateRecord(ctx context.Context, zone *cfZone, record, desired cfRecord) (string, error) {
+	want := r.updatedRecord(zone.Name, record, desired)
+	proxied := want.Proxied
+	r.infof("update A record domain=%s old=%s new=%s", desired.Name, record.Content, desired.Content)
+	updated, err := r.client.updateARecord(ctx, zone.ID, record.ID, r.payloadName(desired.Name), desired.Content, proxied, want.TTL, want.Comment)
+	if err != nil && proxied && isNotProxiable(err) {
+		r.fallBackToDNSOnly(desired.Name, err.Error())
+		proxied = false
+		updated, err = r.client.updateARecord(ctx, zone.ID, record.ID, r.payloadName(desired.Name), desired.Content, proxied, want.TTL, want.Comment)
+	}
+	r.seedWritten(zone.ID, updated)
+	if err != nil {
+		if proxied != record.Proxied {
+			err = proxyToggleError(desired.Name, proxied, err)
+		}
+		return ActionFailed, err
+	}
+	if updated != nil && updated.ID != "" && updated.Proxied != proxied {
+		r.warnf("domain=%s update accepted but Cloudflare kept proxied=%t; it is retried next cycle", desired.Name, updated.Proxied)
+	}
+	return ActionUpdated, nil
+}
+
+// updatedRecord is what updateRecord writes over record in zone. Fields outside ReconcileFields keep
+// whatever value the record already has.
+func (r *Runner) updatedRecord(zone string, record, desired cfRecord) cfRecord {
+	want := desired
+	if !hasField(r.cfg.ReconcileFields, reconcileProxied) {
+		want.Proxied = record.Proxied
+	}
+	want.Comment = record.Comment
+	// Owned records get a freshly rendered CommentTemplate with every update.
+	if hasField(r.cfg.ReconcileFields, reconcileComment) || (r.cfg.CommentTemplate != "" && r.ownsRecord(zone, record)) {
+		want.Comment = r.buildComment(zone, desired.Name, desired.Content)
+	}
+	if record.TTL != 0 && !hasField(r.cfg.ReconcileFields, reconcileTTL) {
+		want.TTL = record.TTL
+	}
+	return want
+}
+
+// proxyToggleError adds guidance to a failed switch between proxied and DNS-only, for the
+// rejections a raw API error does not explain.
+func proxyToggleError(domain string, proxied bool, err error) error {
+	mode := "DNS-only"
+	if proxied {
+		mode = "proxied"
+	}
+	switch {
+	case isSSLRejection(err):
+		return fmt.Errorf("cloudflare rejected switching domain=%s to %s because of the zone's SSL/TLS settings: "+
+			"check the SSL/TLS encryption mode (Full (strict) needs a valid origin certificate) and that an edge "+
+			"certificate covers the host, or drop proxied from reconcileFields: %w", domain, mode, err)
+	}
+	return err
+}
+
+// fallBackToDNSOnly reports that domain is written DNS-only although proxying was requested, because
+// Cloudflare cannot proxy it. The WARN is logged once per host.
+func (r *Runner) fallBackToDNSOnly(domain, reason string) {
+	if st := r.state(domain); !st.dnsOnlyWarned {
+		st.dnsOnlyWarned = true
+		r.warnf("domain=%s cannot be proxied (%s); writing it DNS-only instead. Set proxied=false for it in "+
+			"domainOverrides to silence this", domain, reason)
+		return
+	}
+	r.debugf("domain=%s written DNS-only (not proxiable)", domain)
+}
+
+// resolveCNAMEConflict handles a CNAME at a host that needs a new A record, since Cloudflare rejects
+// an A record next to a CNAME. It returns a non-empty action when the create must not proceed.
+// With AllowApexCNAMEOverride an apex CNAME (Cloudflare flattens those) is deleted first and returned,
+// so the caller can restore it if the create fails.
+func (r *Runner) resolveCNAMEConflict(ctx context.Context, zone *cfZone, domain string) (*cfRecord, string, error) {
+	records, err := r.client.listRecordsByName(ctx, zone.ID, domain)
+	if err != nil {
+		return nil, ActionFailed, err
+	}
+	var replaced *cfRecord
+	for _, record := range records {
+		if record.Type != "CNAME" {
+			continue
+		}
+		apex := strings.EqualFold(domain, strings.TrimSpace(zone.Name))
+		if !apex {
+			r.warnf("domain=%s skipped (CNAME to %s exists; remove it to manage an A record)", domain, record.Content)
+			return nil, ActionSkipped, nil
+		}
+		if !r.cfg.AllowApexCNAMEOverride || r.isSkipped(record) {
+			r.warnf("domain=%s skipped (apex has a flattened CNAME to %s; set allowApexCnameOverride to replace it)", domain, record.Content)
+			return nil, ActionSkipped, nil
+		}
+		r.infof("delete apex CNAME domain=%s target=%s (allowApexCnameOverride)", domain, record.Content)
+		if err := r.client.deleteRecord(ctx, zone.ID, record.ID); err != nil {
+			return nil, ActionFailed, err
+		}
+		record := record
+		replaced = &record
+	}
+	return replaced, "", nil
+}
+
+// restoreCNAME recreates an apex CNAME that resolveCNAMEConflict deleted for an A record that then
+// could not be created, so a failed create does not leave the apex without an answer.
+func (r *Runner) restoreCNAME(ctx context.Context, zone *cfZone, record cfRecord) {
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = autoTTL
+	}
+	payload := map[string]interface{}{
+		"type":    "CNAME",
+		"name":    record.Name,
+		"content": record.Content,
+		"ttl":     ttl,
+		"proxied": record.Proxied,
+		"comment": record.Comment,
+	}
+	if len(record.Tags) > 0 {
+		payload["tags"] = record.Tags
+	}
+	if _, err := r.client.doRequest(ctx, http.MethodPost, fmt.Sprintf("/zones/%s/dns_records", zone.ID), payload); err != nil {
+		r.errorf("domain=%s apex CNAME to %s could not be restored after the failed create; recreate it by hand: %v", record.Name, record.Content, err)
+		return
+	}
+	r.warnf("domain=%s apex CNAME to %s restored after the A record create failed", record.Name, record.Content)
+}
+
+// recordDefaults resolves proxied and TTL for a record: DomainOverrides beat ZoneDefaults,
+// which beat DefaultProxied and TTL. DomainOverrides, DefaultProxied and TTL come from the middleware
+// resolveHostSettings picked for the host.
+func (r *Runner) recordDefaults(name, zone string) (bool, int) {
+	proxied, ttl := r.cfg.DefaultProxied, r.cfg.TTL
+	overrides := r.cfg.DomainOverrides
+	// A host registered through a middleware other than the runner-wide one takes that middleware's
+	// defaults and DomainOverrides; ZoneDefaults stay runner-wide.
+	if reg, ok := r.hostRegistration(normalizeHost(name)); ok {
+		proxied, ttl = reg.defaults.apply(proxied, ttl)
+		overrides = reg.overrides
+	}
+	proxied, ttl = r.cfg.ZoneDefaults[normalizeHost(zone)].apply(proxied, ttl)
+	return overrides[normalizeHost(name)].apply(proxied, ttl)
+}
+
+// commentPlaceholders are the placeholders accepted in CommentTemplate.
+var commentPlaceholders = []string{"{host}", "{ip}", "{time}", "{instance}"}
+
+// managedComment returns the sanitized ManagedComment, or the zone's ZoneComments entry, the stable
+// ownership marker of every managed record in zone.
+func (r *Runner) managedComment(zone string) string {
+	comment, _ := sanitizeComment(r.zoneComment(zone))
+	return comment
+}
+
+// zoneComment is the unsanitized ownership comment for records in zone.
+func (r *Runner) zoneComment(zone string) string {
+	if comment, ok := r.cfg.ZoneComments[normalizeHost(zone)]; ok {
+		return comment
+	}
+	return r.cfg.ManagedComment
+}
+
+// buildComment returns the comment written to a managed record in zone, sanitized for Cloudflare.
+func (r *Runner) buildComment(zone, domain, ip string) string {
+	comment := r.zoneComment(zone)
+	if r.cfg.CommentTemplate != "" {
+		comment += " " + strings.NewReplacer(
+			"{host}", domain,
+			"{ip}", ip,
+			"{time}", r.clock.Now().UTC().Format(time.RFC3339),
+			"{instance}", r.instance,
+		).Replace(r.cfg.CommentTemplate)
+	}
+	comment, truncated := sanitizeComment(comment)
+	if truncated {
+		r.debugf("domain=%s comment truncated to %d characters", domain, maxCommentLength)
+	}
+	return comment
+}
+
+// isSkipped reports whether a record carries the SkipComment marker.
+func (r *Runner) isSkipped(record cfRecord) bool {
+	return r.cfg.SkipComment != "" && strings.Contains(record.Comment, r.cfg.SkipComment)
+}
+
+// withoutSkipped drops records carrying the SkipComment marker.
+func (r *Runner) withoutSkipped(records []cfRecord) []cfRecord {
+	if r.cfg.SkipComment == "" {
+		return records
+	}
+	out := make([]cfRecord, 0, len(records))
+	for _, record := range records {
+		if !r.isSkipped(record) {
+			out = append(out, record)
+		}
+	}
+	return out
+}
+
+// ownsRecord reports whether a record in zone carries that zone's managed comment or one of
+// AdditionalOwnedComments, alone or followed by a rendered CommentTemplate.
+func (r *Runner) ownsRecord(zone string, record cfRecord) bool {
+	if commentMatches(record.Comment, r.managedComment(zone)) {
+		return true
+	}
+	for _, comment := range r.cfg.AdditionalOwnedComments {
+		if commentMatches(record.Comment, comment) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentMatches reports whether comment is want, optionally followed by a space and templated details.
+func commentMatches(comment, want string) bool {
+	return comment == want || strings.HasPrefix(comment, want+" ")
+}
+
+// sanitizeComment drops non-printable characters and truncates to maxCommentLength runes.
+func sanitizeComment(comment string) (string, bool) {
+	cleaned := strings.Map(func(c rune) rune {
+		if !unicode.IsPrint(c) {
+			return -1
+		}
+		return c
+	}, comment)
+	cleaned = strings.TrimSpace(cleaned)
+	runes := []rune(cleaned)
+	if len(runes) <= maxCommentLength {
+		return cleaned, false
+	}
+	return strings.TrimSpace(string(runes[:maxCommentLength])), true
+}
+
+var namePlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// applyNameTemplate renders NameTemplate for host within zone.
+// An empty {label} (apex host) collapses the surrounding dots.
+func applyNameTemplate(template, host, zone string) string {
+	zone = strings.ToLower(strings.TrimSpace(zone))
+	label := strings.TrimSuffix(strings.TrimSuffix(host, zone), ".")
+	name := strings.NewReplacer("{host}", host, "{label}", label, "{zone}", zone).Replace(template)
+	for strings.Contains(name, "..") {
+		name = strings.ReplaceAll(name, "..", ".")
+	}
+	return normalizeHost(strings.Trim(name, "."))
+}
+
+func extractHosts(rule string) []string {
+	outSet := make(map[string]struct{})
+	for _, literal := range extractHostLiterals(rule) {
+		if host := normalizeHost(literal); host != "" {
+			outSet[host] = struct{}{}
+		}
+	}
+
+	out := make([]string, 0, len(outSet))
+	for host := range outSet {
+		out = append(out, host)
+	}
+	return out
+}
+
+// extractHostLiterals returns the Host(...) literals of rule as written, before normalizeHost.
+func extractHostLiterals(rule string) []string {
+	return ruleLiterals(rule, hostCallPattern)
+}
+
+// hostPort is a host from a rule and the port written after it, or 0 when there was none.
+type hostPort struct {
+	Host string
+	Port int
+}
+
+// extractHostPorts is extractHosts that keeps the port normalizeHost strips, for features that
+// need it (such as SRV records), and also reads HostSNI(...) matchers. Pairs are returned once each,
+// in rule order; a port that is not 1-65535 is reported as 0.
+func extractHostPorts(rule string) []hostPort {
+	seen := make(map[hostPort]struct{})
+	var out []hostPort
+	for _, pattern := range []*regexp.Regexp{hostCallPattern, hostSNICallPattern} {
+		for _, literal := range ruleLiterals(rule, pattern) {
+			host := normalizeHost(literal)
+			if host == "" {
+				continue
+			}
+			pair := hostPort{Host: host}
+			if parts := strings.Split(strings.TrimSpace(literal), ":"); len(parts) == 2 {
+				if port, err := strconv.Atoi(parts[1]); err == nil && port > 0 && port <= 65535 {
+					pair.Port = port
+				}
+			}
+			if _, dup := seen[pair]; !dup {
+				seen[pair] = struct{}{}
+				out = append(out, pair)
+			}
+		}
+	}
+	return out
+}
+
+// ruleLiterals returns the quoted literals inside every call of rule matched by pattern.
+func ruleLiterals(rule string, pattern *regexp.Regexp) []string {
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return nil
+	}
+	if strings.Contains(rule, `\`) {
+		rule = ruleEscapes.Replace(rule)
+	}
+	var literals []string
+	for _, call := range pattern.FindAllStringSubmatch(rule, -1) {
+		if len(call) < 2 {
+			continue
+		}
+		for _, token := range hostLiteralPattern.FindAllStringSubmatch(call[1], -1) {
+			literals = append(literals, token[1]+token[2]+token[3])
+		}
+	}
+	return literals
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.Trim(host, "`")
+	host = strings.Trim(host, " ")
+	if parts := strings.Split(host, ":"); len(parts) == 2 {
+		host = parts[0]
+	}
+	if strings.Contains(host, "*") {
+		return ""
+	}
+	return strings.Trim(host, "[]")
+}
+
+func hasDesiredARecord(records []cfRecord, domain, publicIP string) bool {
+	desired := cfRecord{Name: domain, Type: "A", Content: publicIP}
+	return hasReconciledRecord(records, desired, nil)
+}
+
+// hasReconciledRecord reports whether any record matches desired on its content and on every other
+// field listed in fields. Content is compared even when fields omits it, so an IP change is never
+// mistaken for a synced record.
+func hasReconciledRecord(records []cfRecord, desired cfRecord, fields []string) bool {
+	for _, record := range records {
+		if !strings.EqualFold(record.Name, desired.Name) {
+			continue
+		}
+		if !strings.EqualFold(record.Type, desired.Type) {
+			continue
+		}
+		if !sameIP(record.Content, desired.Content) {
+			continue
+		}
+		if hasField(fields, reconcileProxied) && record.Proxied != desired.Proxied {
+			continue
+		}
+		if hasField(fields, reconcileTTL) && ttlDrifted(record, desired, fields) {
+			continue
+		}
+		if hasField(fields, reconcileComment) && !commentMatches(record.Comment, desired.Comment) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// ttlDrifted reports a TTL difference on a record that ends up non-proxied. Cloudflare pins proxied
+// records to the automatic TTL, so comparing them against a configured TTL would update forever.
+func ttlDrifted(record, desired cfRecord, fields []string) bool {
+	proxied := record.Proxied
+	if hasField(fields, reconcileProxied) {
+		proxied = desired.Proxied
+	}
+	return !proxied && record.TTL != desired.TTL
+}
+
+// sameIP compares record contents as addresses, so formatting differences such as whitespace,
+// IPv6 zero compression or case never look like drift. Non-IP content falls back to string equality.
+func sameIP(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if ipA, ipB := net.ParseIP(a), net.ParseIP(b); ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return a == b
+}
+
+func hasField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ipResolutionFallback picks an IP after every source failed: the observed request IP when available,
+// then whatever OnIPResolutionFailure allows. It returns an error when the cycle must not touch records.
+func (r *Runner) ipResolutionFallback(ctx context.Context, resolveErr error) (string, error) {
+	if observed := r.observed.mostCommon(); observed != "" {
+		if !r.isDeniedIP(observed) {
+			r.warnf("ip resolution failed (%v); using ip %s observed on incoming requests", resolveErr, observed)
+			return observed, nil
+		}
+		r.warnf("ip resolution failed (%v); not using observed request ip %s: it is in denyIps", resolveErr, observed)
+	}
+	switch r.cfg.OnIPResolutionFailure {
+	case ipFailureUseLastKnown:
+		switch {
+		case r.lastKnownIP == "":
+			r.errorf("ip resolution failed and no last known ip yet: %v", resolveErr)
+		case r.isDeniedIP(r.lastKnownIP):
+			r.warnf("ip resolution failed (%v); not reusing last known ip %s: it is in denyIps", resolveErr, r.lastKnownIP)
+		default:
+			r.warnf("ip resolution failed (%v); reusing last known ip %s (onIpResolutionFailure=use-last-known)", resolveErr, r.lastKnownIP)
+			return r.lastKnownIP, nil
+		}
+	case ipFailureAlert:
+		r.errorf("ip resolution failed: %v", resolveErr)
+		payload := webhookPayload{Event: webhookIPResolutionFailed, Message: resolveErr.Error(), IP: r.lastKnownIP}
+		if err := r.sendWebhook(ctx, payload); err != nil {
+			r.errorf("ip resolution failure webhook failed: %v", err)
+		}
+	default:
+		r.errorf("ip resolution failed: %v", resolveErr)
+	}
+	return "", fmt.Errorf("ip resolution failed: %w", resolveErr)
+}
+
+// isDeniedIP reports whether ip falls inside DenyIPs. Fallback addresses bypass the IP sources, so
+// they are checked here instead of in ipResolver.query.
+func (r *Runner) isDeniedIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && ipInNets(parsed, r.denyNets)
+}
+
+func normalizeConfig(cfg Config) Config {
+	if cfg.SyncIntervalSeconds <= 0 {
+		cfg.SyncIntervalSeconds = 300
+	}
+	if cfg.RequestTimeoutSeconds <= 0 {
+		cfg.RequestTimeoutSeconds = 10
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = autoTTL
+	}
+	cfg.StaticZones = normalizeStaticZones(cfg.StaticZones)
+	var denied []string
+	for _, entry := range cfg.DenyIPs {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			denied = append(denied, entry)
+		}
+	}
+	cfg.DenyIPs = denied
+	cfg.ZoneDefaults = normalizeRecordDefaults(cfg.ZoneDefaults)
+	if len(cfg.ZoneComments) > 0 {
+		comments := make(map[string]string, len(cfg.ZoneComments))
+		for zone, comment := range cfg.ZoneComments {
+			comments[strings.TrimSuffix(normalizeHost(zone), ".")] = strings.TrimSpace(comment)
+		}
+		cfg.ZoneComments = comments
+	}
+	cfg.DomainOverrides = normalizeRecordDefaults(cfg.DomainOverrides)
+	if cfg.ForwardedForDepth <= 0 {
+		cfg.ForwardedForDepth = 1
+	}
+	if cfg.AdaptiveIPSourcesResetSeconds <= 0 {
+		cfg.AdaptiveIPSourcesResetSeconds = 86400
+	}
+	if cfg.HostBackoffMaxCycles <= 0 {
+		cfg.HostBackoffMaxCycles = 16
+	}
+	if cfg.SeedRefreshSeconds <= 0 {
+		cfg.SeedRefreshSeconds = 3600
+	}
+	if cfg.HealthCheckTimeoutSeconds <= 0 {
+		cfg.HealthCheckTimeoutSeconds = 5
+	}
+	if cfg.FailoverThreshold <= 0 {
+		cfg.FailoverThreshold = 3
+	}
+	cfg.FailoverIP = strings.TrimSpace(cfg.FailoverIP)
+	cfg.PruneObserveUntil = strings.ToLower(strings.TrimSpace(cfg.PruneObserveUntil))
+	if cfg.MaintenanceTimezone = strings.TrimSpace(cfg.MaintenanceTimezone); cfg.MaintenanceTimezone == "" {
+		cfg.MaintenanceTimezone = "UTC"
+	}
+	if len(cfg.FollowHosts) > 0 {
+		follows := make(map[string]string, len(cfg.FollowHosts))
+		for host, reference := range cfg.FollowHosts {
+			follows[normalizeHost(host)] = strings.TrimSuffix(normalizeHost(reference), ".")
+		}
+		cfg.FollowHosts = follows
+	}
+	if len(cfg.IPv6SuffixPerHost) > 0 {
+		suffixes := make(map[string]string, len(cfg.IPv6SuffixPerHost))
+		for host, suffix := range cfg.IPv6SuffixPerHost {
+			suffixes[normalizeHost(host)] = strings.TrimSpace(suffix)
+		}
+		cfg.IPv6SuffixPerHost = suffixes
+		if len(cfg.IPv6Sources) == 0 {
+			cfg.IPv6Sources = append([]string(nil), defaultIPv6Sources...)
+		}
+	}
+	if len(cfg.HostFamilies) > 0 {
+		families := make(map[string][]string, len(cfg.HostFamilies))
+		for host, tokens := range cfg.HostFamilies {
+			families[normalizeHost(host)] = normalizeFamilies(tokens)
+		}
+		cfg.HostFamilies = families
+	}
+	if cfg.DefaultFamilies = normalizeFamilies(cfg.DefaultFamilies); len(cfg.DefaultFamilies) == 0 {
+		cfg.DefaultFamilies = []string{ipFamilyV4, ipFamilyV6}
+	}
+	if len(cfg.IPSources) == 0 {
+		cfg.IPSources = append([]string(nil), defaultIPSources...)
+	} else if cfg.AppendDefaultIPSources {
+		cfg.IPSources = withDefaultIPSources(cfg.IPSources)
+	}
+	if cfg.ManagedComment == "" {
+		cfg.ManagedComment = defaultManagedComment
+	}
+	// Unset means the historical defaults, but only for the default ManagedComment: a custom comment marks
+	// a separate instance, whose runner must not own records the defaults mark.
+	if cfg.AdditionalOwnedComments == nil {
+		if strings.TrimSpace(cfg.ManagedComment) == defaultManagedComment {
+			cfg.AdditionalOwnedComments = append([]string(nil), defaultOwnedComments...)
+		}
+	} else {
+		owned := make([]string, 0, len(cfg.AdditionalOwnedComments))
+		for _, comment := range cfg.AdditionalOwnedComments {
+			if comment = strings.TrimSpace(comment); comment != "" && !hasField(owned, comment) {
+				owned = append(owned, comment)
+			}
+		}
+		cfg.AdditionalOwnedComments = owned
+	}
+	if cfg.DryRunFormat = strings.ToLower(strings.TrimSpace(cfg.DryRunFormat)); cfg.DryRunFormat == "" {
+		cfg.DryRunFormat = dryRunFormatLog
+	}
+	if cfg.HostConflictPolicy = strings.ToLower(strings.TrimSpace(cfg.HostConflictPolicy)); cfg.HostConflictPolicy == "" {
+		cfg.HostConflictPolicy = hostConflictPrimary
+	}
+	if cfg.OnIPResolutionFailure = strings.ToLower(strings.TrimSpace(cfg.OnIPResolutionFailure)); cfg.OnIPResolutionFailure == "" {
+		cfg.OnIPResolutionFailure = ipFailureSkip
+	}
+	cfg.WebhookURL = strings.TrimSpace(cfg.WebhookURL)
+	if cfg.WebhookBatchWindowSeconds > 0 && cfg.WebhookBatchMaxDelaySeconds == 0 {
+		cfg.WebhookBatchMaxDelaySeconds = 10 * cfg.WebhookBatchWindowSeconds
+	}
+	cfg.CustomHostnameSSLMethod = strings.ToLower(strings.TrimSpace(cfg.CustomHostnameSSLMethod))
+	if cfg.CustomHostnameSSLMethod == "" {
+		cfg.CustomHostnameSSLMethod = "http"
+	}
+	for i := range cfg.SRVRecords {
+		cfg.SRVRecords[i].Name = normalizeHost(cfg.SRVRecords[i].Name)
+		cfg.SRVRecords[i].Target = normalizeHost(cfg.SRVRecords[i].Target)
+	}
+	fields := make([]string, 0, len(cfg.ReconcileFields))
+	for _, field := range cfg.ReconcileFields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" && !hasField(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		fields = append(fields, defaultReconcileFields...)
+	}
+	cfg.ReconcileFields = fields
+	// Support manual domain configuration via CSV in addition to list form.
+	if cfg.DomainsCSV != "" {
+		for _, entry := range strings.Split(cfg.DomainsCSV, ",") {
+			// Hosts are normalized by configHosts; the written casing is kept for PreserveHostCase.
+			if normalizeHost(entry) != "" {
+				cfg.Domains = append(cfg.Domains, strings.TrimSpace(entry))
+			}
+		}
+	}
+	return cfg
+}
+
+// normalizeRecordDefaults lowercases ZoneDefaults/DomainOverrides keys so lookups match normalized hosts.
+func normalizeRecordDefaults(in map[string]RecordDefaults) map[string]RecordDefaults {
+	if len(in) == 0 {
+		return in
+	}
+	out := make(map[string]RecordDefaults, len(in))
+	for key, defaults := range in {
+		out[normalizeHost(key)] = defaults
+	}
+	return out
+}
+
+// withDefaultIPSources appends the built-in sources that are not already listed.
+func withDefaultIPSources(sources []string) []string {
+	out := append([]string(nil), sources...)
+	for _, source := range defaultIPSources {
+		if !hasField(out, source) {
+			out = append(out, source)
+		}
+	}
+	return out
+}
+
+// clampSyncInterval raises seconds to the minimum floor and reports whether it did.
+func clampSyncInterval(seconds int) (int, bool) {
+	floor := defaultMinSyncIntervalSeconds
+	if raw := strings.TrimSpace(os.Getenv("MIN_SYNC_INTERVAL_SECONDS")); raw != "" {
+		if value, err := strconv.Atoi(raw); err == nil && value > 0 {
+			floor = value
+		}
+	}
+	if seconds < floor {
+		return floor, true
+	}
+	return seconds, false
+}
+
+// validateConfig rejects settings that normalizeConfig cannot repair.
+func validateConfig(cfg Config) error {
+	for _, field := range cfg.ReconcileFields {
+		switch field {
+		case reconcileContent, reconcileProxied, reconcileTTL, reconcileComment:
+		default:
+			return fmt.Errorf("invalid reconcileFields entry %q: expected content, proxied, ttl or comment", field)
+		}
+	}
+	if cfg.FailoverIP != "" {
+		if ip := net.ParseIP(cfg.FailoverIP); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid failoverIp %q: expected an IPv4 address", cfg.FailoverIP)
+		}
+		if cfg.HealthCheckURL == "" {
+			return errors.New("failoverIp requires healthCheckUrl")
+		}
+	}
+	if cfg.CommentTemplate != "" {
+		for _, placeholder := range namePlaceholderPattern.FindAllString(cfg.CommentTemplate, -1) {
+			if !hasField(commentPlaceholders, placeholder) {
+				return fmt.Errorf("invalid commentTemplate placeholder %s: expected {host}, {ip}, {time} or {instance}", placeholder)
+			}
+		}
+		if prefix, truncated := sanitizeComment(cfg.ManagedComment); truncated || len([]rune(prefix)) >= maxCommentLength-1 {
+			return fmt.Errorf("managedComment leaves no room for commentTemplate within %d characters", maxCommentLength)
+		}
+		for zone, comment := range cfg.ZoneComments {
+			if prefix, truncated := sanitizeComment(comment); truncated || len([]rune(prefix)) >= maxCommentLength-1 {
+				return fmt.Errorf("zoneComments[%s] leaves no room for commentTemplate within %d characters", zone, maxCommentLength)
+			}
+		}
+	}
+	if cfg.NameTemplate != "" {
+		for _, placeholder := range namePlaceholderPattern.FindAllString(cfg.NameTemplate, -1) {
+			if placeholder != "{host}" && placeholder != "{label}" && placeholder != "{zone}" {
+				return fmt.Errorf("invalid nameTemplate placeholder %s: expected {host}, {label} or {zone}", placeholder)
+			}
+		}
+		if !strings.Contains(cfg.NameTemplate, "{host}") && !strings.Contains(cfg.NameTemplate, "{label}") {
+			return errors.New("nameTemplate must contain {host} or {label}")
+		}
+	}
+	if err := validateTTL("ttl", cfg.TTL); err != nil {
+		return err
+	}
+	for _, tier := range []struct {
+		name   string
+		values map[string]RecordDefaults
+	}{{"zoneDefaults", cfg.ZoneDefaults}, {"domainOverrides", cfg.DomainOverrides}} {
+		for key, defaults := range tier.values {
+			if defaults.TTL == 0 {
+				continue
+			}
+			if err := validateTTL(fmt.Sprintf("%s[%s].ttl", tier.name, key), defaults.TTL); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cidr := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid trustedProxies entry %q: expected a CIDR such as 10.0.0.0/8", cidr)
+		}
+	}
+	if _, err := parseMaintenanceWindows(cfg.MaintenanceWindows); err != nil {
+		return err
+	}
+	if _, err := parseDenyIPs(cfg.DenyIPs); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(cfg.MaintenanceTimezone); err != nil {
+		return fmt.Errorf("invalid maintenanceTimezone %q: %w", cfg.MaintenanceTimezone, err)
+	}
+	for i, srv := range cfg.SRVRecords {
+		if err := validateSRVRecord(i, srv); err != nil {
+			return err
+		}
+	}
+	if err := validateStaticZones(cfg.StaticZones); err != nil {
+		return err
+	}
+	if err := validateFollowHosts(cfg); err != nil {
+		return err
+	}
+	if err := validateIPv6Suffixes(cfg); err != nil {
+		return err
+	}
+	if err := validateHostFamilies(cfg); err != nil {
+		return err
+	}
+	if cfg.PruneObserveUntil != "" {
+		if !cfg.PruneStale {
+			return errors.New("pruneObserveUntil requires pruneStale")
+		}
+		if _, _, err := parsePruneObserveUntil(cfg.PruneObserveUntil); err != nil {
+			return err
+		}
+	}
+	if cfg.DryRunFormat != dryRunFormatLog && cfg.DryRunFormat != dryRunFormatPlan {
+		return fmt.Errorf("invalid dryRunFormat %q: expected log or plan", cfg.DryRunFormat)
+	}
+	if cfg.HostConflictPolicy != hostConflictPrimary && cfg.HostConflictPolicy != hostConflictLast {
+		return fmt.Errorf("invalid hostConflictPolicy %q: expected primary or last", cfg.HostConflictPolicy)
+	}
+	if cfg.WebhookOnChange && cfg.WebhookURL == "" {
+		return errors.New("webhookOnChange requires webhookUrl")
+	}
+	if cfg.WebhookBatchWindowSeconds > 0 && cfg.WebhookBatchMaxDelaySeconds < cfg.WebhookBatchWindowSeconds {
+		return fmt.Errorf("invalid webhookBatchMaxDelaySeconds %d: expected at least webhookBatchWindowSeconds (%d)",
+			cfg.WebhookBatchMaxDelaySeconds, cfg.WebhookBatchWindowSeconds)
+	}
+	switch cfg.OnIPResolutionFailure {
+	case ipFailureSkip, ipFailureUseLastKnown:
+	case ipFailureAlert:
+		if cfg.WebhookURL == "" {
+			return errors.New("onIpResolutionFailure=alert requires webhookUrl")
+		}
+	default:
+		return fmt.Errorf("invalid onIpResolutionFailure %q: expected skip, use-last-known or alert", cfg.OnIPResolutionFailure)
+	}
+	if cfg.CustomHostnameMode {
+		if strings.TrimSpace(cfg.Zone) == "" {
+			return errors.New("customHostnameMode requires zone (the Cloudflare for SaaS zone)")
+		}
+		if !hasField(customHostnameSSLMethods, cfg.CustomHostnameSSLMethod) {
+			return fmt.Errorf("invalid customHostnameSslMethod %q: expected http, txt or email", cfg.CustomHostnameSSLMethod)
+		}
+	}
+	return nil
+}
+
+// validateTTL accepts Cloudflare's automatic TTL (1) or 60-86400 seconds.
+func validateTTL(field string, ttl int) error {
+	if ttl != autoTTL && (ttl < 60 || ttl > 86400) {
+		return fmt.Errorf("invalid %s %d: expected 1 (automatic) or 60-86400 seconds", field, ttl)
+	}
+	return nil
+}
+
+func (r *Runner) debugf(format string, args ...interface{}) {
+	r.logger.Printf("[DEBUG] "+format, args...)
+}
+
+func (r *Runner) infof(format string, args ...interface{}) {
+	r.logger.Printf("[INFO] "+format, args...)
+}
+
+func (r *Runner) warnf(format string, args ...interface{}) {
+	r.warnKeyed(format, format, args...)
+}
+
+func (r *Runner) errorf(format string, args ...interface{}) {
+	r.errorKeyed(format, format, args...)
+}
+
+// warnKeyed logs a WARN line rate limited under message type key.
+func (r *Runner) warnKeyed(key, format string, args ...interface{}) {
+	if r.logAllowed("WARN", key) {
+		r.logger.Printf("[WARN] "+format, args...)
+	}
+}
+
+// errorKeyed logs an ERROR line rate limited under message type key. The event is always emitted.
+func (r *Runner) errorKeyed(key, format string, args ...interface{}) {
+	if r.logAllowed("ERROR", key) {
+		r.logger.Printf("[ERROR] "+format, args...)
+	}
+	r.emit(event{Type: eventError, Message: fmt.Sprintf(format, args...)})
 }
@@ -1,10 +1,20 @@
 package ddns_traefik_plugin
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func resetGlobalRunner() {
@@ -33,6 +43,63 @@ func TestExtractHosts(t *testing.T) {
 	}
 }
 
+func TestExtractHostsQuoteStyles(t *testing.T) {
+	cases := map[string][]string{
+		"Host(`a.example.com`)":                          {"a.example.com"},
+		`Host("a.example.com")`:                          {"a.example.com"},
+		`Host('a.example.com')`:                          {"a.example.com"},
+		"Host(\"a.example.com\", `b.example.com`)":       {"a.example.com", "b.example.com"},
+		`Host("a.example.com") || Host('b.example.com')`: {"a.example.com", "b.example.com"},
+	}
+	for rule, want := range cases {
+		got := extractHosts(rule)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("extractHosts(%s) = %v, want %v", rule, got, want)
+		}
+	}
+}
+
+func TestExtractHostsMultiLineAndEscapedRules(t *testing.T) {
+	cases := map[string][]string{
+		"Host(\n  `a.example.com`,\n  `b.example.com`\n) &&\nPathPrefix(`/`)": {"a.example.com", "b.example.com"},
+		"Host (`a.example.com`) ||\r\n  Host(`b.example.com`)":                {"a.example.com", "b.example.com"},
+		"Host(\\`a.example.com\\`) || Host(\\\"b.example.com\\\")":            {"a.example.com", "b.example.com"},
+		"HostRegexp(`{sub:[a-z]+}.example.com`) || Host(`c.example.com`)":     {"c.example.com"},
+	}
+	for rule, want := range cases {
+		got := extractHosts(rule)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("extractHosts(%q) = %v, want %v", rule, got, want)
+		}
+	}
+}
+
+func TestExtractHostPortsKeepsPorts(t *testing.T) {
+	cases := map[string]string{
+		"Host(`app.example.com:8443`)":                                                     "app.example.com:8443",
+		"Host(`App.Example.com`, `api.example.com:443`)":                                   "app.example.com:0,api.example.com:443",
+		"HostSNI(`db.example.com:5432`) && ClientIP(`10.0.0.0/8`)":                         "db.example.com:5432",
+		"HostSNI(`*`) || HostSNI(`mq.example.com`)":                                        "mq.example.com:0",
+		"Host(`a.example.com:99999`) || Host(`a.example.com:x`)":                           "a.example.com:0",
+		"Host(`a.example.com:80`) || Host(`a.example.com:80`) || Host(`a.example.com:81`)": "a.example.com:80,a.example.com:81",
+	}
+	for rule, want := range cases {
+		var got []string
+		for _, pair := range extractHostPorts(rule) {
+			got = append(got, fmt.Sprintf("%s:%d", pair.Host, pair.Port))
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("extractHostPorts(%q) = %v, want %s", rule, got, want)
+		}
+	}
+	// The host-only extractor is unchanged: ports are stripped and HostSNI is not read.
+	if got := extractHosts("Host(`app.example.com:8443`) || HostSNI(`db.example.com`)"); len(got) != 1 || got[0] != "app.example.com" {
+		t.Fatalf("unexpected extractHosts result %v", got)
+	}
+}
+
 func TestServeHTTPIsPassive(t *testing.T) {
 	resetGlobalRunner()
 	cfg := CreateConfig()
@@ -104,3 +171,1413 @@ func TestHasDesiredARecord(t *testing.T) {
 		t.Fatalf("did not expect unmatched record")
 	}
 }
+
+func TestHasReconciledRecordFields(t *testing.T) {
+	records := []cfRecord{
+		{ID: "1", Name: "app.example.com", Type: "A", Content: "203.0.113.10", Proxied: true, TTL: 1, Comment: "manual"},
+	}
+	desired := cfRecord{Name: "app.example.com", Type: "A", Content: "203.0.113.10", Proxied: false, TTL: 1, Comment: "managed"}
+
+	if !hasReconciledRecord(records, desired, []string{"content"}) {
+		t.Fatalf("expected content-only match")
+	}
+	if !hasReconciledRecord(records, desired, []string{"content", "ttl"}) {
+		t.Fatalf("expected content+ttl match")
+	}
+	if hasReconciledRecord(records, desired, []string{"content", "proxied"}) {
+		t.Fatalf("expected proxied drift to be detected")
+	}
+	if hasReconciledRecord(records, desired, []string{"content", "comment"}) {
+		t.Fatalf("expected comment drift to be detected")
+	}
+	moved := desired
+	moved.Content = "203.0.113.11"
+	if hasReconciledRecord(records, moved, []string{"ttl"}) {
+		t.Fatalf("expected content drift to be detected without content in fields")
+	}
+}
+
+func TestReconcileCorrectsTTLDrift(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+		{ID: "r2", Name: "cdn.example.com", Type: "A", Content: "203.0.113.7", TTL: autoTTL, Proxied: true, Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "cdn.example.com"}
+	cfg.TTL = 300
+	cfg.ReconcileFields = []string{"content", "ttl"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, result := range results {
+		actions[result.Domain] = result.Action
+	}
+	if actions["app.example.com"] != ActionUpdated || cf.records["z1"][0].TTL != 300 {
+		t.Fatalf("expected TTL drift to be corrected, got %+v records=%+v", results, cf.records["z1"])
+	}
+	// Proxied records always report the automatic TTL.
+	if actions["cdn.example.com"] != ActionUnchanged || cf.countCalls("PUT") != 1 {
+		t.Fatalf("expected proxied record to be left alone, got %+v (%v)", results, cf.calls)
+	}
+
+	results, err = r.reconcile(context.Background())
+	if err != nil || results[0].Action != ActionUnchanged || results[1].Action != ActionUnchanged {
+		t.Fatalf("expected second cycle to be unchanged, got %+v (%v)", results, err)
+	}
+}
+
+func TestReconcileFollowsIPChangeWithoutContentField(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "app.example.com", Type: "A", Content: "198.51.100.1", TTL: autoTTL, Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.ReconcileFields = []string{"proxied"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionUpdated || cf.records["z1"][0].Content != "203.0.113.7" {
+		t.Fatalf("expected the stale IP to be updated, got %+v records=%+v", results, cf.records["z1"])
+	}
+}
+
+func TestProxiedRecordsAreWrittenWithAutomaticTTL(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "moved.example.com", Type: "A", Content: "198.51.100.1", TTL: 300, Comment: "managed-by=traefik-plugin-ddns"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"cdn.example.com", "dns.example.com", "moved.example.com"}
+	cfg.DefaultProxied = true
+	cfg.TTL = 300
+	cfg.DomainOverrides = map[string]RecordDefaults{"dns.example.com": {Proxied: boolPtr(false)}}
+	cfg.ReconcileFields = []string{"content", "proxied", "ttl"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+	r.client.logger = r.logger
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	ttls := map[string]int{}
+	for _, record := range cf.records["z1"] {
+		ttls[record.Name] = record.TTL
+	}
+	if ttls["cdn.example.com"] != autoTTL || ttls["moved.example.com"] != autoTTL {
+		t.Fatalf("expected proxied records to use the automatic TTL, got %v", ttls)
+	}
+	if ttls["dns.example.com"] != 300 {
+		t.Fatalf("expected DNS-only record to keep the configured TTL, got %v", ttls)
+	}
+	if !strings.Contains(logs.String(), "[DEBUG] domain=cdn.example.com ttl=300 replaced by automatic TTL") {
+		t.Fatalf("expected the override to be logged, got:\n%s", logs.String())
+	}
+}
+
+func TestRequireActiveZoneSkipsPendingZones(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com", Status: "active"}, cfZone{ID: "z2", Name: "new.org", Status: "pending"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "app.new.org", "www.new.org"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	for i := 0; i < 2; i++ {
+		results, err := r.reconcile(context.Background())
+		if err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+		for _, result := range results {
+			if result.Zone == "new.org" && (result.Action != ActionSkipped || result.Error != "zone new.org is pending") {
+				t.Fatalf("expected hosts in the pending zone to be skipped, got %+v", result)
+			}
+		}
+	}
+	if n := strings.Count(logs.String(), `[WARN] zone=new.org status is "pending"`); n != 1 {
+		t.Fatalf("expected one warning for the pending zone, got %d:\n%s", n, logs.String())
+	}
+	if len(cf.records["z2"]) != 0 || len(cf.records["z1"]) != 1 {
+		t.Fatalf("expected only the active zone to be written, got %+v", cf.records)
+	}
+
+	r.cfg.RequireActiveZone = false
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(cf.records["z2"]) != 2 {
+		t.Fatalf("expected requireActiveZone=false to write to the pending zone, got %+v", cf.records["z2"])
+	}
+}
+
+func TestProxiedToggleFailureGuidance(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxied bool
+		body    string
+		want    string
+	}{
+		{"ssl mode", true, `{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error: SSL mode Full (strict) requires a valid origin certificate"}]}`, "SSL/TLS encryption mode"},
+		{"to dns-only", false, `{"success":false,"errors":[{"code":1004,"message":"Edge certificate still in use for this SSL hostname"}]}`, "to DNS-only"},
+		{"unrelated", true, `{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error"}]}`, "DNS Validation Error"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+			cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7", TTL: autoTTL, Proxied: !tc.proxied}}
+			cf.fail = func(req *http.Request) (int, string, bool) {
+				if req.Method == http.MethodPut {
+					return http.StatusBadRequest, tc.body, true
+				}
+				return 0, "", false
+			}
+			cfg := CreateConfig()
+			cfg.Domains = []string{"app.example.com"}
+			cfg.DefaultProxied = tc.proxied
+			cfg.ReconcileFields = []string{"content", "proxied"}
+			r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+			results, _ := r.reconcile(context.Background())
+			if len(results) != 1 || results[0].Action != ActionFailed || !strings.Contains(results[0].Error, tc.want) {
+				t.Fatalf("expected failure mentioning %q, got %+v", tc.want, results)
+			}
+			if tc.name == "unrelated" && strings.Contains(results[0].Error, "SSL/TLS") {
+				t.Fatalf("expected unrelated errors to stay raw, got %q", results[0].Error)
+			}
+		})
+	}
+}
+
+func TestNotProxiableFallsBackToDNSOnly(t *testing.T) {
+	notProxiable := false
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.1", TTL: autoTTL, Proxiable: &notProxiable}}
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method != http.MethodPost && req.Method != http.MethodPut {
+			return 0, "", false
+		}
+		body, _ := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if strings.Contains(string(body), `"proxied":true`) {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":9041,"message":"This DNS record cannot be proxied."}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "new.example.com"}
+	cfg.DefaultProxied = true
+	cfg.ReconcileFields = []string{"content", "proxied"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, result := range results {
+		actions[result.Domain] = result.Action
+	}
+	if actions["app.example.com"] != ActionUpdated || actions["new.example.com"] != ActionCreated {
+		t.Fatalf("expected both hosts written DNS-only, got %+v", results)
+	}
+	for _, record := range cf.records["z1"] {
+		if record.Proxied {
+			t.Fatalf("expected DNS-only records, got %+v", cf.records["z1"])
+		}
+	}
+	out := logs.String()
+	if !strings.Contains(out, "[WARN] domain=app.example.com cannot be proxied (cloudflare marks the record proxiable=false)") ||
+		!strings.Contains(out, "[WARN] domain=new.example.com cannot be proxied (") {
+		t.Fatalf("expected a DNS-only fallback WARN per host, got:\n%s", out)
+	}
+
+	logs.Reset()
+	// Cloudflare reports proxiable=false on the records it refused to proxy.
+	for i := range cf.records["z1"] {
+		cf.records["z1"][i].Proxiable = &notProxiable
+	}
+	results, err = r.reconcile(context.Background())
+	if err != nil || results[0].Action != ActionUnchanged || results[1].Action != ActionUnchanged {
+		t.Fatalf("expected DNS-only records to count as synced, got %+v err=%v", results, err)
+	}
+	if strings.Contains(logs.String(), "[WARN]") {
+		t.Fatalf("expected the fallback WARN only once per host, got:\n%s", logs.String())
+	}
+}
+
+func TestProxiedToggleIgnoredByCloudflareIsLogged(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7", TTL: autoTTL}}
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method == http.MethodPut {
+			return http.StatusOK, `{"success":true,"result":{"id":"r1","name":"app.example.com","type":"A","content":"203.0.113.7","proxied":false}}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.DefaultProxied = true
+	cfg.ReconcileFields = []string{"content", "proxied"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	results, err := r.reconcile(context.Background())
+	if err != nil || results[0].Action != ActionUpdated {
+		t.Fatalf("expected accepted update, got %+v (%v)", results, err)
+	}
+	if !strings.Contains(logs.String(), "kept proxied=false") {
+		t.Fatalf("expected ignored toggle to be logged, got %q", logs.String())
+	}
+}
+
+func TestReconcileFieldsNormalizeAndValidate(t *testing.T) {
+	cfg := normalizeConfig(Config{ReconcileFields: []string{" Content ", "PROXIED", "content"}})
+	if len(cfg.ReconcileFields) != 2 || cfg.ReconcileFields[0] != "content" || cfg.ReconcileFields[1] != "proxied" {
+		t.Fatalf("unexpected normalized fields: %v", cfg.ReconcileFields)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	cfg = normalizeConfig(Config{})
+	if len(cfg.ReconcileFields) != 1 || cfg.ReconcileFields[0] != "content" {
+		t.Fatalf("expected default content field, got %v", cfg.ReconcileFields)
+	}
+
+	cfg = normalizeConfig(Config{ReconcileFields: []string{"content", "priority"}})
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected unknown field to be rejected")
+	}
+}
+
+func TestPruneStaleHonorsGracePeriod(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Method == http.MethodDelete {
+			deleted = append(deleted, req.URL.Path)
+			_, _ = rw.Write([]byte(`{"success":true,"result":{"id":"x"}}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"success":true,"result":[` +
+			`{"id":"1","name":"gone.example.com","type":"A","content":"203.0.113.1","comment":"managed-by=traefik-plugin-ddns"},` +
+			`{"id":"2","name":"manual.example.com","type":"A","content":"203.0.113.1","comment":""},` +
+			`{"id":"3","name":"app.example.com","type":"A","content":"203.0.113.1","comment":"managed-by=traefik-plugin-ddns"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "token"
+	cfg.PruneStale = true
+	cfg.PruneGracePeriodSeconds = 60
+	r, err := newRunner(cfg)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	r.client.baseURL = server.URL
+
+	zones := []cfZone{{ID: "z1", Name: "example.com"}}
+	start := time.Now()
+	r.pruneStale(context.Background(), zones, []string{"app.example.com"}, start)
+	if len(deleted) != 0 {
+		t.Fatalf("expected no deletion inside grace period, got %v", deleted)
+	}
+	if st, ok := r.hostStates["gone.example.com"]; !ok || st.absentSince.IsZero() {
+		t.Fatalf("expected absence to be tracked")
+	}
+
+	// Host reappears: its timer is cleared.
+	r.pruneStale(context.Background(), zones, []string{"app.example.com", "gone.example.com"}, start.Add(30*time.Second))
+	if st, ok := r.hostStates["gone.example.com"]; ok && !st.absentSince.IsZero() {
+		t.Fatalf("expected absence timer to reset when host reappears")
+	}
+
+	r.pruneStale(context.Background(), zones, []string{"app.example.com"}, start.Add(40*time.Second))
+	r.pruneStale(context.Background(), zones, []string{"app.example.com"}, start.Add(110*time.Second))
+	if len(deleted) != 1 || deleted[0] != "/zones/z1/dns_records/1" {
+		t.Fatalf("expected only the owned stale record to be deleted, got %v", deleted)
+	}
+}
+
+func TestPruneStaleOwnsCLIRecords(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "1", Name: "cli.example.com", Type: "A", Content: "203.0.113.1", Comment: "managed-by=ddns-traefik-sync"},
+		{ID: "2", Name: "old.example.com", Type: "A", Content: "203.0.113.1", Comment: "managed-by=legacy"},
+		{ID: "3", Name: "manual.example.com", Type: "A", Content: "203.0.113.1", Comment: "hand made"},
+	}
+	cfg := CreateConfig()
+	cfg.PruneStale = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	zones := []cfZone{{ID: "z1", Name: "example.com"}}
+	r.pruneStale(context.Background(), zones, nil, time.Now())
+	if len(cf.records["z1"]) != 2 || cf.records["z1"][0].ID != "2" {
+		t.Fatalf("expected the CLI-created record to be pruned by default, got %+v", cf.records["z1"])
+	}
+
+	r.cfg.AdditionalOwnedComments = []string{"managed-by=legacy"}
+	r.pruneStale(context.Background(), zones, nil, time.Now())
+	if len(cf.records["z1"]) != 1 || cf.records["z1"][0].ID != "3" {
+		t.Fatalf("expected configured legacy comment to be owned, got %+v", cf.records["z1"])
+	}
+}
+
+func TestNormalizeAdditionalOwnedComments(t *testing.T) {
+	cfg := normalizeConfig(Config{AdditionalOwnedComments: []string{" managed-by=legacy ", "", "managed-by=legacy"}})
+	if len(cfg.AdditionalOwnedComments) != 1 || cfg.AdditionalOwnedComments[0] != "managed-by=legacy" {
+		t.Fatalf("unexpected normalized comments: %v", cfg.AdditionalOwnedComments)
+	}
+	cfg = normalizeConfig(Config{})
+	if !hasField(cfg.AdditionalOwnedComments, "managed-by=ddns-traefik-sync") || !hasField(cfg.AdditionalOwnedComments, "managed-by=traefik-plugin-ddns") {
+		t.Fatalf("expected both historical defaults, got %v", cfg.AdditionalOwnedComments)
+	}
+	if cfg = normalizeConfig(Config{AdditionalOwnedComments: []string{}}); len(cfg.AdditionalOwnedComments) != 0 {
+		t.Fatalf("expected an explicit empty list to stay empty, got %v", cfg.AdditionalOwnedComments)
+	}
+	if cfg = normalizeConfig(Config{ManagedComment: "managed-by=edge"}); len(cfg.AdditionalOwnedComments) != 0 {
+		t.Fatalf("expected no default owners with a custom managedComment, got %v", cfg.AdditionalOwnedComments)
+	}
+}
+
+func TestPruneStaleCustomCommentLeavesDefaultMarkedRecords(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "1", Name: "mine.example.com", Type: "A", Content: "203.0.113.1", Comment: "managed-by=edge"},
+		{ID: "2", Name: "plugin.example.com", Type: "A", Content: "203.0.113.1", Comment: defaultManagedComment},
+		{ID: "3", Name: "cli.example.com", Type: "A", Content: "203.0.113.1", Comment: cliManagedComment},
+	}
+	cfg := CreateConfig()
+	cfg.ManagedComment = "managed-by=edge"
+	cfg.PruneStale = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	r.pruneStale(context.Background(), []cfZone{{ID: "z1", Name: "example.com"}}, nil, time.Now())
+	if len(cf.records["z1"]) != 2 || cf.records["z1"][0].ID != "2" || cf.records["z1"][1].ID != "3" {
+		t.Fatalf("expected only the custom-comment record to be pruned, got %+v", cf.records["z1"])
+	}
+}
+
+func TestVersionPrefersLdflagsValue(t *testing.T) {
+	if got := Version(); got == "" {
+		t.Fatalf("expected non-empty version")
+	}
+	old := version
+	version = "v9.9.9"
+	defer func() { version = old }()
+	if got := Version(); got != "v9.9.9" {
+		t.Fatalf("expected ldflags version, got %s", got)
+	}
+}
+
+func TestClampSyncInterval(t *testing.T) {
+	if got, clamped := clampSyncInterval(5); !clamped || got != 30 {
+		t.Fatalf("expected 5s to clamp to 30s, got %d clamped=%v", got, clamped)
+	}
+	if got, clamped := clampSyncInterval(300); clamped || got != 300 {
+		t.Fatalf("expected 300s untouched, got %d clamped=%v", got, clamped)
+	}
+	t.Setenv("MIN_SYNC_INTERVAL_SECONDS", "5")
+	if got, clamped := clampSyncInterval(5); clamped || got != 5 {
+		t.Fatalf("expected env floor override, got %d clamped=%v", got, clamped)
+	}
+}
+
+func TestWithWWWAliases(t *testing.T) {
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "token"
+	r, err := newRunner(cfg)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+
+	www := CreateConfig()
+	www.AutoWWW = true
+	www.Domains = []string{"example.com", "example.net", "app.example.com"}
+	www.ExcludeDomains = []string{"www.example.net"}
+	if err := r.RegisterConfig("www", normalizeConfig(*www)); err != nil {
+		t.Fatalf("RegisterConfig failed: %v", err)
+	}
+
+	zones := []cfZone{{ID: "1", Name: "example.com"}, {ID: "2", Name: "example.net"}}
+	hosts := r.withWWWAliases(r.snapshotHosts(), zones)
+
+	set := make(map[string]bool)
+	for _, h := range hosts {
+		if set[h] {
+			t.Fatalf("duplicate host %s", h)
+		}
+		set[h] = true
+	}
+	if !set["www.example.com"] {
+		t.Fatalf("expected www alias for apex, got %v", hosts)
+	}
+	if set["www.example.net"] {
+		t.Fatalf("expected excluded alias to be skipped, got %v", hosts)
+	}
+	if set["www.app.example.com"] {
+		t.Fatalf("did not expect alias for non-apex host, got %v", hosts)
+	}
+}
+
+func TestPrimaryMiddlewareOwnsRunnerSettings(t *testing.T) {
+	first := normalizeConfig(*CreateConfig())
+	first.APIToken = "first-token"
+	first.Zone = "example.net"
+	first.Domains = []string{"a.example.net"}
+	r, err := newRunner(first)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	if err := r.RegisterConfig("first", first); err != nil {
+		t.Fatalf("RegisterConfig failed: %v", err)
+	}
+
+	primary := CreateConfig()
+	primary.Primary = true
+	primary.APIToken = "primary-token"
+	primary.Zone = "example.com"
+	primary.Domains = []string{"b.example.com"}
+	if err := r.RegisterConfig("primary", normalizeConfig(*primary)); err != nil {
+		t.Fatalf("RegisterConfig failed: %v", err)
+	}
+	if r.client.apiToken != "primary-token" || r.cfg.Zone != "example.com" {
+		t.Fatalf("expected primary settings, got token=%q zone=%q", r.client.apiToken, r.cfg.Zone)
+	}
+
+	// Later non-primary middlewares only add hosts.
+	late := CreateConfig()
+	late.APIToken = "late-token"
+	late.Zone = "example.org"
+	late.Domains = []string{"c.example.org"}
+	if err := r.RegisterConfig("late", normalizeConfig(*late)); err != nil {
+		t.Fatalf("RegisterConfig failed: %v", err)
+	}
+	if r.client.apiToken != "primary-token" || r.cfg.Zone != "example.com" || len(r.snapshotHosts()) != 3 {
+		t.Fatalf("expected non-primary to only add hosts, got token=%q zone=%q hosts=%v", r.client.apiToken, r.cfg.Zone, r.snapshotHosts())
+	}
+
+	// A reload of the primary is fine; a second primary is not.
+	if err := r.RegisterConfig("primary", normalizeConfig(*primary)); err != nil {
+		t.Fatalf("expected primary reload to be accepted: %v", err)
+	}
+	late.Primary = true
+	if err := r.RegisterConfig("late", normalizeConfig(*late)); err == nil || !strings.Contains(err.Error(), "middleware=primary") {
+		t.Fatalf("expected second primary to be rejected, got %v", err)
+	}
+}
+
+func TestNewRunnerFailOnEmpty(t *testing.T) {
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "token"
+	cfg.FailOnEmpty = true
+	if _, err := newRunner(cfg); err == nil {
+		t.Fatalf("expected error when no hosts are configured")
+	}
+
+	cfg.Domains = []string{"app.example.com"}
+	if _, err := newRunner(cfg); err != nil {
+		t.Fatalf("unexpected error with hosts configured: %v", err)
+	}
+}
+
+func TestSanitizeComment(t *testing.T) {
+	long := "managed-by=traefik-plugin-ddns " + strings.Repeat("x", 120)
+	got, truncated := sanitizeComment(long)
+	if !truncated {
+		t.Fatalf("expected over-length comment to be truncated")
+	}
+	if len([]rune(got)) != maxCommentLength {
+		t.Fatalf("expected %d characters, got %d", maxCommentLength, len([]rune(got)))
+	}
+
+	got, truncated = sanitizeComment("managed\tby\nplugin\x00")
+	if truncated {
+		t.Fatalf("did not expect truncation")
+	}
+	if got != "managedbyplugin" {
+		t.Fatalf("expected control characters stripped, got %q", got)
+	}
+}
+
+func TestConfigHostsMergesRouterRules(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.RouterRule = "Host(`app.example.com`)"
+	cfg.RouterRules = []string{
+		"Host(`api.example.com`) && PathPrefix(`/v1`)",
+		"Host(`app.example.com`,`docs.example.com`)",
+	}
+	hosts := configHosts(normalizeConfig(*cfg))
+	sort.Strings(hosts)
+	want := []string{"api.example.com", "app.example.com", "docs.example.com"}
+	if strings.Join(hosts, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", hosts, want)
+	}
+}
+
+func TestConfigHostsDropsReservedTLDs(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.RouterRule = "Host(`app.localhost`) || Host(`app.test`) || Host(`app.example.com`)"
+	cfg.Domains = []string{"nas.local", "x.invalid", "docs.example", "testing.example.org"}
+	hosts := configHosts(normalizeConfig(*cfg))
+	sort.Strings(hosts)
+	want := []string{"app.example.com", "testing.example.org"}
+	if strings.Join(hosts, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", hosts, want)
+	}
+
+	cfg.AllowReservedTLDs = true
+	if hosts := configHosts(normalizeConfig(*cfg)); len(hosts) != 7 {
+		t.Fatalf("expected allowReservedTlds to keep every host, got %v", hosts)
+	}
+}
+
+func TestInsecureSkipVerifyIPSourcesOnlyAffectsIPLookups(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("203.0.113.9"))
+	}))
+	defer server.Close()
+
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "token"
+	if _, err := resolvePublicIPv4(context.Background(), []string{server.URL}, newIPSourceClient(cfg)); err == nil {
+		t.Fatalf("expected self-signed source to fail with verification enabled")
+	}
+
+	cfg.InsecureSkipVerifyIPSources = true
+	r, err := newRunner(cfg)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	got, err := resolvePublicIPv4(context.Background(), []string{server.URL}, r.ipClient)
+	if err != nil || got != "203.0.113.9" {
+		t.Fatalf("expected insecure IP lookup to succeed, got %q err=%v", got, err)
+	}
+	if r.client.httpClient == r.ipClient || r.client.httpClient.Transport != nil {
+		t.Fatalf("cloudflare client must keep the default verifying transport")
+	}
+}
+
+// fakeCloudflare is a minimal in-memory Cloudflare DNS API.
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	zones   []cfZone
+	records map[string][]cfRecord // zone ID -> records
+	calls   []string
+	nextID  int
+	// fail, when set, can force an error response for a request.
+	fail func(req *http.Request) (int, string, bool)
+}
+
+func newFakeCloudflare(zones ...cfZone) *fakeCloudflare {
+	return &fakeCloudflare{zones: zones, records: make(map[string][]cfRecord)}
+}
+
+func (f *fakeCloudflare) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, req.Method+" "+req.URL.Path)
+	if f.fail != nil {
+		if status, body, ok := f.fail(req); ok {
+			rw.WriteHeader(status)
+			_, _ = rw.Write([]byte(body))
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	write := func(result interface{}) {
+		raw, _ := json.Marshal(map[string]interface{}{"success": true, "result": result})
+		_, _ = rw.Write(raw)
+	}
+	switch {
+	case len(parts) == 1 && parts[0] == "zones":
+		write(f.zones)
+	case len(parts) == 3 && parts[2] == "dns_records" && req.Method == http.MethodGet:
+		q := req.URL.Query()
+		out := []cfRecord{}
+		for _, rec := range f.records[parts[1]] {
+			if t := q.Get("type"); t != "" && rec.Type != t {
+				continue
+			}
+			if n := q.Get("name"); n != "" && !strings.EqualFold(rec.Name, n) {
+				continue
+			}
+			out = append(out, rec)
+		}
+		write(out)
+	case len(parts) == 3 && parts[2] == "dns_records" && req.Method == http.MethodPost:
+		var rec cfRecord
+		_ = json.NewDecoder(req.Body).Decode(&rec)
+		f.nextID++
+		rec.ID = fmt.Sprintf("new-%d", f.nextID)
+		f.records[parts[1]] = append(f.records[parts[1]], rec)
+		write(rec)
+	case len(parts) == 4 && parts[2] == "dns_records" && (req.Method == http.MethodPut || req.Method == http.MethodPatch):
+		var rec cfRecord
+		_ = json.NewDecoder(req.Body).Decode(&rec)
+		for i, existing := range f.records[parts[1]] {
+			if existing.ID == parts[3] {
+				rec.ID = existing.ID
+				f.records[parts[1]][i] = rec
+			}
+		}
+		write(rec)
+	case len(parts) == 4 && parts[2] == "dns_records" && req.Method == http.MethodDelete:
+		kept := f.records[parts[1]][:0]
+		for _, existing := range f.records[parts[1]] {
+			if existing.ID != parts[3] {
+				kept = append(kept, existing)
+			}
+		}
+		f.records[parts[1]] = kept
+		write(map[string]string{"id": parts[3]})
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+		_, _ = rw.Write([]byte(`{"success":false,"errors":[{"code":7003,"message":"not found"}]}`))
+	}
+}
+
+func (f *fakeCloudflare) countCalls(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, call := range f.calls {
+		if strings.HasPrefix(call, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// newTestRunner wires a runner to a fake Cloudflare API and a fixed IP source.
+func newTestRunner(t *testing.T, cfg *Config, cf http.Handler, publicIP string) *Runner {
+	t.Helper()
+	cfServer := httptest.NewServer(cf)
+	t.Cleanup(cfServer.Close)
+	ipServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(publicIP))
+	}))
+	t.Cleanup(ipServer.Close)
+
+	cfg.APIToken = "token"
+	cfg.IPSources = []string{ipServer.URL}
+	effective := normalizeConfig(*cfg)
+	if err := validateConfig(effective); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	r, err := newRunner(effective)
+	if err != nil {
+		t.Fatalf("newRunner failed: %v", err)
+	}
+	r.client.baseURL = cfServer.URL
+	r.client.clock = newFakeClock(time.Unix(0, 0))
+	if err := r.RegisterConfig("test", effective); err != nil {
+		t.Fatalf("RegisterConfig failed: %v", err)
+	}
+	return r
+}
+
+func TestOnCycleCompleteReceivesResultsAndRecoversPanics(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "old.example.com", Type: "A", Content: "198.51.100.1"}}
+
+	var got []DomainStatus
+	cfg := CreateConfig()
+	cfg.Domains = []string{"new.example.com", "old.example.com", "other.org"}
+	cfg.OnCycleComplete = func(results []DomainStatus, err error) {
+		got = results
+		if err != nil {
+			t.Errorf("unexpected cycle error: %v", err)
+		}
+		panic("callback bug")
+	}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	r.runSyncCycle(context.Background())
+
+	actions := make(map[string]string)
+	for _, status := range got {
+		actions[status.Domain] = status.Action
+	}
+	if actions["new.example.com"] != ActionCreated || actions["old.example.com"] != ActionUpdated || actions["other.org"] != ActionSkipped {
+		t.Fatalf("unexpected results: %+v", got)
+	}
+}
+
+func TestResolveContentPicksAddressPerHost(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"eu.example.com", "us.example.com", "broken.example.com", "bogus.example.com", "panics.example.com"}
+	cfg.ResolveContent = func(host string) (string, error) {
+		switch host {
+		case "eu.example.com":
+			return "198.51.100.10", nil
+		case "us.example.com":
+			return " 198.51.100.20 ", nil
+		case "broken.example.com":
+			return "", errors.New("no upstream")
+		case "bogus.example.com":
+			return "2001:db8::1", nil
+		}
+		panic("resolver bug")
+	}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	byDomain := make(map[string]DomainStatus)
+	for _, status := range results {
+		byDomain[status.Domain] = status
+	}
+	if s := byDomain["eu.example.com"]; s.Action != ActionCreated || s.IP != "198.51.100.10" {
+		t.Fatalf("unexpected eu result: %+v", s)
+	}
+	if s := byDomain["us.example.com"]; s.Action != ActionCreated || s.IP != "198.51.100.20" {
+		t.Fatalf("unexpected us result: %+v", s)
+	}
+	for _, host := range []string{"broken.example.com", "bogus.example.com", "panics.example.com"} {
+		if s := byDomain[host]; s.Action != ActionSkipped || !strings.Contains(s.Error, "resolveContent") {
+			t.Fatalf("expected %s to be skipped, got %+v", host, s)
+		}
+	}
+	contents := make(map[string]string)
+	for _, record := range cf.records["z1"] {
+		contents[record.Name] = record.Content
+	}
+	if len(contents) != 2 || contents["eu.example.com"] != "198.51.100.10" || contents["us.example.com"] != "198.51.100.20" {
+		t.Fatalf("unexpected records: %v", contents)
+	}
+}
+
+func TestSkipCommentLeavesMarkedRecordUntouched(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "manual.example.com", Type: "A", Content: "198.51.100.1", Comment: "owner=ops ddns:ignore"},
+		{ID: "r2", Name: "stale.example.com", Type: "A", Content: "198.51.100.1", Comment: "managed-by=traefik-plugin-ddns ddns:ignore"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"manual.example.com"}
+	cfg.SkipComment = "ddns:ignore"
+	cfg.PruneStale = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	r.runSyncCycle(context.Background())
+
+	if n := cf.countCalls(http.MethodPut) + cf.countCalls(http.MethodPost) + cf.countCalls(http.MethodDelete); n != 0 {
+		t.Fatalf("expected no writes, got calls %v", cf.calls)
+	}
+	if cf.records["z1"][0].Content != "198.51.100.1" {
+		t.Fatalf("marked record was modified: %+v", cf.records["z1"][0])
+	}
+}
+
+func TestApplyNameTemplate(t *testing.T) {
+	cases := []struct {
+		template, host, zone, want string
+	}{
+		{"{label}.dyn.{zone}", "app.example.com", "example.com", "app.dyn.example.com"},
+		{"{label}.dyn.{zone}", "example.com", "example.com", "dyn.example.com"},
+		{"{host}", "app.example.com", "example.com", "app.example.com"},
+		{"ddns-{label}.{zone}", "a.b.example.com", "example.com", "ddns-a.b.example.com"},
+	}
+	for _, tc := range cases {
+		if got := applyNameTemplate(tc.template, tc.host, tc.zone); got != tc.want {
+			t.Fatalf("template=%s host=%s got %s, want %s", tc.template, tc.host, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"{label}.{unknown}", "static.{zone}"} {
+		cfg := normalizeConfig(Config{NameTemplate: bad})
+		if err := validateConfig(cfg); err == nil {
+			t.Fatalf("expected template %q to be rejected", bad)
+		}
+	}
+}
+
+func TestNameTemplateRewritesManagedRecord(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.NameTemplate = "{label}.dyn.{zone}"
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	r.runSyncCycle(context.Background())
+
+	if len(cf.records["z1"]) != 1 || cf.records["z1"][0].Name != "app.dyn.example.com" {
+		t.Fatalf("expected templated record, got %+v", cf.records["z1"])
+	}
+}
+
+func TestReadyLineLoggedOnce(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"}, cfZone{ID: "z2", Name: "example.org"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "api.example.com", "app.example.org"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.reconcile(context.Background()); err != nil {
+			t.Fatalf("reconcile failed: %v", err)
+		}
+	}
+	line := "[INFO] ready: ip=203.0.113.7 zones=2 hosts=3 interval=300s proxied-default=false"
+	if n := strings.Count(logs.String(), line); n != 1 {
+		t.Fatalf("expected the ready line once, got %d:\n%s", n, logs.String())
+	}
+}
+
+func TestConfigStringRedactsToken(t *testing.T) {
+	cfg := normalizeConfig(*CreateConfig())
+	cfg.APIToken = "super-secret-token"
+	cfg.WebhookURL = "https://hooks.example/services/secret-hook-token"
+	out := cfg.String()
+	if strings.Contains(out, "super-secret-token") || strings.Contains(out, "secret-hook-token") {
+		t.Fatalf("token leaked in config dump:\n%s", out)
+	}
+	if !strings.Contains(out, "apiToken") || !strings.Contains(out, "(redacted)") {
+		t.Fatalf("expected masked apiToken row:\n%s", out)
+	}
+	if !strings.Contains(out, "syncIntervalSeconds") || !strings.Contains(out, "300") {
+		t.Fatalf("expected interval row:\n%s", out)
+	}
+	if strings.Contains(out, "OnCycleComplete") {
+		t.Fatalf("callback fields must not be dumped:\n%s", out)
+	}
+}
+
+func TestResolveZoneMismatchDiagnostics(t *testing.T) {
+	zones := []cfZone{{ID: "z1", Name: "example.com"}, {ID: "z2", Name: "example.org"}}
+
+	if zone, err := resolveZoneFor("example.com", "app.example.com", zones); err != nil || zone.ID != "z1" {
+		t.Fatalf("expected z1, got %+v err=%v", zone, err)
+	}
+
+	_, err := resolveZoneFor("example.com", "app.example.org", zones)
+	if err == nil || !strings.Contains(err.Error(), "not under configured zone example.com") {
+		t.Fatalf("expected domain-not-under-zone error, got %v", err)
+	}
+
+	_, err = resolveZoneFor("exmaple.com", "app.example.com", zones)
+	if err == nil || !strings.Contains(err.Error(), "configured zone exmaple.com not found") {
+		t.Fatalf("expected zone-not-found error, got %v", err)
+	}
+
+	_, err = resolveZoneFor("", "app.example.net", zones)
+	if err == nil || !strings.Contains(err.Error(), "no zone visible to the token matches app.example.net") {
+		t.Fatalf("expected no-match error, got %v", err)
+	}
+}
+
+func TestReconcileReportsZoneMismatch(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Zone = "example.com"
+	cfg.Domains = []string{"app.example.org"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionSkipped || !strings.Contains(results[0].Error, "not under configured zone") {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestDelegatedSubdomainZoneOwnsItsNames(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"}, cfZone{ID: "z2", Name: "sub.example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"sub.example.com", "app.sub.example.com", "www.example.com"}
+	cfg.AutoWWW = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	names := func(zoneID string) string {
+		var out []string
+		for _, record := range cf.records[zoneID] {
+			out = append(out, record.Name)
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+	if got := names("z2"); got != "app.sub.example.com,sub.example.com,www.sub.example.com" {
+		t.Fatalf("expected the child apex, its www alias and names under it in the delegated zone, got %s", got)
+	}
+	if got := names("z1"); got != "www.example.com" {
+		t.Fatalf("expected no child names in the parent zone, got %s", got)
+	}
+
+	// Pinning the parent zone must not pull the child's names into it.
+	for _, domain := range []string{"sub.example.com", "app.sub.example.com"} {
+		_, err := resolveZoneFor("example.com", domain, cf.zones)
+		if err == nil || !strings.Contains(err.Error(), "belongs to delegated zone sub.example.com") {
+			t.Fatalf("%s: expected a delegated zone error, got %v", domain, err)
+		}
+	}
+	if zone, err := resolveZoneFor("sub.example.com", "app.sub.example.com", cf.zones); err != nil || zone.ID != "z2" {
+		t.Fatalf("expected the delegated zone when it is configured, got %+v err=%v", zone, err)
+	}
+}
+
+func TestCreateForbiddenZoneIsRemembered(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "readonly.com"}, cfZone{ID: "z2", Name: "example.com"})
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method == http.MethodPost && strings.Contains(req.URL.Path, "/z1/") {
+			return http.StatusForbidden, `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.readonly.com", "app.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, _ := r.reconcile(context.Background())
+	for _, status := range results {
+		if status.Domain == "app.readonly.com" && status.Action != ActionFailed {
+			t.Fatalf("expected first create on read-only zone to fail, got %+v", status)
+		}
+	}
+	first := cf.countCalls("POST /zones/z1/")
+	if first == 0 {
+		t.Fatalf("expected a create attempt on the read-only zone")
+	}
+
+	results, _ = r.reconcile(context.Background())
+	if cf.countCalls("POST /zones/z1/") != first {
+		t.Fatalf("expected no further creates on the read-only zone, got %v", cf.calls)
+	}
+	for _, status := range results {
+		if status.Domain == "app.readonly.com" && status.Action != ActionSkipped {
+			t.Fatalf("expected read-only zone domain to be skipped, got %+v", status)
+		}
+	}
+	if cf.countCalls("POST /zones/z2/") != 1 {
+		t.Fatalf("expected writable zone to be unaffected, got %v", cf.calls)
+	}
+}
+
+func TestIsForbiddenClassifiesAPIErrors(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("cloudflare request failed: %w", &CloudflareAPIError{StatusCode: http.StatusForbidden}), true},
+		{fmt.Errorf("cloudflare request failed: %w", &CloudflareAPIError{StatusCode: http.StatusOK, Codes: []int{9109}}), true},
+		{fmt.Errorf("cloudflare request failed: %w", &CloudflareAPIError{StatusCode: http.StatusBadRequest, Codes: []int{81057}}), false},
+		{errors.New("dial tcp: timeout"), false},
+	}
+	for _, tc := range cases {
+		if got := isForbidden(tc.err); got != tc.want {
+			t.Errorf("isForbidden(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestApexCNAMEIsSkippedByDefault(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "c1", Name: "example.com", Type: "CNAME", Content: "cdn.example.net"}}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionSkipped {
+		t.Fatalf("expected apex with CNAME to be skipped, got %+v", results)
+	}
+	if cf.countCalls(http.MethodPost) != 0 || cf.countCalls(http.MethodDelete) != 0 {
+		t.Fatalf("expected no writes, got %v", cf.calls)
+	}
+}
+
+func TestApexCNAMEOverrideReplacesCNAME(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "c1", Name: "example.com", Type: "CNAME", Content: "cdn.example.net"},
+		{ID: "c2", Name: "www.example.com", Type: "CNAME", Content: "example.com"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"example.com", "www.example.com"}
+	cfg.AllowApexCNAMEOverride = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, status := range results {
+		actions[status.Domain] = status.Action
+	}
+	if actions["example.com"] != ActionCreated || actions["www.example.com"] != ActionSkipped {
+		t.Fatalf("expected apex replaced and subdomain CNAME left alone, got %v", actions)
+	}
+	for _, rec := range cf.records["z1"] {
+		if rec.ID == "c1" {
+			t.Fatalf("expected apex CNAME to be deleted, got %+v", cf.records["z1"])
+		}
+	}
+}
+
+func TestApexCNAMEOverrideRestoresCNAMEWhenCreateFails(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "c1", Name: "example.com", Type: "CNAME", Content: "cdn.example.net", TTL: 300, Comment: "cdn"}}
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method != http.MethodPost {
+			return 0, "", false
+		}
+		body, _ := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if strings.Contains(string(body), `"type":"A"`) {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":9005,"message":"Content for A record is invalid"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"example.com"}
+	cfg.AllowApexCNAMEOverride = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, _ := r.reconcile(context.Background())
+	if len(results) != 1 || results[0].Action != ActionFailed {
+		t.Fatalf("expected the apex create to fail, got %+v", results)
+	}
+	if len(cf.records["z1"]) != 1 {
+		t.Fatalf("expected only the restored CNAME, got %+v", cf.records["z1"])
+	}
+	if rec := cf.records["z1"][0]; rec.Type != "CNAME" || rec.Content != "cdn.example.net" || rec.TTL != 300 || rec.Comment != "cdn" {
+		t.Fatalf("expected the apex CNAME restored as it was, got %+v", rec)
+	}
+}
+
+func TestAppendDefaultIPSources(t *testing.T) {
+	cfg := normalizeConfig(Config{IPSources: []string{"https://ip.internal", defaultIPSources[1]}, AppendDefaultIPSources: true})
+	if cfg.IPSources[0] != "https://ip.internal" || len(cfg.IPSources) != len(defaultIPSources)+1 {
+		t.Fatalf("expected custom source first followed by unique defaults, got %v", cfg.IPSources)
+	}
+
+	cfg = normalizeConfig(Config{IPSources: []string{"https://ip.internal"}})
+	if len(cfg.IPSources) != 1 {
+		t.Fatalf("expected custom sources to replace defaults without the option, got %v", cfg.IPSources)
+	}
+}
+
+func TestCreateConflictUpdatesExistingRecord(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.Method != http.MethodPost {
+			return 0, "", false
+		}
+		// Another instance created the record between our list and create.
+		cf.records["z1"] = []cfRecord{{ID: "other", Name: "app.example.com", Type: "A", Content: "198.51.100.1"}}
+		return http.StatusBadRequest, `{"success":false,"errors":[{"code":81057,"message":"Record already exists."}]}`, true
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionUpdated {
+		t.Fatalf("expected create conflict to turn into an update, got %+v", results)
+	}
+	if got := cf.records["z1"][0]; got.ID != "other" || got.Content != "203.0.113.10" {
+		t.Fatalf("expected existing record to be updated, got %+v", got)
+	}
+}
+
+func TestMaxCreatesPerCycleDefersRemainder(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com"}
+	cfg.MaxCreatesPerCycle = 2
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	for cycle, want := range []int{2, 4, 5} {
+		if _, err := r.reconcile(context.Background()); err != nil {
+			t.Fatalf("cycle %d: reconcile failed: %v", cycle, err)
+		}
+		if got := cf.countCalls(http.MethodPost); got != want {
+			t.Fatalf("cycle %d: expected %d creates in total, got %d", cycle, want, got)
+		}
+	}
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func TestRecordDefaultsPrecedence(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "cdn.com"}, cfZone{ID: "z2", Name: "infra.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"www.cdn.com", "api.cdn.com", "cache.cdn.com", "db.infra.com"}
+	cfg.DefaultProxied = false
+	cfg.TTL = 300
+	cfg.ZoneDefaults = map[string]RecordDefaults{"CDN.com": {Proxied: boolPtr(true)}}
+	cfg.DomainOverrides = map[string]RecordDefaults{
+		"api.cdn.com":   {Proxied: boolPtr(false), TTL: 120},
+		"cache.cdn.com": {TTL: 600},
+	}
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	got := map[string]cfRecord{}
+	for _, zone := range []string{"z1", "z2"} {
+		for _, rec := range cf.records[zone] {
+			got[rec.Name] = rec
+		}
+	}
+	cases := []struct {
+		name    string
+		proxied bool
+		ttl     int
+	}{
+		{"www.cdn.com", true, autoTTL},   // zone default; proxied records are written with the automatic TTL
+		{"api.cdn.com", false, 120},      // domain override beats zone default
+		{"cache.cdn.com", true, autoTTL}, // a TTL-only override keeps the zone's proxied setting
+		{"db.infra.com", false, 300},     // global defaults
+	}
+	for _, tc := range cases {
+		rec, ok := got[tc.name]
+		if !ok || rec.Proxied != tc.proxied || rec.TTL != tc.ttl {
+			t.Errorf("%s: expected proxied=%v ttl=%d, got %+v", tc.name, tc.proxied, tc.ttl, rec)
+		}
+	}
+}
+
+func TestValidateConfigRejectsInvalidTTL(t *testing.T) {
+	for _, cfg := range []Config{
+		{TTL: 30},
+		{ZoneDefaults: map[string]RecordDefaults{"example.com": {TTL: 100000}}},
+	} {
+		if err := validateConfig(normalizeConfig(cfg)); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestAccountIDDisambiguatesSameNamedZones(t *testing.T) {
+	// The fake ignores the account.id query, like an API answer that still mixes accounts.
+	cf := newFakeCloudflare(
+		cfZone{ID: "z-a", Name: "example.com", Account: cfAccount{ID: "acc-a"}},
+		cfZone{ID: "z-b", Name: "example.com", Account: cfAccount{ID: "acc-b"}},
+	)
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.AccountID = "acc-b"
+	r := newTestRunner(t, cfg, cf, "203.0.113.10")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(cf.records["z-a"]) != 0 || len(cf.records["z-b"]) != 1 {
+		t.Fatalf("expected record only in the configured account's zone, got %+v", cf.records)
+	}
+
+	if dups := duplicateZoneNames(cf.zones); len(dups) != 1 || dups[0] != "example.com" {
+		t.Fatalf("expected example.com to be reported as duplicate, got %v", dups)
+	}
+	if dups := duplicateZoneNames(zonesInAccount(cf.zones, "acc-b")); len(dups) != 0 {
+		t.Fatalf("expected no duplicates once scoped to an account, got %v", dups)
+	}
+}
+
+func TestHasDesiredARecordComparesAddresses(t *testing.T) {
+	cases := []struct {
+		stored, desired string
+		want            bool
+	}{
+		{" 203.0.113.8\n", "203.0.113.8", true},
+		{"::ffff:203.0.113.8", "203.0.113.8", true},
+		{"2001:DB8:0:0::1", "2001:db8::1", true},
+		{"203.0.113.9", "203.0.113.8", false},
+	}
+	for _, tc := range cases {
+		records := []cfRecord{{Name: "app.example.com", Type: "A", Content: tc.stored}}
+		if got := hasDesiredARecord(records, "app.example.com", tc.desired); got != tc.want {
+			t.Errorf("stored %q desired %q: got %v, want %v", tc.stored, tc.desired, got, tc.want)
+		}
+	}
+}
+
+func TestSelfHostsAreExcludedUnlessManaged(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "traefik.example.com", "status.example.com"}
+	cfg.SelfHost = "Traefik.example.com"
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	// Set after construction so the test does not bind a listener.
+	r.cfg.StatusAddr = "status.example.com:8099"
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Domain != "app.example.com" {
+		t.Fatalf("expected only app.example.com managed, got %+v", results)
+	}
+
+	r.cfg.ManageSelfHost = true
+	if results, _ = r.reconcile(context.Background()); len(results) != 3 {
+		t.Fatalf("expected self hosts managed with manageSelfHost, got %+v", results)
+	}
+}
+
+func TestMixedCaseDiscoveredHostMatchesRecord(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7"}}
+	cfg := CreateConfig()
+	cfg.RouterRule = "Host(`App.Example.com`)"
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionUnchanged || cf.countCalls("POST") != 0 {
+		t.Fatalf("expected mixed-case host to match its record, got %+v (%v)", results, cf.calls)
+	}
+}
+
+func TestPreserveHostCaseKeepsCasingAndMatchesRecords(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "203.0.113.7"}}
+	cfg := CreateConfig()
+	cfg.RouterRule = "Host(`App.Example.com`) || Host(`New.Example.com`)"
+	cfg.PreserveHostCase = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	actions := map[string]string{}
+	for _, result := range results {
+		actions[result.Domain] = result.Action
+	}
+	if actions["app.example.com"] != ActionUnchanged || actions["new.example.com"] != ActionCreated || cf.countCalls("POST") != 1 {
+		t.Fatalf("expected the existing record to match and one create, got %+v (%v)", results, cf.calls)
+	}
+	if records := cf.records["z1"]; len(records) != 2 || records[1].Name != "New.Example.com" {
+		t.Fatalf("expected the created record to keep its casing, got %+v", records)
+	}
+
+	cfg.PreserveHostCase = false
+	cf = newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	r = newTestRunner(t, cfg, cf, "203.0.113.7")
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, record := range cf.records["z1"] {
+		if record.Name != strings.ToLower(record.Name) {
+			t.Fatalf("expected lowercase names by default, got %+v", cf.records["z1"])
+		}
+	}
+}
+
+func TestCommentTemplateRendersPerRecordAndKeepsOwnership(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.CommentTemplate = "host={host} ip={ip} at={time} by={instance}"
+	cfg.ReconcileFields = []string{"content", "comment"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	r.clock = newFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	r.instance = "edge-1"
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	want := "managed-by=traefik-plugin-ddns host=app.example.com ip=203.0.113.7 at=2026-01-02T03:04:05Z by=edge-1"
+	if got := cf.records["z1"][0].Comment; got != want {
+		t.Fatalf("unexpected comment:\n got %q\nwant %q", got, want)
+	}
+	if !r.ownsRecord("example.com", cf.records["z1"][0]) {
+		t.Fatalf("expected templated record to stay owned")
+	}
+
+	// A later cycle at another time is not comment drift.
+	r.clock.(*fakeClock).Advance(time.Hour)
+	results, err := r.reconcile(context.Background())
+	if err != nil || results[0].Action != ActionUnchanged {
+		t.Fatalf("expected templated comment not to cause updates, got %+v (%v)", results, err)
+	}
+}
+
+func TestZoneCommentsOnCreateAndPrune(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"}, cfZone{ID: "z2", Name: "example.org"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "1", Name: "gone.example.com", Type: "A", Content: "203.0.113.1", Comment: "managed-by=edge"},
+	}
+	cf.records["z2"] = []cfRecord{
+		{ID: "2", Name: "gone.example.org", Type: "A", Content: "203.0.113.1", Comment: "managed-by=org-team host=gone.example.org"},
+		{ID: "3", Name: "other.example.org", Type: "A", Content: "203.0.113.1", Comment: "managed-by=edge"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "app.example.org"}
+	cfg.ManagedComment = "managed-by=edge"
+	cfg.ZoneComments = map[string]string{"Example.org.": " managed-by=org-team "}
+	cfg.PruneStale = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	comments := map[string]string{}
+	for _, zone := range []string{"z1", "z2"} {
+		for _, record := range cf.records[zone] {
+			comments[record.Name] = record.Comment
+		}
+	}
+	if comments["app.example.com"] != "managed-by=edge" || comments["app.example.org"] != "managed-by=org-team" {
+		t.Fatalf("expected per-zone comments on create, got %v", comments)
+	}
+	if _, ok := comments["gone.example.com"]; ok {
+		t.Fatalf("expected the global comment to own records in unlisted zones, got %v", comments)
+	}
+	if _, ok := comments["gone.example.org"]; ok {
+		t.Fatalf("expected the zone comment to own records in its zone, got %v", comments)
+	}
+	if _, ok := comments["other.example.org"]; !ok {
+		t.Fatalf("expected the global comment not to own records in a zone with its own comment, got %v", comments)
+	}
+}
+
+func TestValidateCommentTemplate(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.CommentTemplate = "rule={rule}"
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected unknown placeholder to be rejected")
+	}
+	cfg.CommentTemplate = "updated {time}"
+	cfg.ManagedComment = strings.Repeat("x", maxCommentLength)
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected managedComment without room for the template to be rejected")
+	}
+}
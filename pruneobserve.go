@@ -0,0 +1,55 @@
+package ddns_traefik_plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pruneObserve tracks the PruneObserveUntil period, which starts with the first prune pass.
+type pruneObserve struct {
+	started time.Time
+	passes  int
+	ended   bool
+}
+
+// parsePruneObserveUntil reads PruneObserveUntil as a duration ("72h") or a cycle count ("20cycles").
+// Exactly one of the results is non-zero.
+func parsePruneObserveUntil(value string) (time.Duration, int, error) {
+	if count, ok := strings.CutSuffix(value, "cycles"); ok {
+		cycles, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil || cycles <= 0 {
+			return 0, 0, fmt.Errorf("invalid pruneObserveUntil %q: expected a positive cycle count such as 20cycles", value)
+		}
+		return 0, cycles, nil
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("invalid pruneObserveUntil %q: expected a duration such as 72h or a cycle count such as 20cycles", value)
+	}
+	return period, 0, nil
+}
+
+// pruneObserving reports whether this prune pass only logs what it would delete. It is called once per
+// pass and logs when the observation period starts and when it ends.
+func (r *Runner) pruneObserving(now time.Time) bool {
+	if r.cfg.PruneObserveUntil == "" || r.pruneObserve.ended {
+		return false
+	}
+	period, cycles, err := parsePruneObserveUntil(r.cfg.PruneObserveUntil)
+	if err != nil {
+		return false
+	}
+	if r.pruneObserve.started.IsZero() {
+		r.pruneObserve.started = now
+		r.infof("[PRUNE-OBSERVE] prune decisions are logged, not executed, for %s", r.cfg.PruneObserveUntil)
+	}
+	r.pruneObserve.passes++
+	if (period > 0 && now.Sub(r.pruneObserve.started) >= period) || (cycles > 0 && r.pruneObserve.passes > cycles) {
+		r.pruneObserve.ended = true
+		r.infof("[PRUNE-OBSERVE] observation period of %s ended; pruning now deletes records", r.cfg.PruneObserveUntil)
+		return false
+	}
+	return true
+}
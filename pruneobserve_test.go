@@ -0,0 +1,77 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneObserveUntilDuration(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "1", Name: "gone.example.com", Type: "A", Content: "203.0.113.1", Comment: defaultManagedComment}}
+	cfg := CreateConfig()
+	cfg.PruneStale = true
+	cfg.PruneObserveUntil = " 1H "
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	var logs bytes.Buffer
+	r.logger = log.New(&logs, "", 0)
+
+	zones := []cfZone{{ID: "z1", Name: "example.com"}}
+	start := time.Unix(0, 0)
+	r.pruneStale(context.Background(), zones, nil, start)
+	r.pruneStale(context.Background(), zones, nil, start.Add(59*time.Minute))
+	if cf.countCalls("DELETE") != 0 || len(cf.records["z1"]) != 1 {
+		t.Fatalf("expected no deletion while observing, got %v", cf.calls)
+	}
+	if n := strings.Count(logs.String(), "[PRUNE-OBSERVE] would delete A record domain=gone.example.com"); n != 2 {
+		t.Fatalf("expected 2 would-delete lines, got %d:\n%s", n, logs.String())
+	}
+
+	r.pruneStale(context.Background(), zones, nil, start.Add(time.Hour))
+	if len(cf.records["z1"]) != 0 {
+		t.Fatalf("expected the record to be pruned once observation ended, got %+v", cf.records["z1"])
+	}
+	if !strings.Contains(logs.String(), "[PRUNE-OBSERVE] observation period of 1h ended; pruning now deletes records") {
+		t.Fatalf("expected an end-of-observation line, got:\n%s", logs.String())
+	}
+}
+
+func TestPruneObserveUntilCycles(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "1", Name: "gone.example.com", Type: "A", Content: "203.0.113.1", Comment: defaultManagedComment}}
+	cfg := CreateConfig()
+	cfg.PruneStale = true
+	cfg.PruneObserveUntil = "2cycles"
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	zones := []cfZone{{ID: "z1", Name: "example.com"}}
+	for i := 0; i < 2; i++ {
+		r.pruneStale(context.Background(), zones, nil, time.Now())
+	}
+	if len(cf.records["z1"]) != 1 {
+		t.Fatalf("expected no deletion during the first 2 cycles, got %v", cf.calls)
+	}
+	r.pruneStale(context.Background(), zones, nil, time.Now())
+	if len(cf.records["z1"]) != 0 {
+		t.Fatalf("expected the record to be pruned on the third cycle, got %+v", cf.records["z1"])
+	}
+}
+
+func TestValidatePruneObserveUntil(t *testing.T) {
+	for _, value := range []string{"soon", "0cycles", "-1h", "cycles"} {
+		cfg := CreateConfig()
+		cfg.PruneStale = true
+		cfg.PruneObserveUntil = value
+		if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+			t.Fatalf("expected pruneObserveUntil %q to be rejected", value)
+		}
+	}
+	cfg := CreateConfig()
+	cfg.PruneObserveUntil = "72h"
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected pruneObserveUntil without pruneStale to be rejected")
+	}
+}
@@ -0,0 +1,43 @@
+package ddns_traefik_plugin
+
+import "sync"
+
+// recordCache holds zone-wide record listings for the length of one sync cycle, so the seed,
+// prune, external-dns and mail-hint passes share a single read per zone and record type.
+// Any write to a zone drops that zone's listings.
+type recordCache struct {
+	mu    sync.Mutex
+	zones map[string]map[string][]cfRecord
+}
+
+func newRecordCache() *recordCache {
+	return &recordCache{zones: make(map[string]map[string][]cfRecord)}
+}
+
+// get returns a copy of the cached listing, so callers may reorder or append freely.
+func (c *recordCache) get(zoneID, recordType string) ([]cfRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	records, ok := c.zones[zoneID][recordType]
+	if !ok {
+		return nil, false
+	}
+	return append([]cfRecord(nil), records...), true
+}
+
+func (c *recordCache) put(zoneID, recordType string, records []cfRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byType, ok := c.zones[zoneID]
+	if !ok {
+		byType = make(map[string][]cfRecord)
+		c.zones[zoneID] = byType
+	}
+	byType[recordType] = append([]cfRecord(nil), records...)
+}
+
+func (c *recordCache) forgetZone(zoneID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.zones, zoneID)
+}
@@ -0,0 +1,72 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordCacheSharesZoneListingWithinCycle(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "a.example.com", Type: "A", Content: "203.0.113.7", TTL: 1},
+		{ID: "r2", Name: "b.example.com", Type: "A", Content: "203.0.113.7", TTL: 1},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com", "b.example.com"}
+	cfg.SeedFromZoneExport = true
+	cfg.PruneStale = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	// The seed and prune passes both need the zone's A records: one read serves both.
+	if n := cf.countCalls("GET /zones/z1/dns_records"); n != 1 {
+		t.Fatalf("expected one zone listing per cycle, got %v", cf.calls)
+	}
+	if r.client.listings != nil {
+		t.Fatalf("expected the cache to be dropped at the end of the cycle")
+	}
+}
+
+func TestRecordCacheRelistsZoneAfterWrite(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "a.example.com", Type: "A", Content: "203.0.113.7", TTL: 1}}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com", "c.example.com"}
+	cfg.SeedFromZoneExport = true
+	cfg.PruneStale = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if cf.countCalls("POST") != 1 {
+		t.Fatalf("expected c.example.com to be created, got %v", cf.calls)
+	}
+	// Seed listing, the CNAME check before the create, then a fresh listing for prune.
+	if n := cf.countCalls("GET /zones/z1/dns_records"); n != 3 {
+		t.Fatalf("expected prune to re-list the zone after the create, got %v", cf.calls)
+	}
+}
+
+func TestRecordCacheDropsZoneOnWrite(t *testing.T) {
+	c := newRecordCache()
+	c.put("z1", "A", []cfRecord{{ID: "r1"}})
+	c.put("z2", "TXT", nil)
+	records, ok := c.get("z1", "A")
+	if !ok || len(records) != 1 {
+		t.Fatalf("expected cached listing, got %v %v", records, ok)
+	}
+	records[0].ID = "changed"
+	if again, _ := c.get("z1", "A"); again[0].ID != "r1" {
+		t.Fatalf("expected get to return a copy, got %v", again)
+	}
+	c.forgetZone("z1")
+	if _, ok := c.get("z1", "A"); ok {
+		t.Fatalf("expected zone entries to be dropped")
+	}
+	if _, ok := c.get("z2", "TXT"); !ok {
+		t.Fatalf("expected other zones to be kept, including empty listings")
+	}
+}
@@ -0,0 +1,115 @@
+package ddns_traefik_plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cycleReport is the JSON document written to ReportFile after every cycle.
+type cycleReport struct {
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	IP         string         `json:"ip,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Counts     map[string]int `json:"counts"`
+	Domains    []DomainStatus `json:"domains"`
+}
+
+func newCycleReport(started, finished time.Time, ip string, results []DomainStatus, err error) cycleReport {
+	report := cycleReport{
+		StartedAt:  started,
+		FinishedAt: finished,
+		IP:         ip,
+		Counts:     make(map[string]int),
+		Domains:    results,
+	}
+	if report.Domains == nil {
+		report.Domains = []DomainStatus{}
+	}
+	for _, status := range results {
+		report.Counts[status.Action]++
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// writeReport replaces ReportFile with report.
+func writeReport(path string, report cycleReport) error {
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(raw, '\n'))
+}
+
+// writeFileAtomic replaces path with data. The data is written to a temp file in the same directory
+// and renamed over the target, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeCycleReport writes ReportFile when configured. Failures are logged and never fail the cycle.
+func (r *Runner) writeCycleReport(started time.Time, results []DomainStatus, err error) {
+	if r.cfg.ReportFile == "" {
+		return
+	}
+	ip := ""
+	if err == nil {
+		ip = r.lastKnownIP
+	}
+	report := newCycleReport(started, r.clock.Now(), ip, results, err)
+	if writeErr := writeReport(r.cfg.ReportFile, report); writeErr != nil {
+		r.errorf("failed writing report file %s: %v", r.cfg.ReportFile, writeErr)
+	}
+}
+
+// updateLastErrorFile keeps LastErrorFile in step with the cycle: a cycle that failed or had a failed
+// domain writes the details there, a fully successful one removes it. Failures are logged and never
+// fail the cycle.
+func (r *Runner) updateLastErrorFile(results []DomainStatus, err error) {
+	path := r.cfg.LastErrorFile
+	if path == "" {
+		return
+	}
+	var lines []string
+	if err != nil {
+		lines = append(lines, "cycle failed: "+err.Error())
+	}
+	for _, status := range results {
+		if status.Action == ActionFailed {
+			lines = append(lines, fmt.Sprintf("domain=%s sync failed: %s", status.Domain, status.Error))
+		}
+	}
+	if len(lines) == 0 {
+		if removeErr := os.Remove(path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			r.errorf("failed removing last error file %s: %v", path, removeErr)
+		}
+		return
+	}
+	content := r.clock.Now().UTC().Format(time.RFC3339) + "\n" + strings.Join(lines, "\n") + "\n"
+	if writeErr := writeFileAtomic(path, []byte(content)); writeErr != nil {
+		r.errorf("failed writing last error file %s: %v", path, writeErr)
+	}
+}
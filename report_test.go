@@ -0,0 +1,90 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCycleWritesReportFile(t *testing.T) {
+	dir := t.TempDir()
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "api.example.com", Type: "A", Content: "203.0.113.7"}}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "api.example.com", "app.other.org"}
+	cfg.ReportFile = filepath.Join(dir, "report.json")
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	r.runSyncCycle(context.Background())
+
+	raw, err := os.ReadFile(cfg.ReportFile)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report cycleReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.IP != "203.0.113.7" || len(report.Domains) != 3 || report.Error != "" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Counts[ActionCreated] != 1 || report.Counts[ActionUnchanged] != 1 || report.Counts[ActionSkipped] != 1 {
+		t.Fatalf("unexpected counts: %v", report.Counts)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected temp files cleaned up, got %v", entries)
+	}
+}
+
+func TestReportWriteFailureDoesNotFailCycle(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.ReportFile = filepath.Join(t.TempDir(), "missing", "report.json")
+	var got []DomainStatus
+	cfg.OnCycleComplete = func(results []DomainStatus, err error) { got = results }
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	r.runSyncCycle(context.Background())
+	if len(got) != 1 || got[0].Action != ActionCreated {
+		t.Fatalf("expected cycle to complete despite report failure, got %+v", got)
+	}
+}
+
+func TestLastErrorFileTracksFailedCycles(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	failing := true
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if failing && req.Method == http.MethodPost {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":1004,"message":"DNS Validation Error"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "app.other.org"}
+	cfg.LastErrorFile = filepath.Join(t.TempDir(), "last-error")
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	r.runSyncCycle(context.Background())
+	raw, err := os.ReadFile(cfg.LastErrorFile)
+	if err != nil {
+		t.Fatalf("expected last error file after a failed domain: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(raw)), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[1], "domain=app.example.com sync failed:") || !strings.Contains(lines[1], "DNS Validation Error") {
+		t.Fatalf("unexpected last error file:\n%s", raw)
+	}
+
+	failing = false
+	r.runSyncCycle(context.Background())
+	if _, err := os.Stat(cfg.LastErrorFile); !os.IsNotExist(err) {
+		t.Fatalf("expected last error file removed after a successful cycle, got %v", err)
+	}
+	// Removing a file that is already gone is not an error.
+	r.runSyncCycle(context.Background())
+}
@@ -0,0 +1,74 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// zoneSeed is an in-memory view of a zone's A records fetched with one bulk listing, used by
+// SeedFromZoneExport instead of a lookup per host.
+type zoneSeed struct {
+	fetched time.Time
+	byName  map[string][]cfRecord
+}
+
+// listARecords returns the A records at host, from the zone seed when SeedFromZoneExport is on.
+// A failed bulk fetch falls back to a per-host lookup.
+func (r *Runner) listARecords(ctx context.Context, zone *cfZone, host string) ([]cfRecord, error) {
+	if !r.cfg.SeedFromZoneExport {
+		return r.client.listARecords(ctx, zone.ID, host)
+	}
+	seed, err := r.zoneSeed(ctx, zone)
+	if err != nil {
+		r.warnf("zone=%s bulk record listing failed, looking up hosts one by one: %v", zone.Name, err)
+		return r.client.listARecords(ctx, zone.ID, host)
+	}
+	return append([]cfRecord(nil), seed.byName[host]...), nil
+}
+
+// zoneSeed returns the seed for zone, fetching it when missing or older than SeedRefreshSeconds.
+func (r *Runner) zoneSeed(ctx context.Context, zone *cfZone) (*zoneSeed, error) {
+	now := r.clock.Now()
+	if seed, ok := r.seeds[zone.ID]; ok && now.Sub(seed.fetched) < time.Duration(r.cfg.SeedRefreshSeconds)*time.Second {
+		return seed, nil
+	}
+	records, err := r.client.listZoneARecords(ctx, zone.ID)
+	if err != nil {
+		delete(r.seeds, zone.ID)
+		return nil, err
+	}
+	seed := &zoneSeed{fetched: now, byName: make(map[string][]cfRecord)}
+	for _, record := range records {
+		name := normalizeHost(record.Name)
+		seed.byName[name] = append(seed.byName[name], record)
+	}
+	for _, named := range seed.byName {
+		sort.Slice(named, func(i, j int) bool { return named[i].ID < named[j].ID })
+	}
+	r.debugf("zone=%s seeded with %d A records", zone.Name, len(records))
+	r.seeds[zone.ID] = seed
+	return seed, nil
+}
+
+// seedWritten keeps the zone seed in step with a record the runner just wrote. Without the
+// written record the seed can no longer be trusted and is dropped, forcing a fresh bulk fetch.
+func (r *Runner) seedWritten(zoneID string, record *cfRecord) {
+	seed, ok := r.seeds[zoneID]
+	if !ok {
+		return
+	}
+	if record == nil || record.ID == "" {
+		delete(r.seeds, zoneID)
+		return
+	}
+	name := normalizeHost(record.Name)
+	named := seed.byName[name]
+	for i := range named {
+		if named[i].ID == record.ID {
+			named[i] = *record
+			return
+		}
+	}
+	seed.byName[name] = append(named, *record)
+}
@@ -0,0 +1,75 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSeedFromZoneExportListsZoneOnce(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{
+		{ID: "r1", Name: "a.example.com", Type: "A", Content: "203.0.113.7"},
+		{ID: "r2", Name: "b.example.com", Type: "A", Content: "198.51.100.1"},
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com", "b.example.com", "c.example.com"}
+	cfg.SeedFromZoneExport = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	clock := newFakeClock(time.Unix(0, 0))
+	r.clock = clock
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	// One bulk listing plus the CNAME conflict check before creating c.example.com.
+	if n := cf.countCalls("GET /zones/z1/dns_records"); n != 2 {
+		t.Fatalf("expected one bulk listing, got %v", cf.calls)
+	}
+	if cf.countCalls("POST") != 1 || cf.countCalls("PUT") != 1 {
+		t.Fatalf("expected one create and one update, got %v", cf.calls)
+	}
+
+	// Own writes are reflected in the seed: nothing to do and nothing fetched.
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, status := range results {
+		if status.Action != ActionUnchanged {
+			t.Fatalf("expected every host unchanged from the seed, got %+v", results)
+		}
+	}
+	if n := cf.countCalls("GET /zones/z1/dns_records"); n != 2 {
+		t.Fatalf("expected the seed to be reused, got %v", cf.calls)
+	}
+
+	clock.Advance(time.Duration(r.cfg.SeedRefreshSeconds) * time.Second)
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if n := cf.countCalls("GET /zones/z1/dns_records"); n != 3 {
+		t.Fatalf("expected the seed to refresh after seedRefreshSeconds, got %v", cf.calls)
+	}
+}
+
+func TestSeedFallsBackToPerHostLookups(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cf.records["z1"] = []cfRecord{{ID: "r1", Name: "a.example.com", Type: "A", Content: "203.0.113.7"}}
+	cf.fail = func(req *http.Request) (int, string, bool) {
+		if req.URL.Path == "/zones/z1/dns_records" && req.URL.Query().Get("page") != "" {
+			return http.StatusBadRequest, `{"success":false,"errors":[{"code":1004,"message":"bulk listing refused"}]}`, true
+		}
+		return 0, "", false
+	}
+	cfg := CreateConfig()
+	cfg.Domains = []string{"a.example.com"}
+	cfg.SeedFromZoneExport = true
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil || len(results) != 1 || results[0].Action != ActionUnchanged {
+		t.Fatalf("expected per-host fallback to find the record, got %+v (%v)", results, err)
+	}
+}
@@ -0,0 +1,158 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SRVRecord is an SRV record kept in a managed zone, typically pointing a service at a host whose
+// A record this plugin maintains, so the service follows the dynamic IP through Target.
+type SRVRecord struct {
+	// Name is the full SRV owner name, for example _minecraft._tcp.example.com.
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Target   string `json:"target,omitempty" yaml:"target,omitempty"`
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`
+	Priority int    `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// cfSRVData is the structured content of an SRV record in the Cloudflare API.
+type cfSRVData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+func (s SRVRecord) data() cfSRVData {
+	return cfSRVData{Priority: s.Priority, Weight: s.Weight, Port: s.Port, Target: s.Target}
+}
+
+// validateSRVRecord checks one srvRecords entry after normalization.
+func validateSRVRecord(i int, s SRVRecord) error {
+	if !strings.HasPrefix(s.Name, "_") || strings.Count(s.Name, ".") < 2 {
+		return fmt.Errorf("invalid srvRecords[%d].name %q: expected _service._proto.<host>", i, s.Name)
+	}
+	if s.Target == "" {
+		return fmt.Errorf("srvRecords[%d] (%s) requires target", i, s.Name)
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		return fmt.Errorf("invalid srvRecords[%d].port %d: expected 1-65535", i, s.Port)
+	}
+	if s.Priority < 0 || s.Priority > 65535 || s.Weight < 0 || s.Weight > 65535 {
+		return fmt.Errorf("invalid srvRecords[%d] (%s): priority and weight must be 0-65535", i, s.Name)
+	}
+	return nil
+}
+
+// syncSRVRecords reconciles srvRecords after the A records. It is independent of the A-record path:
+// failures are logged per entry and never change the cycle's domain results. Only the record at Name
+// whose target matches is managed; other SRV records at the same name are left alone.
+func (r *Runner) syncSRVRecords(ctx context.Context, zones []cfZone, managed []string) {
+	for _, srv := range r.cfg.SRVRecords {
+		if !hasField(managed, srv.Target) {
+			r.warnf("srv=%s target %s is not a host managed by this plugin; its address will not follow the public ip", srv.Name, srv.Target)
+		}
+		zone, err := r.resolveZone(srv.Name, zones)
+		if err != nil {
+			r.warnf("srv=%s skipped: %v", srv.Name, err)
+			continue
+		}
+		if err := r.syncSRVRecord(ctx, zone, srv); err != nil {
+			r.errorf("srv=%s sync failed: %v", srv.Name, err)
+		}
+	}
+}
+
+func (r *Runner) syncSRVRecord(ctx context.Context, zone *cfZone, srv SRVRecord) error {
+	records, err := r.client.listSRVRecords(ctx, zone.ID, srv.Name)
+	if err != nil {
+		return err
+	}
+	desired := srv.data()
+	_, ttl := r.recordDefaults(srv.Name, zone.Name)
+	var existing *cfRecord
+	for i := range records {
+		data := records[i].Data
+		if data == nil || !strings.EqualFold(strings.TrimSuffix(data.Target, "."), srv.Target) {
+			continue
+		}
+		if *data == desired && records[i].TTL == ttl {
+			r.debugf("srv=%s unchanged", srv.Name)
+			return nil
+		}
+		existing = &records[i]
+		break
+	}
+	switch {
+	case r.writesHeld && existing == nil:
+		r.infof("would create SRV record srv=%s target=%s port=%d (%s)", srv.Name, srv.Target, srv.Port, r.holdReason)
+	case r.writesHeld:
+		r.infof("would update SRV record srv=%s target=%s port=%d (%s)", srv.Name, srv.Target, srv.Port, r.holdReason)
+	case existing == nil:
+		r.infof("create SRV record srv=%s target=%s port=%d", srv.Name, srv.Target, srv.Port)
+		_, err = r.client.createSRVRecord(ctx, zone.ID, srv.Name, desired, ttl, r.buildComment(zone.Name, srv.Name, ""))
+	default:
+		r.infof("update SRV record srv=%s target=%s port=%d", srv.Name, srv.Target, srv.Port)
+		_, err = r.client.updateSRVRecord(ctx, zone.ID, existing.ID, srv.Name, desired, ttl, r.buildComment(zone.Name, srv.Name, ""))
+	}
+	return err
+}
+
+func (c *cloudflareClient) listSRVRecords(ctx context.Context, zoneID, name string) ([]cfRecord, error) {
+	path := fmt.Sprintf("/zones/%s/dns_records?type=SRV&name=%s&per_page=100", zoneID, nameQuery(name))
+	env, err := c.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var records []cfRecord
+	if err := json.Unmarshal(env.Result, &records); err != nil {
+		return nil, fmt.Errorf("invalid dns records payload: %w", err)
+	}
+	filtered := make([]cfRecord, 0, len(records))
+	for _, record := range records {
+		if strings.EqualFold(record.Name, name) && record.Type == "SRV" {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered, nil
+}
+
+func (c *cloudflareClient) createSRVRecord(ctx context.Context, zoneID, name string, data cfSRVData, ttl int, comment string) (*cfRecord, error) {
+	path := fmt.Sprintf("/zones/%s/dns_records", zoneID)
+	env, err := c.writeRecord(ctx, http.MethodPost, path, srvPayload(name, data, ttl, comment))
+	if err != nil {
+		return nil, err
+	}
+	var record cfRecord
+	if err := json.Unmarshal(env.Result, &record); err != nil {
+		return nil, fmt.Errorf("invalid create record payload: %w", err)
+	}
+	return &record, nil
+}
+
+func (c *cloudflareClient) updateSRVRecord(ctx context.Context, zoneID, recordID, name string, data cfSRVData, ttl int, comment string) (*cfRecord, error) {
+	path := fmt.Sprintf("/zones/%s/dns_records/%s", zoneID, recordID)
+	env, err := c.writeRecord(ctx, http.MethodPut, path, srvPayload(name, data, ttl, comment))
+	if err != nil {
+		return nil, err
+	}
+	var record cfRecord
+	if err := json.Unmarshal(env.Result, &record); err != nil {
+		return nil, fmt.Errorf("invalid update record payload: %w", err)
+	}
+	return &record, nil
+}
+
+func srvPayload(name string, data cfSRVData, ttl int, comment string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "SRV",
+		"name":    name,
+		"data":    data,
+		"ttl":     ttl,
+		"comment": comment,
+	}
+}
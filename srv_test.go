@@ -0,0 +1,74 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSyncSRVRecordsCreatesAndUpdatesOwnTarget(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	other := cfRecord{ID: "s1", Name: "_mc._tcp.example.com", Type: "SRV",
+		Data: &cfSRVData{Priority: 10, Weight: 5, Port: 25565, Target: "backup.example.org"}}
+	cf.records["z1"] = []cfRecord{other}
+
+	cfg := CreateConfig()
+	cfg.Domains = []string{"game.example.com"}
+	cfg.TTL = 300
+	cfg.CommentTemplate = "host={host}"
+	cfg.SRVRecords = []SRVRecord{{Name: "_mc._tcp.example.com", Target: "Game.example.com", Port: 25565, Priority: 0, Weight: 5}}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	var created *cfRecord
+	for i, record := range cf.records["z1"] {
+		if record.Type == "SRV" && record.ID != "s1" {
+			created = &cf.records["z1"][i]
+		}
+	}
+	if created == nil || created.Data == nil || created.Data.Target != "game.example.com" || created.Data.Port != 25565 {
+		t.Fatalf("expected srv record created for the managed target, got %+v", cf.records["z1"])
+	}
+	if created.TTL != 300 || created.Comment != "managed-by=traefik-plugin-ddns host=_mc._tcp.example.com" {
+		t.Fatalf("expected srv record written with the configured ttl and comment, got %+v", *created)
+	}
+	if kept := cf.records["z1"][0]; kept.ID != "s1" || *kept.Data != *other.Data {
+		t.Fatalf("expected srv record for another target untouched, got %+v", cf.records["z1"][0])
+	}
+
+	r.cfg.SRVRecords[0].Port = 25566
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	if n := cf.countCalls("PUT /zones/z1/dns_records/" + created.ID); n != 1 {
+		t.Fatalf("expected one srv update, got %d (%v)", n, cf.calls)
+	}
+
+	before := len(cf.calls)
+	if _, err := r.reconcile(context.Background()); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, call := range cf.calls[before:] {
+		if strings.HasPrefix(call, "PUT") || strings.HasPrefix(call, "POST") {
+			t.Fatalf("expected no writes once in sync, got %v", cf.calls[before:])
+		}
+	}
+}
+
+func TestValidateSRVRecords(t *testing.T) {
+	cases := []SRVRecord{
+		{Name: "game.example.com", Target: "game.example.com", Port: 25565},
+		{Name: "_mc._tcp.example.com", Port: 25565},
+		{Name: "_mc._tcp.example.com", Target: "game.example.com", Port: 0},
+		{Name: "_mc._tcp.example.com", Target: "game.example.com", Port: 25565, Weight: 70000},
+	}
+	for _, srv := range cases {
+		cfg := CreateConfig()
+		cfg.SRVRecords = []SRVRecord{srv}
+		if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+			t.Errorf("expected %+v to be rejected", srv)
+		}
+	}
+}
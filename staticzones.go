@@ -0,0 +1,67 @@
+package ddns_traefik_plugin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StaticZone is a zone known up front, for networks where the token may not list zones.
+type StaticZone struct {
+	// Name is the zone name, for example example.com.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// ID is the 32-character zone ID from the zone's Overview page in the Cloudflare dashboard.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+}
+
+// normalizeStaticZones lowercases and trims names and IDs, dropping a trailing dot from names.
+func normalizeStaticZones(zones []StaticZone) []StaticZone {
+	if len(zones) == 0 {
+		return nil
+	}
+	out := make([]StaticZone, 0, len(zones))
+	for _, zone := range zones {
+		out = append(out, StaticZone{
+			Name: strings.TrimSuffix(strings.ToLower(strings.TrimSpace(zone.Name)), "."),
+			ID:   strings.ToLower(strings.TrimSpace(zone.ID)),
+		})
+	}
+	return out
+}
+
+func validateStaticZones(zones []StaticZone) error {
+	seen := make(map[string]struct{}, len(zones))
+	for i, zone := range zones {
+		if zone.Name == "" || !strings.Contains(zone.Name, ".") || strings.ContainsAny(zone.Name, " */:") {
+			return fmt.Errorf("invalid staticZones[%d].name %q: expected a zone name such as example.com", i, zone.Name)
+		}
+		if !isZoneID(zone.ID) {
+			return fmt.Errorf("invalid staticZones[%d].id %q: expected the 32-character hex zone ID", i, zone.ID)
+		}
+		if _, dup := seen[zone.Name]; dup {
+			return fmt.Errorf("invalid staticZones[%d]: zone %s is listed twice", i, zone.Name)
+		}
+		seen[zone.Name] = struct{}{}
+	}
+	return nil
+}
+
+func isZoneID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// staticCFZones converts StaticZones to the zones listZones would return.
+func staticCFZones(zones []StaticZone) []cfZone {
+	out := make([]cfZone, 0, len(zones))
+	for _, zone := range zones {
+		out = append(out, cfZone{ID: zone.ID, Name: zone.Name})
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"testing"
+)
+
+const testZoneID = "023e105f4ecef8ad9ca31a8372d0c353"
+
+func TestStaticZonesReplaceZoneListing(t *testing.T) {
+	cf := newFakeCloudflare()
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com", "other.org"}
+	cfg.StaticZones = []StaticZone{{Name: "Example.com.", ID: " " + testZoneID}}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	results, err := r.reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	for _, call := range cf.calls {
+		if call == "GET /zones" {
+			t.Fatalf("expected zones never to be listed, got %v", cf.calls)
+		}
+	}
+	actions := map[string]string{}
+	for _, result := range results {
+		actions[result.Domain] = result.Action
+	}
+	if actions["app.example.com"] != ActionCreated || actions["other.org"] != ActionSkipped {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if records := cf.records[testZoneID]; len(records) != 1 || records[0].Name != "app.example.com" {
+		t.Fatalf("expected record in the static zone, got %+v", cf.records)
+	}
+}
+
+func TestValidateStaticZones(t *testing.T) {
+	for _, zones := range [][]StaticZone{
+		{{Name: "example.com", ID: "not-an-id"}},
+		{{Name: "", ID: testZoneID}},
+		{{Name: "example.com", ID: testZoneID}, {Name: "EXAMPLE.com", ID: testZoneID}},
+	} {
+		cfg := CreateConfig()
+		cfg.StaticZones = zones
+		if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+			t.Fatalf("expected staticZones %+v to be rejected", zones)
+		}
+	}
+}
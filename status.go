@@ -0,0 +1,118 @@
+package ddns_traefik_plugin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RunnerStatus is the state reported by the status endpoint.
+type RunnerStatus struct {
+	Version     string    `json:"version"`
+	Paused      bool      `json:"paused"`
+	LastIP      string    `json:"lastIp,omitempty"`
+	LastCycleAt time.Time `json:"lastCycleAt,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+	Hosts       int       `json:"hosts"`
+	// Timings are latency histograms of IP resolution, zone listing and per-host sync.
+	Timings map[string]TimingSnapshot `json:"timings"`
+}
+
+// Pause stops record changes until Resume. It waits for an in-flight cycle to finish,
+// so no write happens after Pause returns.
+func (r *Runner) Pause() {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if !r.paused {
+		r.paused = true
+		r.infof("runner paused; sync cycles are skipped until resumed")
+	}
+}
+
+// Resume re-enables sync cycles after Pause.
+func (r *Runner) Resume() {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if r.paused {
+		r.paused = false
+		r.infof("runner resumed")
+	}
+}
+
+// Status returns a snapshot of the runner state. It waits for an in-flight cycle.
+func (r *Runner) Status() RunnerStatus {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	return RunnerStatus{
+		Version:     Version(),
+		Paused:      r.paused,
+		LastIP:      r.lastKnownIP,
+		LastCycleAt: r.lastCycleAt,
+		LastError:   r.lastCycleErr,
+		Hosts:       len(r.snapshotHosts()),
+		Timings:     r.timings.snapshot(r.clock.Now()),
+	}
+}
+
+// statusHandler serves GET /status, GET /metrics and, when ControlToken is set, POST /pause and POST /resume.
+func (r *Runner) statusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(r.Status())
+	})
+	mux.HandleFunc("/metrics", r.metricsHandler)
+	mux.HandleFunc("/pause", r.controlEndpoint(r.Pause))
+	mux.HandleFunc("/resume", r.controlEndpoint(r.Resume))
+	return mux
+}
+
+// controlEndpoint wraps a state-changing action: POST only, bearer ControlToken required.
+// Without a ControlToken the control endpoints are disabled.
+func (r *Runner) controlEndpoint(action func()) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !r.authorizedControl(req) {
+			http.Error(rw, "forbidden", http.StatusForbidden)
+			return
+		}
+		action()
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(r.Status())
+	}
+}
+
+func (r *Runner) authorizedControl(req *http.Request) bool {
+	controlToken := r.config().ControlToken
+	if controlToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(controlToken)) == 1
+}
+
+// serveStatus listens on StatusAddr and serves statusHandler in the background.
+func (r *Runner) serveStatus() error {
+	listener, err := net.Listen("tcp", r.cfg.StatusAddr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: r.statusHandler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			r.errorf("status server stopped: %v", err)
+		}
+	}()
+	r.infof("status server listening on %s", listener.Addr())
+	return nil
+}
@@ -0,0 +1,135 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPausedRunnerSkipsCycles(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+
+	r.Pause()
+	r.runSyncCycle(context.Background())
+	if len(cf.calls) != 0 {
+		t.Fatalf("expected no api calls while paused, got %v", cf.calls)
+	}
+
+	r.Resume()
+	r.runSyncCycle(context.Background())
+	if cf.countCalls("POST /zones/z1/dns_records") != 1 {
+		t.Fatalf("expected record created after resume, got %v", cf.calls)
+	}
+}
+
+func TestControlEndpointsRequireToken(t *testing.T) {
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.ControlToken = "secret"
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	handler := r.statusHandler()
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/pause", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without token, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/pause", "wrong"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 with wrong token, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/pause", "secret"); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET /pause, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/pause", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for authorized pause, got %d", rec.Code)
+	}
+
+	var status RunnerStatus
+	rec := do(http.MethodGet, "/status", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if !status.Paused {
+		t.Fatalf("expected status to report paused, got %+v", status)
+	}
+
+	do(http.MethodPost, "/resume", "secret")
+	if r.Status().Paused {
+		t.Fatalf("expected runner resumed")
+	}
+}
+
+func TestControlEndpointsDisabledWithoutToken(t *testing.T) {
+	r := newTestRunner(t, CreateConfig(), newFakeCloudflare(), "203.0.113.7")
+	req := httptest.NewRequest(http.MethodPost, "/pause", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	r.statusHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || r.Status().Paused {
+		t.Fatalf("expected control disabled without a token, got %d", rec.Code)
+	}
+}
+
+// TestRegisterPrimaryWhileStatusAndWebhookRun is meant for go test -race: a primary registering while the
+// status server, webhook deliveries and the worker loop read the runner-wide settings must not race.
+func TestRegisterPrimaryWhileStatusAndWebhookRun(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer hook.Close()
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.ControlToken = "control"
+	cfg.WebhookURL = hook.URL
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	r.logger = log.New(io.Discard, "", 0)
+	primary := r.config()
+	primary.Primary = true
+	primary.LogRateLimitPerMinute = 10
+
+	var wg sync.WaitGroup
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				f()
+			}
+		}()
+	}
+	run(func() {
+		if err := r.RegisterConfig("primary", primary); err != nil {
+			t.Errorf("RegisterConfig failed: %v", err)
+		}
+	})
+	run(func() {
+		req := httptest.NewRequest(http.MethodPost, "/resume", nil)
+		req.Header.Set("Authorization", "Bearer control")
+		rec := httptest.NewRecorder()
+		r.statusHandler().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected the control endpoint to accept the token, got %d", rec.Code)
+		}
+	})
+	run(func() {
+		r.sendChanges(context.Background(), []webhookChange{{Domain: "app.example.com", Action: ActionUpdated}}, "203.0.113.7")
+		r.warnf("webhook path log line")
+	})
+	run(func() { r.runSyncCycle(context.Background()) })
+	wg.Wait()
+}
@@ -0,0 +1,35 @@
+package ddns_traefik_plugin
+
+import "runtime/debug"
+
+const modulePath = "github.com/xdsorite/ddns-traefik-plugin"
+
+// version can be set at build time:
+// -ldflags "-X github.com/xdsorite/ddns-traefik-plugin.version=v1.2.3".
+var version = ""
+
+// Version reports the running plugin version.
+// Order: ldflags value, module version from build info, VCS revision, then "dev".
+func Version() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath && dep.Version != "" && dep.Version != "(devel)" {
+			return dep.Version
+		}
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+			return "dev+" + setting.Value[:7]
+		}
+	}
+	return "dev"
+}
@@ -0,0 +1,160 @@
+package ddns_traefik_plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Webhook event names.
+const (
+	webhookIPResolutionFailed = "ip_resolution_failed"
+	webhookRecordsChanged     = "records_changed"
+)
+
+// webhookPayload is the JSON body POSTed to WebhookURL.
+type webhookPayload struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Message string    `json:"message,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	// Changes lists the records created or updated, for records_changed events.
+	Changes []webhookChange `json:"changes,omitempty"`
+}
+
+// webhookChange is one created or updated record in a records_changed event.
+type webhookChange struct {
+	Time   time.Time `json:"time"`
+	Domain string    `json:"domain"`
+	Record string    `json:"record,omitempty"`
+	Zone   string    `json:"zone,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	Action string    `json:"action"`
+}
+
+// changeBatch holds records_changed entries waiting for WebhookBatchWindowSeconds to pass quietly.
+type changeBatch struct {
+	mu      sync.Mutex
+	changes []webhookChange
+	// first is when the oldest pending change was queued; it bounds the delay by WebhookBatchMaxDelaySeconds.
+	first time.Time
+	// ip is the public IP when the newest change was queued; the timer must not read runner state.
+	ip    string
+	timer *time.Timer
+}
+
+// sendWebhook POSTs payload to WebhookURL. Delivery is best effort: failures are returned for logging
+// and never retried, so a broken receiver cannot stall the worker.
+func (r *Runner) sendWebhook(ctx context.Context, payload webhookPayload) error {
+	url, client := r.webhookTarget()
+	if url == "" {
+		return nil
+	}
+	payload.Time = r.clock.Now()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ddns-traefik-plugin/"+Version())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookTarget returns WebhookURL and the client that delivers to it. sendWebhook also runs from the
+// batch timer, outside syncMu.
+func (r *Runner) webhookTarget() (string, *http.Client) {
+	r.cfgMu.RLock()
+	defer r.cfgMu.RUnlock()
+	return r.cfg.WebhookURL, r.webhookClient
+}
+
+// notifyChanges reports the records a cycle created or updated when WebhookOnChange is set: at once, or
+// through the change batch when WebhookBatchWindowSeconds is set.
+func (r *Runner) notifyChanges(ctx context.Context, results []DomainStatus) {
+	if !r.cfg.WebhookOnChange {
+		return
+	}
+	now := r.clock.Now()
+	var changes []webhookChange
+	for _, status := range results {
+		if status.Action != ActionCreated && status.Action != ActionUpdated {
+			continue
+		}
+		changes = append(changes, webhookChange{Time: now, Domain: status.Domain, Record: status.Record,
+			Zone: status.Zone, IP: status.IP, Action: status.Action})
+	}
+	if len(changes) == 0 {
+		return
+	}
+	if r.cfg.WebhookBatchWindowSeconds <= 0 {
+		r.sendChanges(ctx, changes, r.lastKnownIP)
+		return
+	}
+	r.queueChanges(changes, now)
+}
+
+// queueChanges adds changes to the batch and re-arms its timer: the batch is sent once no change arrived
+// for WebhookBatchWindowSeconds, but never later than WebhookBatchMaxDelaySeconds after its oldest change.
+func (r *Runner) queueChanges(changes []webhookChange, now time.Time) {
+	b := &r.changeBatch
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.changes) == 0 {
+		b.first = now
+	}
+	b.changes = append(b.changes, changes...)
+	b.ip = r.lastKnownIP
+	delay := time.Duration(r.cfg.WebhookBatchWindowSeconds) * time.Second
+	if latest := b.first.Add(time.Duration(r.cfg.WebhookBatchMaxDelaySeconds) * time.Second); now.Add(delay).After(latest) {
+		delay = latest.Sub(now)
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = r.afterFunc(delay, r.flushChanges)
+}
+
+// flushChanges sends the pending batch, if any. It runs from the batch timer and from Stop, so a
+// graceful shutdown does not lose queued notifications.
+func (r *Runner) flushChanges() {
+	b := &r.changeBatch
+	b.mu.Lock()
+	changes, ip := b.changes, b.ip
+	b.changes = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+	if len(changes) == 0 {
+		return
+	}
+	// The runner context may already be cancelled by Stop; webhookClient's timeout bounds the request.
+	r.sendChanges(context.Background(), changes, ip)
+}
+
+func (r *Runner) sendChanges(ctx context.Context, changes []webhookChange, ip string) {
+	payload := webhookPayload{Event: webhookRecordsChanged, Message: fmt.Sprintf("%d record changes", len(changes)),
+		IP: ip, Changes: changes}
+	if err := r.sendWebhook(ctx, payload); err != nil {
+		r.errorf("%s webhook failed: %v", payload.Event, err)
+	}
+}
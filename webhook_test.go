@@ -0,0 +1,151 @@
+package ddns_traefik_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIPResolutionFailurePolicies(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		payloads []webhookPayload
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var payload webhookPayload
+		_ = json.NewDecoder(req.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	for _, policy := range []string{ipFailureSkip, ipFailureUseLastKnown, ipFailureAlert} {
+		cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+		cf.records["z1"] = []cfRecord{{ID: "r1", Name: "app.example.com", Type: "A", Content: "198.51.100.1"}}
+		cfg := CreateConfig()
+		cfg.Domains = []string{"app.example.com"}
+		cfg.OnIPResolutionFailure = policy
+		cfg.WebhookURL = hook.URL
+		r := newTestRunner(t, cfg, cf, "not-an-ip")
+		r.lastKnownIP = "203.0.113.7"
+
+		results, err := r.reconcile(context.Background())
+		switch policy {
+		case ipFailureUseLastKnown:
+			if err != nil || len(results) != 1 || results[0].IP != "203.0.113.7" || results[0].Action != ActionUpdated {
+				t.Fatalf("%s: expected record refreshed with last known ip, got %+v (%v)", policy, results, err)
+			}
+		default:
+			if err == nil || cf.countCalls("POST")+cf.countCalls("PUT")+cf.countCalls("PATCH")+cf.countCalls("DELETE") != 0 {
+				t.Fatalf("%s: expected cycle aborted without writes, got %v (%v)", policy, cf.calls, err)
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 1 || payloads[0].Event != webhookIPResolutionFailed || payloads[0].IP != "203.0.113.7" {
+		t.Fatalf("expected one alert webhook, got %+v", payloads)
+	}
+}
+
+func TestValidateIPResolutionFailurePolicy(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.OnIPResolutionFailure = "retry"
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected unknown policy to be rejected")
+	}
+	cfg.OnIPResolutionFailure = "alert"
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected alert without webhookUrl to be rejected")
+	}
+	cfg.WebhookURL = "https://hooks.example.com/ddns"
+	if err := validateConfig(normalizeConfig(*cfg)); err != nil {
+		t.Fatalf("expected alert with webhookUrl to be accepted: %v", err)
+	}
+}
+
+func TestRecordsChangedWebhookIsDebounced(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		payloads []webhookPayload
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var payload webhookPayload
+		_ = json.NewDecoder(req.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	cf := newFakeCloudflare(cfZone{ID: "z1", Name: "example.com"})
+	ip := "203.0.113.7"
+	cfg := CreateConfig()
+	cfg.Domains = []string{"app.example.com"}
+	cfg.WebhookURL = hook.URL
+	cfg.WebhookOnChange = true
+	cfg.WebhookBatchWindowSeconds = 60
+	cfg.WebhookBatchMaxDelaySeconds = 100
+	cfg.ResolveContent = func(host string) (string, error) { return ip, nil }
+	r := newTestRunner(t, cfg, cf, "203.0.113.7")
+	clock := newFakeClock(time.Unix(0, 0))
+	r.clock = clock
+	// Timers never fire on their own here; the test inspects the delays they were armed with.
+	var delays []time.Duration
+	r.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		delays = append(delays, d)
+		return time.AfterFunc(time.Hour, f)
+	}
+
+	for _, step := range []string{"203.0.113.7", "203.0.113.7", "198.51.100.9"} {
+		ip = step
+		r.runSyncCycle(context.Background())
+		clock.Advance(50 * time.Second)
+	}
+	// Created at 0s (window 60s), unchanged at 50s, updated at 100s: the cap ends the batch at 100s.
+	if len(delays) != 2 || delays[0] != 60*time.Second || delays[1] != 0 {
+		t.Fatalf("unexpected timer delays: %v", delays)
+	}
+	mu.Lock()
+	sent := len(payloads)
+	mu.Unlock()
+	if sent != 0 {
+		t.Fatalf("expected changes to wait for the batch timer, got %d webhooks", sent)
+	}
+
+	r.Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 1 || payloads[0].Event != webhookRecordsChanged || len(payloads[0].Changes) != 2 {
+		t.Fatalf("expected one consolidated webhook on stop, got %+v", payloads)
+	}
+	if got := payloads[0].Changes; got[0].Action != ActionCreated || got[1].Action != ActionUpdated || got[1].IP != "198.51.100.9" {
+		t.Fatalf("unexpected changes: %+v", got)
+	}
+}
+
+func TestValidateWebhookBatch(t *testing.T) {
+	cfg := CreateConfig()
+	cfg.WebhookURL = "https://hooks.example.com"
+	cfg.WebhookOnChange = true
+	cfg.WebhookBatchWindowSeconds = 60
+	effective := normalizeConfig(*cfg)
+	if err := validateConfig(effective); err != nil || effective.WebhookBatchMaxDelaySeconds != 600 {
+		t.Fatalf("expected a default cap of 10 windows, got %d (%v)", effective.WebhookBatchMaxDelaySeconds, err)
+	}
+	cfg.WebhookBatchMaxDelaySeconds = 30
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected a cap below the window to be rejected")
+	}
+	cfg = CreateConfig()
+	cfg.WebhookOnChange = true
+	if err := validateConfig(normalizeConfig(*cfg)); err == nil {
+		t.Fatalf("expected webhookOnChange without webhookUrl to be rejected")
+	}
+}